@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"crypto/rsa"
 	"os"
 	"path/filepath"
@@ -17,6 +18,7 @@ type DBConfig struct {
 
 type ApplicationConfig struct {
 	Port                   int           `toml:"PORT"`
+	GrpcPort               int           `toml:"GRPC_PORT"`
 	Development            bool          `toml:"DEVELOPMENT"`
 	LogPath                string        `toml:"LOG_PATH"`
 	GracefulShutdownPeriod time.Duration `toml:"GRACEFUL_SHUTDOWN_PERIOD"`
@@ -40,7 +42,9 @@ type ConsumerOptions struct {
 	Topic          string
 	MaxElapsedTime time.Duration
 	RetryWaitTime  time.Duration
+	Timeout        time.Duration
 	MaxWorker      int
+	Group          string
 }
 
 type ConfigDistributorPubsub struct {
@@ -48,8 +52,66 @@ type ConfigDistributorPubsub struct {
 	Publisher PublisherOptions
 }
 
+type WebhookDispatcherPubsub struct {
+	Consumer  ConsumerOptions
+	Publisher PublisherOptions
+}
+
 type PubsubConfig struct {
 	ConfigDistributor ConfigDistributorPubsub
+	WebhookDispatcher WebhookDispatcherPubsub
+}
+
+type WebhookConfig struct {
+	// Timeout is the timeout of every delivery attempt
+	Timeout time.Duration
+	// MaxInterval is the maximum wait time between the attempts
+	MaxInterval time.Duration
+	// MaxElapsedTime is the maximum time to retry before the delivery is dead-lettered
+	MaxElapsedTime time.Duration
+}
+
+type WatchConfig struct {
+	DefaultTimeout time.Duration
+	MaxTimeout     time.Duration
+}
+
+type WebsocketConfig struct {
+	PingInterval time.Duration `toml:"PING_INTERVAL"`
+	PongTimeout  time.Duration `toml:"PONG_TIMEOUT"`
+	WriteTimeout time.Duration `toml:"WRITE_TIMEOUT"`
+	// SendQueueSize is the number of messages that can be queued per client
+	SendQueueSize int `toml:"SEND_QUEUE_SIZE"`
+	// SendQueuePolicy is applied when the queue is full: drop_oldest, coalesce or disconnect
+	SendQueuePolicy string `toml:"SEND_QUEUE_POLICY"`
+	// MaxSubscriptions is the number of application keys that a connection can subscribe
+	MaxSubscriptions int `toml:"MAX_SUBSCRIPTIONS"`
+	// DriftGracePeriod is the time a client has to report the sent revision,
+	// after that the client that runs an older revision is stuck
+	DriftGracePeriod time.Duration `toml:"DRIFT_GRACE_PERIOD"`
+	// TrustedProxies is the list of the proxy addresses or CIDRs whose X-Forwarded-For is read,
+	// the remote address of the other peers is never taken from the header
+	TrustedProxies []string `toml:"TRUSTED_PROXIES"`
+	// InternalToken authorizes the internal publisher of the server, it's generated
+	// on every start and never written to the configuration file
+	InternalToken string `toml:"-"`
+}
+
+// InternalTokenHeader is the handshake header of the internal publisher
+const InternalTokenHeader = "X-Coma-Internal-Token"
+
+// DistributionConfig collapses the burst of changes of a client key into one distribution
+type DistributionConfig struct {
+	// Window is the quiet time after the last change before the configuration is distributed,
+	// a negative window distributes every change immediately
+	Window time.Duration `toml:"WINDOW"`
+	// MaxDelay is the longest time a change is held by the window
+	MaxDelay time.Duration `toml:"MAX_DELAY"`
+}
+
+// SigningConfig is the key that signs the distributed configuration
+type SigningConfig struct {
+	PrivateKey ed25519.PrivateKey
 }
 
 type Config struct {
@@ -62,7 +124,12 @@ type Config struct {
 			Websocket ExternalWebsocketConfigOptions
 		} `toml:"-"`
 	}
-	Pubsub PubsubConfig `toml:"-"`
+	Websocket    WebsocketConfig    `toml:"WEBSOCKET"`
+	Distribution DistributionConfig `toml:"DISTRIBUTION"`
+	Pubsub       PubsubConfig       `toml:"-"`
+	Watch        WatchConfig        `toml:"-"`
+	Webhook      WebhookConfig      `toml:"-"`
+	Signing      SigningConfig      `toml:"-"`
 
 	Auth struct {
 		User struct {
@@ -88,6 +155,10 @@ func New() Config {
 			panic("creating data directory")
 			return
 		}
+		// the storage may be created before the signing key was introduced
+		if err := createSigningKeyIfNotExist(); err != nil {
+			panic("creating signing key")
+		}
 
 		configPath := filepath.Join(CONST.CFG_PATH, CONST.CFG_NAME)
 		byt, err := os.ReadFile(configPath)
@@ -119,10 +190,20 @@ func New() Config {
 			panic("cannot unmarshaling config")
 		}
 
+		// the configuration file may be created before grpc was introduced
+		if cfg.Application.GrpcPort == 0 {
+			cfg.Application.GrpcPort = CONST.APP_GRPC_PORT
+		}
+
+		cfg.Websocket = setDefaultWebsocketConfig(cfg.Websocket)
+		cfg.Distribution = setDefaultDistributionConfig(cfg.Distribution)
 		cfg.Pubsub = defaultPubsubConfig(CONST.PUBSUB_MAX_WORKER, CONST.PUBSUB_MAX_BUFFER_CAPACITY)
+		cfg.Watch = defaultWatchConfig()
+		cfg.Webhook = defaultWebhookConfig()
 		cfg.External.Coma.Websocket = defaultExternalComaWSConnection(cfg.Application.Port)
 		cfg.Auth.User.PrivateKey = readRSAPrivateKey()
 		cfg.Auth.User.PublicKey = readRSAPublicKey()
+		cfg.Signing.PrivateKey = readSigningKey()
 
 		if cfg.Auth.User.PrivateKey == nil || cfg.Auth.User.PublicKey == nil {
 			panic("PrivateKey or PublicKey is empty")
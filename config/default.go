@@ -1,7 +1,9 @@
 package config
 
 import (
+	"crypto/rand"
 	"crypto/rsa"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,11 +28,13 @@ type ConstObject struct {
 	NIX_STORAGE_PATH                 string
 	WIN_STORAGE_PATH                 string
 	APP_PORT                         int
+	APP_GRPC_PORT                    int
 	PUBSUB_MAX_WORKER                int
 	PUBSUB_MAX_BUFFER_CAPACITY       int
 	DEFAULT_RSA_BITSIZE              int
 	DEFAULT_RSA_PUBLIC_KEY_LOCATION  string
 	DEFAULT_RSA_PRIVATE_KEY_LOCATION string
+	DEFAULT_SIGNING_KEY_LOCATION     string
 }
 
 func (c *ConstObject) getStorageDirPath(goos string) {
@@ -67,11 +71,13 @@ func NewConstObject() ConstObject {
 		DB_DIR_NAME:                      dbDirName,
 		NIX_STORAGE_PATH:                 "/var/lib/coma",
 		APP_PORT:                         5899,
+		APP_GRPC_PORT:                    5900,
 		PUBSUB_MAX_WORKER:                1000000,
 		PUBSUB_MAX_BUFFER_CAPACITY:       1000,
 		DEFAULT_RSA_BITSIZE:              2048,
 		DEFAULT_RSA_PUBLIC_KEY_LOCATION:  cfgPath + "/coma_public.pem",
 		DEFAULT_RSA_PRIVATE_KEY_LOCATION: cfgPath + "/coma_private.pem",
+		DEFAULT_SIGNING_KEY_LOCATION:     cfgPath + "/coma_signing.pem",
 	}
 
 	co.getStorageDirPath(goos)
@@ -97,11 +103,13 @@ func NewDevelopmentConstObject() ConstObject {
 		NIX_STORAGE_PATH:                 wd,
 		WIN_STORAGE_PATH:                 wd,
 		APP_PORT:                         5898,
+		APP_GRPC_PORT:                    5897,
 		PUBSUB_MAX_WORKER:                1000000,
 		PUBSUB_MAX_BUFFER_CAPACITY:       1000,
 		DEFAULT_RSA_BITSIZE:              2048,
 		DEFAULT_RSA_PUBLIC_KEY_LOCATION:  wd + "/coma_public.pem",
 		DEFAULT_RSA_PRIVATE_KEY_LOCATION: wd + "/coma_private.pem",
+		DEFAULT_SIGNING_KEY_LOCATION:     wd + "/coma_signing.pem",
 	}
 
 	co.getStorageDirPath(goos)
@@ -161,15 +169,119 @@ func defaultPubsubConfig(maxWorker int, maxBufferCapacity int) PubsubConfig {
 	return PubsubConfig{
 		ConfigDistributor: ConfigDistributorPubsub{
 			Consumer: ConsumerOptions{
-				Topic:         "pubsub:distribute-config",
-				RetryWaitTime: 10 * time.Second,
-				MaxWorker:     maxWorker,
+				Topic:          "pubsub:distribute-config",
+				MaxElapsedTime: time.Minute,
+				RetryWaitTime:  10 * time.Second,
+				Timeout:        30 * time.Second,
+				MaxWorker:      maxWorker,
 			},
 			Publisher: PublisherOptions{
 				Topic:             "pubsub:distribute-config",
 				MaxBufferCapacity: maxBufferCapacity,
 			},
 		},
+		WebhookDispatcher: WebhookDispatcherPubsub{
+			Consumer: ConsumerOptions{
+				Topic:          "pubsub:webhook-event",
+				MaxElapsedTime: time.Minute,
+				RetryWaitTime:  10 * time.Second,
+				Timeout:        30 * time.Second,
+				MaxWorker:      maxWorker,
+				Group:          "webhook-dispatcher",
+			},
+			Publisher: PublisherOptions{
+				Topic:             "pubsub:webhook-event",
+				MaxBufferCapacity: maxBufferCapacity,
+			},
+		},
+	}
+}
+
+func defaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Timeout:        10 * time.Second,
+		MaxInterval:    1 * time.Minute,
+		MaxElapsedTime: 15 * time.Minute,
+	}
+}
+
+func defaultWebsocketConfig() WebsocketConfig {
+	return WebsocketConfig{
+		PingInterval:     30 * time.Second,
+		PongTimeout:      10 * time.Second,
+		WriteTimeout:     10 * time.Second,
+		SendQueueSize:    16,
+		SendQueuePolicy:  "coalesce",
+		MaxSubscriptions: 16,
+		DriftGracePeriod: time.Minute,
+		InternalToken:    internalToken(),
+	}
+}
+
+// internalToken is the random credential of the internal publisher
+func internalToken() string {
+	byt := make([]byte, 32)
+	if _, err := rand.Read(byt); err != nil {
+		panic("cannot generate internal token")
+	}
+	return hex.EncodeToString(byt)
+}
+
+// setDefaultWebsocketConfig fills the empty options,
+// the configuration file may be created before the options were introduced
+func setDefaultWebsocketConfig(websocketConfig WebsocketConfig) WebsocketConfig {
+	defaultConfig := defaultWebsocketConfig()
+	if websocketConfig.PingInterval <= 0 {
+		websocketConfig.PingInterval = defaultConfig.PingInterval
+	}
+	if websocketConfig.PongTimeout <= 0 {
+		websocketConfig.PongTimeout = defaultConfig.PongTimeout
+	}
+	if websocketConfig.WriteTimeout <= 0 {
+		websocketConfig.WriteTimeout = defaultConfig.WriteTimeout
+	}
+	if websocketConfig.SendQueueSize <= 0 {
+		websocketConfig.SendQueueSize = defaultConfig.SendQueueSize
+	}
+	if websocketConfig.SendQueuePolicy == "" {
+		websocketConfig.SendQueuePolicy = defaultConfig.SendQueuePolicy
+	}
+	if websocketConfig.MaxSubscriptions <= 0 {
+		websocketConfig.MaxSubscriptions = defaultConfig.MaxSubscriptions
+	}
+	if websocketConfig.DriftGracePeriod <= 0 {
+		websocketConfig.DriftGracePeriod = defaultConfig.DriftGracePeriod
+	}
+	if websocketConfig.InternalToken == "" {
+		websocketConfig.InternalToken = defaultConfig.InternalToken
+	}
+	return websocketConfig
+}
+
+func defaultDistributionConfig() DistributionConfig {
+	return DistributionConfig{
+		Window:   250 * time.Millisecond,
+		MaxDelay: 2 * time.Second,
+	}
+}
+
+// setDefaultDistributionConfig fills the empty options,
+// the configuration file may be created before the options were introduced
+func setDefaultDistributionConfig(distributionConfig DistributionConfig) DistributionConfig {
+	defaultConfig := defaultDistributionConfig()
+	if distributionConfig.Window == 0 {
+		distributionConfig.Window = defaultConfig.Window
+	}
+	if distributionConfig.MaxDelay <= 0 {
+		distributionConfig.MaxDelay = defaultConfig.MaxDelay
+	}
+	return distributionConfig
+}
+
+func defaultWatchConfig() WatchConfig {
+	return WatchConfig{
+		DefaultTimeout: 30 * time.Second,
+		MaxTimeout:     5 * time.Minute,
 	}
 }
 
@@ -187,6 +299,7 @@ func defaultConfig() Config {
 	return Config{
 		Application: ApplicationConfig{
 			Port:                   CONST.APP_PORT,
+			GrpcPort:               CONST.APP_GRPC_PORT,
 			Development:            isDevelopment(),
 			GracefulShutdownPeriod: 30 * time.Second,
 			GracefulWarnPeriod:     30 * time.Second,
@@ -209,7 +322,14 @@ func defaultConfig() Config {
 				Websocket: defaultExternalComaWSConnection(CONST.APP_PORT),
 			},
 		},
-		Pubsub: defaultPubsubConfig(CONST.PUBSUB_MAX_WORKER, CONST.PUBSUB_MAX_BUFFER_CAPACITY),
+		Websocket:    defaultWebsocketConfig(),
+		Distribution: defaultDistributionConfig(),
+		Pubsub:       defaultPubsubConfig(CONST.PUBSUB_MAX_WORKER, CONST.PUBSUB_MAX_BUFFER_CAPACITY),
+		Watch:        defaultWatchConfig(),
+		Webhook:      defaultWebhookConfig(),
+		Signing: SigningConfig{
+			PrivateKey: readSigningKey(),
+		},
 		Auth: struct {
 			User struct {
 				PublicKeyLocation    string          "toml:\"PUBLIC_KEY_LOCATION\""
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+)
+
+const (
+	SIGNING_KEY_CODE = "PRIVATE KEY"
+)
+
+// createSigningKeyIfNotExist creates the Ed25519 key that signs the distributed configuration,
+// it's separated from the rsa key of the user token
+func createSigningKeyIfNotExist() error {
+	if _, err := os.Stat(CONST.DEFAULT_SIGNING_KEY_LOCATION); !os.IsNotExist(err) {
+		return nil
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  SIGNING_KEY_CODE,
+		Bytes: privDER,
+	})
+
+	return os.WriteFile(CONST.DEFAULT_SIGNING_KEY_LOCATION, privatePEM, 0600)
+}
+
+func readSigningKey() ed25519.PrivateKey {
+	file, err := os.ReadFile(CONST.DEFAULT_SIGNING_KEY_LOCATION)
+	if err != nil {
+		panic(err)
+	}
+
+	block, _ := pem.Decode(file)
+	if block == nil || block.Type != SIGNING_KEY_CODE {
+		panic(errors.New("err: cannot decode file"))
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		panic(err)
+	}
+
+	privateKey, ok := private.(ed25519.PrivateKey)
+	if !ok {
+		panic(errors.New("not an Ed25519 private key"))
+	}
+
+	return privateKey
+}
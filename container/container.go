@@ -20,12 +20,18 @@ type Repository struct {
 	repository.RepositoryApplicationKeyReader
 	repository.RepositoryApplicationConfigurationWriter
 	repository.RepositoryApplicationConfigurationReader
+	repository.RepositoryApplicationConfigurationOverrideWriter
+	repository.RepositoryApplicationConfigurationOverrideReader
 	repository.RepositoryUserWriter
 	repository.RepositoryUserReader
 	repository.RepositoryUserAuthReader
 	repository.RepositoryUserAuthWriter
 	repository.RepositoryUserApplicationScopeWriter
 	repository.RepositoryUserApplicationScopeReader
+	repository.RepositoryWebhookWriter
+	repository.RepositoryWebhookReader
+	repository.RepositoryWebhookDeliveryWriter
+	repository.RepositoryWebhookDeliveryReader
 }
 
 func (c Repository) Validate() []error {
@@ -54,6 +60,9 @@ type Service struct {
 	service.InternalUserServicer
 	service.UserApplicationScopeServicer
 	service.InternalUserApplicationScopeServicer
+	service.WebhookServicer
+	service.InternalWebhookServicer
+	service.SigningServicer
 }
 
 func (c Service) Validate() []error {
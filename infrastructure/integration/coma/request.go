@@ -4,6 +4,7 @@ import "encoding/json"
 
 type RequestSendMessage struct {
 	ClientKey string          `json:"clientKey"`
+	Revision  string          `json:"revision"`
 	Data      json.RawMessage `json:"data"`
 }
 
@@ -35,10 +35,16 @@ func (w *WebsocketClient) Connect() error {
 				Msg("error when create websocket connection")
 			return nil
 		default:
-			conn, err := websocket.Dial(
+			wsConfig, err := websocket.NewConfig(
 				fmt.Sprintf("%s?self=true", w.url),
-				"",
 				w.cfg.External.Coma.Websocket.OriginUrl)
+			if err != nil {
+				return err
+			}
+			// the self connection is the only publisher of the configuration
+			wsConfig.Header.Set(config.InternalTokenHeader, w.cfg.Websocket.InternalToken)
+
+			conn, err := websocket.DialConfig(wsConfig)
 			if err != nil {
 				connectionErr = err
 				continue
@@ -0,0 +1,218 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nurcahyaari/coma/internal/x/file"
+	"github.com/nurcahyaari/coma/pkg/client"
+	"github.com/rs/zerolog/log"
+)
+
+const SdkName = "coma-agent"
+
+// Agent renders the configuration of an application key into files
+// and keeps them updated as long as it's connected to the server
+type Agent struct {
+	cfg       Config
+	client    *client.Client
+	renderers []*Renderer
+
+	mtx      sync.Mutex
+	revision string
+	reloads  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(cfg Config) (*Agent, error) {
+	agent := &Agent{
+		cfg:     cfg,
+		reloads: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	agent.ctx, agent.cancel = context.WithCancel(context.Background())
+
+	for _, output := range cfg.Outputs {
+		renderer, err := NewRenderer(output)
+		if err != nil {
+			return nil, err
+		}
+		agent.renderers = append(agent.renderers, renderer)
+	}
+
+	opts := []client.Option{
+		client.SetOriginUrl(cfg.OriginUrl),
+		client.SetInstanceId(cfg.InstanceId),
+		client.SetSdk(SdkName, client.Version),
+		client.SetCacheDir(cfg.CacheDir),
+		client.SetRetryMaxWait(cfg.RetryMaxWait),
+		client.SetVerificationKeys(cfg.VerificationKeys...),
+	}
+	if cfg.SigningKeysUrl != "" {
+		opts = append(opts, client.SetSigningKeysUrl(cfg.SigningKeysUrl))
+	}
+
+	c, err := client.New(cfg.Url, cfg.Key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	agent.client = c
+
+	return agent, nil
+}
+
+// Run renders the cached configuration first, so the files are available
+// while the server is unreachable, then it follows the server until it's shutdown
+func (a *Agent) Run() {
+	defer close(a.done)
+
+	unwatch := a.client.Watch(func(snapshot client.Snapshot) {
+		if err := a.apply(snapshot); err != nil {
+			log.Error().
+				Err(err).
+				Msg("[Agent.Run] configuration is not applied, keep serving the last configuration")
+		}
+	})
+	defer unwatch()
+
+	a.client.Start()
+	a.reloader()
+}
+
+// Shutdown stops the agent, the rendered files are kept
+func (a *Agent) Shutdown(ctx context.Context) error {
+	a.cancel()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return a.client.Close(ctx)
+}
+
+// apply renders every output before writing any of them, so an invalid
+// configuration never replaces the last good files
+func (a *Agent) apply(snapshot client.Snapshot) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if snapshot.Revision != "" && snapshot.Revision == a.revision {
+		return nil
+	}
+
+	contents := make([][]byte, len(a.renderers))
+	for idx, renderer := range a.renderers {
+		content, err := renderer.Render(snapshot.Data)
+		if err != nil {
+			return fmt.Errorf("render %s: %w", renderer.Path(), err)
+		}
+		contents[idx] = content
+	}
+
+	changed := false
+	for idx, renderer := range a.renderers {
+		current, err := os.ReadFile(renderer.Path())
+		if err == nil && bytes.Equal(current, contents[idx]) {
+			continue
+		}
+
+		if err := file.WriteAtomic(renderer.Path(), contents[idx], 0644); err != nil {
+			return fmt.Errorf("write %s: %w", renderer.Path(), err)
+		}
+		changed = true
+
+		log.Info().
+			Str("path", renderer.Path()).
+			Str("revision", snapshot.Revision).
+			Msg("[Agent.apply] configuration is rendered")
+	}
+
+	a.revision = snapshot.Revision
+
+	if changed {
+		a.scheduleReload()
+	}
+
+	return nil
+}
+
+// scheduleReload doesn't wait for the reload, so a slow reload command doesn't hold
+// the next configuration. The reloads asked while one is running are coalesced into one
+func (a *Agent) scheduleReload() {
+	select {
+	case a.reloads <- struct{}{}:
+	default:
+	}
+}
+
+// reloader runs the reloads one at a time until the agent is shutdown
+func (a *Agent) reloader() {
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-a.reloads:
+			a.reload()
+		}
+	}
+}
+
+// reload runs the reload command and signals the pid, both are optional
+func (a *Agent) reload() {
+	if a.cfg.ReloadCommand != "" {
+		cmd := exec.Command("sh", "-c", a.cfg.ReloadCommand)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Error().
+				Err(err).
+				Str("command", a.cfg.ReloadCommand).
+				Msg("[Agent.reload] error run reload command")
+		}
+	}
+
+	pid, err := a.pid()
+	if err != nil {
+		log.Error().Err(err).Msg("[Agent.reload] error read pid")
+		return
+	}
+	if pid == 0 {
+		return
+	}
+
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(a.cfg.ReloadSignal)
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("pid", pid).
+			Msg("[Agent.reload] error send reload signal")
+	}
+}
+
+// pid reads the pid file on every reload since the process may be restarted
+func (a *Agent) pid() (int, error) {
+	if a.cfg.PidFile == "" {
+		return a.cfg.Pid, nil
+	}
+
+	data, err := os.ReadFile(a.cfg.PidFile)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
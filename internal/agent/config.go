@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nurcahyaari/coma/pkg/signature"
+)
+
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatYAML     Format = "yaml"
+	FormatTOML     Format = "toml"
+	FormatEnv      Format = "env"
+	FormatTemplate Format = "template"
+)
+
+// Output is where the configuration is rendered,
+// Template is only used by the template format
+type Output struct {
+	Format   Format
+	Template string
+	Path     string
+}
+
+// ParseOutput parses "<format>:<path>" or "template:<template path>:<path>"
+func ParseOutput(value string) (Output, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Output{}, fmt.Errorf("err: invalid output %q, use <format>:<path>", value)
+	}
+
+	output := Output{
+		Format: Format(strings.ToLower(parts[0])),
+		Path:   parts[1],
+	}
+
+	switch output.Format {
+	case FormatJSON, FormatYAML, FormatTOML, FormatEnv:
+	case FormatTemplate:
+		paths := strings.SplitN(parts[1], ":", 2)
+		if len(paths) != 2 || paths[0] == "" || paths[1] == "" {
+			return Output{}, fmt.Errorf("err: invalid output %q, use template:<template path>:<path>", value)
+		}
+		output.Template = paths[0]
+		output.Path = paths[1]
+	default:
+		return Output{}, fmt.Errorf("err: output format %q is not supported", parts[0])
+	}
+
+	return output, nil
+}
+
+type outputs []Output
+
+func (o *outputs) String() string {
+	values := make([]string, 0, len(*o))
+	for _, output := range *o {
+		values = append(values, fmt.Sprintf("%s:%s", output.Format, output.Path))
+	}
+	return strings.Join(values, ",")
+}
+
+func (o *outputs) Set(value string) error {
+	output, err := ParseOutput(value)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, output)
+	return nil
+}
+
+type verificationKeys []signature.VerificationKey
+
+func (k *verificationKeys) String() string {
+	values := make([]string, 0, len(*k))
+	for _, key := range *k {
+		values = append(values, key.KeyId)
+	}
+	return strings.Join(values, ",")
+}
+
+func (k *verificationKeys) Set(value string) error {
+	key, err := signature.NewVerificationKey(value)
+	if err != nil {
+		return err
+	}
+	*k = append(*k, key)
+	return nil
+}
+
+type Config struct {
+	Url           string
+	OriginUrl     string
+	Key           string
+	InstanceId    string
+	Outputs       []Output
+	ReloadCommand string
+	ReloadSignal  syscall.Signal
+	Pid           int
+	PidFile       string
+	CacheDir      string
+	RetryMaxWait  time.Duration
+	// VerificationKeys are pinned, the keys aren't fetched from SigningKeysUrl
+	VerificationKeys []signature.VerificationKey
+	SigningKeysUrl   string
+}
+
+// ParseConfig reads the agent flags, the key can be given by COMA_AGENT_KEY
+// so it's not shown on the process list
+func ParseConfig(args []string) (Config, error) {
+	var (
+		cfg         Config
+		outputs     outputs
+		keys        verificationKeys
+		signal      string
+		hostname, _ = os.Hostname()
+		cacheDir, _ = os.UserCacheDir()
+		flagSet     = flag.NewFlagSet("agent", flag.ContinueOnError)
+	)
+
+	flagSet.StringVar(&cfg.Url, "url", "ws://127.0.0.1:5898/websocket", "websocket url of the coma server, a ws:// url needs -verification-key")
+	flagSet.StringVar(&cfg.OriginUrl, "origin", "http://127.0.0.1/", "origin url of the websocket connection")
+	flagSet.StringVar(&cfg.Key, "key", os.Getenv("COMA_AGENT_KEY"), "application key, default is COMA_AGENT_KEY")
+	flagSet.StringVar(&cfg.InstanceId, "instance-id", hostname, "instance id that is reported to the server")
+	flagSet.Var(&outputs, "output", "rendered file, <json|yaml|toml|env>:<path> or template:<template path>:<path>, can be repeated")
+	flagSet.StringVar(&cfg.ReloadCommand, "reload-command", "", "command that is run with sh -c after the files are changed")
+	flagSet.StringVar(&signal, "reload-signal", "HUP", "signal that is sent to the pid after the files are changed")
+	flagSet.IntVar(&cfg.Pid, "pid", 0, "pid that receives the reload signal")
+	flagSet.StringVar(&cfg.PidFile, "pid-file", "", "file that contains the pid that receives the reload signal")
+	flagSet.StringVar(&cfg.CacheDir, "cache-dir", filepath.Join(cacheDir, "coma-agent"), "directory of the last good configuration")
+	flagSet.DurationVar(&cfg.RetryMaxWait, "retry-max-wait", 30*time.Second, "maximum wait time between the reconnections")
+	flagSet.Var(&keys, "verification-key", "base64 Ed25519 public key of /v1/signing-keys that verifies the configuration, can be repeated, default is fetched from the server on a wss:// url")
+	flagSet.StringVar(&cfg.SigningKeysUrl, "signing-keys-url", "", "https url of the published verification keys, default is /v1/signing-keys of the server")
+
+	if err := flagSet.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	cfg.Outputs = outputs
+	cfg.VerificationKeys = keys
+
+	reloadSignal, exists := signals[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]
+	if !exists {
+		return cfg, fmt.Errorf("err: reload signal %q is not supported", signal)
+	}
+	cfg.ReloadSignal = reloadSignal
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func (c Config) Validate() error {
+	if c.Url == "" {
+		return errors.New("err: url cannot be empty")
+	}
+	if c.Key == "" {
+		return errors.New("err: key cannot be empty")
+	}
+	if len(c.Outputs) == 0 {
+		return errors.New("err: at least one output is required")
+	}
+	if c.Pid != 0 && c.PidFile != "" {
+		return errors.New("err: use either pid or pid-file")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadCoalesced(t *testing.T) {
+	dir := t.TempDir()
+	count := filepath.Join(dir, "reloads")
+
+	renderer, err := NewRenderer(Output{
+		Format: FormatJSON,
+		Path:   filepath.Join(dir, "config.json"),
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := &Agent{
+		cfg: Config{
+			ReloadCommand: fmt.Sprintf("echo reload >> %s; sleep 0.3", count),
+		},
+		renderers: []*Renderer{renderer},
+		reloads:   make(chan struct{}, 1),
+		ctx:       ctx,
+	}
+	go a.reloader()
+
+	reloads := func() int {
+		data, _ := os.ReadFile(count)
+		return strings.Count(string(data), "reload")
+	}
+
+	// the configurations are applied while the first reload is running
+	start := time.Now()
+	for idx := 1; idx <= 5; idx++ {
+		require.NoError(t, a.apply(client.Snapshot{
+			Revision: fmt.Sprint(idx),
+			Data:     json.RawMessage(fmt.Sprintf(`{"revision":%d}`, idx)),
+		}))
+		if idx == 1 {
+			assert.Eventually(t, func() bool { return reloads() == 1 }, time.Second, 10*time.Millisecond)
+		}
+	}
+	assert.Less(t, time.Since(start), 300*time.Millisecond)
+
+	// the pending reloads run once after the first one
+	assert.Eventually(t, func() bool { return reloads() == 2 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 2, reloads())
+}
@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Renderer struct {
+	output   Output
+	template *template.Template
+}
+
+// NewRenderer parses the template once, so the invalid template fails on start
+func NewRenderer(output Output) (*Renderer, error) {
+	renderer := &Renderer{
+		output: output,
+	}
+
+	if output.Format != FormatTemplate {
+		return renderer, nil
+	}
+
+	tmpl, err := template.New(filepath.Base(output.Template)).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"toJson": func(value any) (string, error) {
+				byt, err := json.Marshal(value)
+				return string(byt), err
+			},
+		}).
+		ParseFiles(output.Template)
+	if err != nil {
+		return nil, err
+	}
+	renderer.template = tmpl
+
+	return renderer, nil
+}
+
+func (r *Renderer) Path() string {
+	return r.output.Path
+}
+
+// Render encodes the configuration JSON object to the output format
+func (r *Renderer) Render(data json.RawMessage) ([]byte, error) {
+	var configuration map[string]any
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&configuration); err != nil {
+		return nil, err
+	}
+
+	switch r.output.Format {
+	case FormatJSON:
+		var buff bytes.Buffer
+		if err := json.Indent(&buff, data, "", "  "); err != nil {
+			return nil, err
+		}
+		buff.WriteByte('\n')
+		return buff.Bytes(), nil
+	case FormatYAML:
+		return yaml.Marshal(normalizeNumber(configuration))
+	case FormatTOML:
+		return toml.Marshal(normalizeNumber(configuration))
+	case FormatEnv:
+		return renderEnv(configuration)
+	case FormatTemplate:
+		var buff bytes.Buffer
+		if err := r.template.Execute(&buff, normalizeNumber(configuration)); err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("err: output format %q is not supported", r.output.Format)
+}
+
+// normalizeNumber turns json.Number into int64 or float64,
+// so the encoders don't write the number as a string
+func normalizeNumber(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeNumber(item)
+		}
+		return v
+	case []any:
+		for idx, item := range v {
+			v[idx] = normalizeNumber(item)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// renderEnv writes KEY=value sorted by the key, the nested object is flattened
+// with underscore and the array is written as JSON
+func renderEnv(configuration map[string]any) ([]byte, error) {
+	variables := make(map[string]string)
+	if err := flattenEnv("", configuration, variables); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(variables))
+	for key := range variables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buff bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buff, "%s=%s\n", key, variables[key])
+	}
+	return buff.Bytes(), nil
+}
+
+func flattenEnv(prefix string, value any, variables map[string]string) error {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if err := flattenEnv(envKey(prefix, key), item, variables); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string:
+		variables[prefix] = envValue(v)
+	case json.Number:
+		variables[prefix] = v.String()
+	case bool:
+		variables[prefix] = strconv.FormatBool(v)
+	case nil:
+		variables[prefix] = ""
+	default:
+		byt, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		variables[prefix] = envValue(string(byt))
+	}
+	return nil
+}
+
+func envKey(prefix, key string) string {
+	key = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key))
+
+	if prefix == "" {
+		return key
+	}
+	return fmt.Sprintf("%s_%s", prefix, key)
+}
+
+// envValue quotes the value when it can't be read as is by the shell
+func envValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'\\$`#=;&|<>(){}[]*?!") {
+		return value
+	}
+	return strconv.Quote(value)
+}
@@ -0,0 +1,233 @@
+package agent_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nurcahyaari/coma/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutput(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		expectation agent.Output
+		isErr       bool
+	}{
+		{
+			name:  "json",
+			value: "json:/etc/app/config.json",
+			expectation: agent.Output{
+				Format: agent.FormatJSON,
+				Path:   "/etc/app/config.json",
+			},
+		},
+		{
+			name:  "uppercase format",
+			value: "ENV:/etc/app/.env",
+			expectation: agent.Output{
+				Format: agent.FormatEnv,
+				Path:   "/etc/app/.env",
+			},
+		},
+		{
+			name:  "template",
+			value: "template:/etc/app/nginx.tmpl:/etc/nginx/nginx.conf",
+			expectation: agent.Output{
+				Format:   agent.FormatTemplate,
+				Template: "/etc/app/nginx.tmpl",
+				Path:     "/etc/nginx/nginx.conf",
+			},
+		},
+		{
+			name:  "template without path",
+			value: "template:/etc/app/nginx.tmpl",
+			isErr: true,
+		},
+		{
+			name:  "unsupported format",
+			value: "xml:/etc/app/config.xml",
+			isErr: true,
+		},
+		{
+			name:  "without path",
+			value: "json",
+			isErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			act, err := agent.ParseOutput(tc.value)
+			if tc.isErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectation, act)
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		name  string
+		args  []string
+		isErr bool
+	}{
+		{
+			name: "valid",
+			args: []string{"-key", "secret", "-output", "json:/tmp/config.json", "-reload-signal", "SIGUSR1"},
+		},
+		{
+			name:  "without key",
+			args:  []string{"-key", "", "-output", "json:/tmp/config.json"},
+			isErr: true,
+		},
+		{
+			name:  "without output",
+			args:  []string{"-key", "secret"},
+			isErr: true,
+		},
+		{
+			name:  "unsupported signal",
+			args:  []string{"-key", "secret", "-output", "json:/tmp/config.json", "-reload-signal", "FOO"},
+			isErr: true,
+		},
+		{
+			name: "pinned verification key",
+			args: []string{"-key", "secret", "-output", "json:/tmp/config.json", "-verification-key", "6ybH1SGwb2xXQOVmo7gYsN10sSsAaNyfELBtCwhBh6o="},
+		},
+		{
+			name:  "invalid verification key",
+			args:  []string{"-key", "secret", "-output", "json:/tmp/config.json", "-verification-key", "bm90IGEga2V5"},
+			isErr: true,
+		},
+		{
+			name:  "pid and pid file",
+			args:  []string{"-key", "secret", "-output", "json:/tmp/config.json", "-pid", "1", "-pid-file", "/tmp/app.pid"},
+			isErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := agent.ParseConfig(tc.args)
+			if tc.isErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := json.RawMessage(`{"name":"coma","port":8080,"ratio":0.5,"debug":true,"database":{"host":"localhost","user-name":"root"},"tags":["a","b"],"motd":"hello world"}`)
+
+	tmplPath := filepath.Join(t.TempDir(), "config.tmpl")
+	require.NoError(t, os.WriteFile(tmplPath, []byte("{{ .name }}:{{ .port }} {{ toJson .tags }}"), 0644))
+
+	testCases := []struct {
+		name        string
+		output      agent.Output
+		expectation string
+	}{
+		{
+			name:   "json",
+			output: agent.Output{Format: agent.FormatJSON},
+			expectation: `{
+  "name": "coma",
+  "port": 8080,
+  "ratio": 0.5,
+  "debug": true,
+  "database": {
+    "host": "localhost",
+    "user-name": "root"
+  },
+  "tags": [
+    "a",
+    "b"
+  ],
+  "motd": "hello world"
+}
+`,
+		},
+		{
+			name:   "yaml",
+			output: agent.Output{Format: agent.FormatYAML},
+			expectation: `database:
+    host: localhost
+    user-name: root
+debug: true
+motd: hello world
+name: coma
+port: 8080
+ratio: 0.5
+tags:
+    - a
+    - b
+`,
+		},
+		{
+			name:   "toml",
+			output: agent.Output{Format: agent.FormatTOML},
+			expectation: `debug = true
+motd = 'hello world'
+name = 'coma'
+port = 8080
+ratio = 0.5
+tags = ['a', 'b']
+
+[database]
+host = 'localhost'
+user-name = 'root'
+`,
+		},
+		{
+			name:   "env",
+			output: agent.Output{Format: agent.FormatEnv},
+			expectation: `DATABASE_HOST=localhost
+DATABASE_USER_NAME=root
+DEBUG=true
+MOTD="hello world"
+NAME=coma
+PORT=8080
+RATIO=0.5
+TAGS="[\"a\",\"b\"]"
+`,
+		},
+		{
+			name:        "template",
+			output:      agent.Output{Format: agent.FormatTemplate, Template: tmplPath},
+			expectation: `coma:8080 ["a","b"]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			renderer, err := agent.NewRenderer(tc.output)
+			require.NoError(t, err)
+
+			act, err := renderer.Render(data)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectation, string(act))
+		})
+	}
+}
+
+func TestRenderTemplateMissingKey(t *testing.T) {
+	tmplPath := filepath.Join(t.TempDir(), "config.tmpl")
+	require.NoError(t, os.WriteFile(tmplPath, []byte("{{ .missing }}"), 0644))
+
+	renderer, err := agent.NewRenderer(agent.Output{Format: agent.FormatTemplate, Template: tmplPath})
+	require.NoError(t, err)
+
+	_, err = renderer.Render(json.RawMessage(`{"name":"coma"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package agent
+
+import "syscall"
+
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+}
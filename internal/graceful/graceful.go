@@ -35,6 +35,13 @@ func GracefulShutdown(ctx context.Context, req RequestGraceful) {
 	signal.Notify(signalchan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	oscall := <-signalchan
 
+	// the operations stop waiting for their work after the shutdown period
+	if req.ShutdownPeriod > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.WarnPeriod+req.ShutdownPeriod)
+		defer cancel()
+	}
+
 	wg := sync.WaitGroup{}
 	wg.Add(len(req.Operations))
 	for k, op := range req.Operations {
@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/nurcahyaari/coma/config"
+	comav1 "github.com/nurcahyaari/coma/pkg/proto/coma/v1"
+	grpchandler "github.com/nurcahyaari/coma/src/handlers/grpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+type Grpc struct {
+	cfg        config.Config
+	handler    *grpchandler.GrpcHandler
+	grpcServer *grpc.Server
+}
+
+func New(cfg config.Config, handler *grpchandler.GrpcHandler) *Grpc {
+	return &Grpc{
+		cfg:     cfg,
+		handler: handler,
+		grpcServer: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(handler.UnaryInterceptorApplicationKey),
+			grpc.ChainStreamInterceptor(handler.StreamInterceptorApplicationKey),
+		),
+	}
+}
+
+func (g *Grpc) Listen() {
+	comav1.RegisterConfigurationServiceServer(g.grpcServer, g.handler)
+
+	serverPort := fmt.Sprintf(":%d", g.cfg.Application.GrpcPort)
+	listener, err := net.Listen("tcp", serverPort)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot listen grpc port")
+	}
+
+	log.Info().Msgf("Grpc server started on Port %s ", serverPort)
+	if err := g.grpcServer.Serve(listener); err != nil {
+		log.Fatal().Err(err).Msg("cannot establish grpc connection")
+	}
+}
+
+func (g *Grpc) Shutdown(ctx context.Context) error {
+	// end the watch streams, otherwise graceful stop will wait for them forever
+	g.handler.Close()
+
+	stopped := make(chan bool)
+	go func() {
+		g.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		g.grpcServer.Stop()
+	}
+
+	return nil
+}
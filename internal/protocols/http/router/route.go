@@ -3,18 +3,40 @@ package router
 import (
 	"github.com/go-chi/chi/v5"
 	httphandler "github.com/nurcahyaari/coma/src/handlers/http"
+	"github.com/nurcahyaari/coma/src/handlers/localpubsub"
 	websockethandler "github.com/nurcahyaari/coma/src/handlers/websocket"
 )
 
 type HttpRoute struct {
 	handler   *httphandler.HttpHandle
 	wsHandler *websockethandler.WebsocketHandler
+	psHandler *localpubsub.LocalPubsub
 }
 
 func (h *HttpRoute) Router(r *chi.Mux) {
 	h.handler.Router(r)
 	h.wsHandler.Router(r)
 
+	r.Route("/v1/connections", func(r chi.Router) {
+		r.Use(
+			h.handler.MiddlewareLocalAuthAccessTokenValidate,
+			h.handler.MiddlewareLocalAuthUserScope)
+		h.wsHandler.ConnectionRouter(r)
+	})
+
+	r.Route("/v1/pubsub/dead-letters", func(r chi.Router) {
+		r.Use(
+			h.handler.MiddlewareLocalAuthAccessTokenValidate,
+			h.handler.MiddlewareLocalAuthUserScope)
+		h.psHandler.DeadLetterRouter(r)
+	})
+
+	r.Route("/v1/pubsub/stats", func(r chi.Router) {
+		r.Use(
+			h.handler.MiddlewareLocalAuthAccessTokenValidate,
+			h.handler.MiddlewareLocalAuthUserScope)
+		h.psHandler.StatsRouter(r)
+	})
 }
 
 func (h *HttpRoute) CloseWebsocket() {
@@ -24,9 +46,11 @@ func (h *HttpRoute) CloseWebsocket() {
 func NewHttpRouter(
 	handler *httphandler.HttpHandle,
 	wsHandler *websockethandler.WebsocketHandler,
+	psHandler *localpubsub.LocalPubsub,
 ) *HttpRoute {
 	return &HttpRoute{
 		handler:   handler,
 		wsHandler: wsHandler,
+		psHandler: psHandler,
 	}
 }
@@ -0,0 +1,31 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nurcahyaari/coma/internal/x/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.json")
+
+	require.NoError(t, file.WriteAtomic(path, []byte("first"), 0640))
+	require.NoError(t, os.Chmod(path, 0600))
+	require.NoError(t, file.WriteAtomic(path, []byte("second"), 0644))
+
+	act, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(act))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
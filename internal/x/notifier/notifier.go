@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"sync"
+)
+
+// Notifier wakes up every waiter that is registered on a key
+// it is used to block the caller until something happened on that key
+type Notifier struct {
+	mtx     sync.Mutex
+	counter uint64
+	waiters map[string]map[uint64]chan struct{}
+}
+
+func New() *Notifier {
+	return &Notifier{
+		waiters: make(map[string]map[uint64]chan struct{}),
+	}
+}
+
+// Wait registers a waiter on the key, the returned channel is closed
+// when the key is notified. cancel must be called when the caller
+// doesn't need to wait anymore
+func (n *Notifier) Wait(key string) (<-chan struct{}, func()) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.counter++
+	id := n.counter
+	ch := make(chan struct{})
+
+	if _, exists := n.waiters[key]; !exists {
+		n.waiters[key] = make(map[uint64]chan struct{})
+	}
+	n.waiters[key][id] = ch
+
+	cancel := func() {
+		n.mtx.Lock()
+		defer n.mtx.Unlock()
+
+		waiters, exists := n.waiters[key]
+		if !exists {
+			return
+		}
+		delete(waiters, id)
+		if len(waiters) == 0 {
+			delete(n.waiters, key)
+		}
+	}
+
+	return ch, cancel
+}
+
+// Notify wakes up all the waiters of the key
+func (n *Notifier) Notify(key string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for _, ch := range n.waiters[key] {
+		close(ch)
+	}
+	delete(n.waiters, key)
+}
+
+// Len returns the number of waiters of the key
+func (n *Notifier) Len(key string) int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return len(n.waiters[key])
+}
@@ -0,0 +1,58 @@
+package notifier_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Run("notify wakes up all waiters of the key", func(t *testing.T) {
+		n := notifier.New()
+
+		wait1, cancel1 := n.Wait("key-1")
+		defer cancel1()
+		wait2, cancel2 := n.Wait("key-1")
+		defer cancel2()
+
+		assert.Equal(t, 2, n.Len("key-1"))
+
+		n.Notify("key-1")
+
+		for _, wait := range []<-chan struct{}{wait1, wait2} {
+			select {
+			case <-wait:
+			case <-time.After(time.Second):
+				t.Fatal("waiter is not notified")
+			}
+		}
+		assert.Equal(t, 0, n.Len("key-1"))
+	})
+
+	t.Run("notify doesn't wake up waiters of another key", func(t *testing.T) {
+		n := notifier.New()
+
+		wait, cancel := n.Wait("key-1")
+		defer cancel()
+
+		n.Notify("key-2")
+
+		select {
+		case <-wait:
+			t.Fatal("waiter must not be notified")
+		case <-time.After(10 * time.Millisecond):
+		}
+	})
+
+	t.Run("cancel removes the waiter", func(t *testing.T) {
+		n := notifier.New()
+
+		_, cancel := n.Wait("key-1")
+		cancel()
+
+		assert.Equal(t, 0, n.Len("key-1"))
+		n.Notify("key-1")
+	})
+}
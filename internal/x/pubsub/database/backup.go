@@ -1,12 +1,21 @@
 package database
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Backup struct {
-	Id         string `json:"_id"`
-	SequenceId int64  `json:"sequenceId"`
-	Topic      string `json:"topic"`
-	Message    []byte `json:"byte"`
+	Id          string            `json:"_id"`
+	SequenceId  int64             `json:"sequenceId"`
+	Topic       string            `json:"topic"`
+	MessageId   string            `json:"messageId"`
+	Key         string            `json:"key,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	PublishedAt time.Time         `json:"publishedAt"`
+	Message     []byte            `json:"byte"`
+	// SubscriberId is the only receiver of the replayed dead letter
+	SubscriberId string `json:"subscriberId,omitempty"`
 }
 
 func (r Backup) MapStringInterface() (map[string]interface{}, error) {
@@ -1,12 +1,19 @@
 package database
 
 import (
+	"sync"
+
 	"github.com/ostafen/clover"
 )
 
 type CloverDatabase struct {
 	name string
 	db   *clover.DB
+
+	// mtx serializes the sequence id of the stored backups
+	mtx            sync.Mutex
+	lastSequenceId int64
+	loaded         bool
 }
 
 func NewCloverDatabase(db *clover.DB) Databaser {
@@ -22,7 +29,7 @@ func (db *CloverDatabase) getLastSequenceId() (int64, error) {
 	var backup Backup
 	doc, err := db.db.Query(db.name).
 		Sort(clover.SortOption{
-			Field:     "sequence_id",
+			Field:     "sequenceId",
 			Direction: -1}).
 		FindFirst()
 	if err != nil {
@@ -40,15 +47,29 @@ func (db *CloverDatabase) getLastSequenceId() (int64, error) {
 	return backup.SequenceId, nil
 }
 
-func (db *CloverDatabase) delete(topic string) error {
-	return db.db.Query(db.name).Where(clover.Field("topic").Eq(topic)).Delete()
+// nextSequenceId continues the sequence of the stored backups, the sequence is shared by the topics
+func (db *CloverDatabase) nextSequenceId() (int64, error) {
+	if !db.loaded {
+		sequenceId, err := db.getLastSequenceId()
+		if err != nil {
+			return 0, err
+		}
+		db.lastSequenceId = sequenceId
+		db.loaded = true
+	}
+
+	db.lastSequenceId++
+	return db.lastSequenceId, nil
 }
 
-func (db *CloverDatabase) RetrieveAndDelete(topic string) (Backups, error) {
+func (db *CloverDatabase) Retrieve(topic string) (Backups, error) {
 	var backups Backups
 
 	docs, err := db.db.Query(db.name).
 		Where(clover.Field("topic").Eq(topic)).
+		Sort(clover.SortOption{
+			Field:     "sequenceId",
+			Direction: 1}).
 		FindAll()
 	if err != nil {
 		return nil, err
@@ -63,30 +84,43 @@ func (db *CloverDatabase) RetrieveAndDelete(topic string) (Backups, error) {
 		backups = append(backups, backup)
 	}
 
-	db.delete(topic)
-
 	return backups, nil
 }
 
-func (db *CloverDatabase) Store(data Backup) error {
-	sequenceId, err := db.getLastSequenceId()
+func (db *CloverDatabase) Store(data Backup) (Backup, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	sequenceId, err := db.nextSequenceId()
 	if err != nil {
-		return err
+		return Backup{}, err
 	}
 
 	data.SequenceId = sequenceId
 	dataMap, err := data.MapStringInterface()
 	if err != nil {
-		return err
+		return Backup{}, err
 	}
 
 	doc := clover.NewDocument()
 	doc.SetAll(dataMap)
 
-	_, err = db.db.InsertOne(db.name, doc)
+	id, err := db.db.InsertOne(db.name, doc)
 	if err != nil {
-		return err
+		return Backup{}, err
+	}
+
+	data.Id = id
+	return data, nil
+}
+
+func (db *CloverDatabase) Delete(topic string, sequenceIds ...int64) error {
+	ids := make([]interface{}, 0, len(sequenceIds))
+	for _, sequenceId := range sequenceIds {
+		ids = append(ids, sequenceId)
 	}
 
-	return nil
+	return db.db.Query(db.name).
+		Where(clover.Field("topic").Eq(topic).And(clover.Field("sequenceId").In(ids...))).
+		Delete()
 }
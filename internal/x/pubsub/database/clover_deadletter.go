@@ -0,0 +1,75 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/ostafen/clover"
+)
+
+type CloverDeadLetterDatabase struct {
+	name string
+	db   *clover.DB
+}
+
+func NewCloverDeadLetterDatabase(db *clover.DB) DeadLetterDatabaser {
+	name := "x_system_storage_pubsub_dead_letter"
+	db.CreateCollection(name)
+	return &CloverDeadLetterDatabase{
+		db:   db,
+		name: name,
+	}
+}
+
+func (db *CloverDeadLetterDatabase) criteria(filter FilterDeadLetter) *clover.Criteria {
+	criteria := clover.Field("topic").Eq(filter.Topic)
+	if len(filter.Ids) == 0 {
+		return criteria
+	}
+
+	ids := make([]interface{}, 0, len(filter.Ids))
+	for _, id := range filter.Ids {
+		ids = append(ids, id)
+	}
+	return criteria.And(clover.Field("id").In(ids...))
+}
+
+func (db *CloverDeadLetterDatabase) StoreDeadLetter(deadLetter DeadLetter) error {
+	dataMap, err := deadLetter.MapStringInterface()
+	if err != nil {
+		return err
+	}
+
+	doc := clover.NewDocument()
+	doc.SetAll(dataMap)
+
+	_, err = db.db.InsertOne(db.name, doc)
+	return err
+}
+
+func (db *CloverDeadLetterDatabase) FindDeadLetters(filter FilterDeadLetter) (DeadLetters, error) {
+	docs, err := db.db.Query(db.name).
+		Where(db.criteria(filter)).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := DeadLetters{}
+	for _, doc := range docs {
+		deadLetter := DeadLetter{}
+		if err := doc.Unmarshal(&deadLetter); err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	sort.SliceStable(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FailedAt.Before(deadLetters[j].FailedAt)
+	})
+
+	return deadLetters, nil
+}
+
+func (db *CloverDeadLetterDatabase) DeleteDeadLetters(filter FilterDeadLetter) error {
+	return db.db.Query(db.name).Where(db.criteria(filter)).Delete()
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/ostafen/clover"
+)
+
+type CloverScheduleDatabase struct {
+	name string
+	db   *clover.DB
+}
+
+func NewCloverScheduleDatabase(db *clover.DB) ScheduleDatabaser {
+	name := "x_system_storage_pubsub_schedule"
+	db.CreateCollection(name)
+	return &CloverScheduleDatabase{
+		db:   db,
+		name: name,
+	}
+}
+
+func (db *CloverScheduleDatabase) criteria(filter FilterSchedule) *clover.Criteria {
+	criteria := clover.Field("topic").Eq(filter.Topic)
+	if len(filter.Ids) == 0 {
+		return criteria
+	}
+
+	ids := make([]interface{}, 0, len(filter.Ids))
+	for _, id := range filter.Ids {
+		ids = append(ids, id)
+	}
+	return criteria.And(clover.Field("id").In(ids...))
+}
+
+func (db *CloverScheduleDatabase) StoreSchedule(schedule Schedule) error {
+	dataMap, err := schedule.MapStringInterface()
+	if err != nil {
+		return err
+	}
+
+	doc := clover.NewDocument()
+	doc.SetAll(dataMap)
+
+	_, err = db.db.InsertOne(db.name, doc)
+	return err
+}
+
+func (db *CloverScheduleDatabase) FindSchedules(filter FilterSchedule) (Schedules, error) {
+	docs, err := db.db.Query(db.name).
+		Where(db.criteria(filter)).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := Schedules{}
+	for _, doc := range docs {
+		schedule := Schedule{}
+		if err := doc.Unmarshal(&schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].DeliverAt.Before(schedules[j].DeliverAt)
+	})
+
+	return schedules, nil
+}
+
+func (db *CloverScheduleDatabase) DeleteSchedules(filter FilterSchedule) error {
+	return db.db.Query(db.name).Where(db.criteria(filter)).Delete()
+}
@@ -2,9 +2,14 @@ package database
 
 import "github.com/ostafen/clover"
 
+// Databaser is the write-ahead log of the published messages, the message is stored before
+// it's queued and deleted after every subscriber is done with it
 type Databaser interface {
-	RetrieveAndDelete(topic string) (Backups, error)
-	Store(data Backup) error
+	// Retrieve returns the stored messages of the topic in the SequenceId order
+	Retrieve(topic string) (Backups, error)
+	// Store appends the message with the next SequenceId
+	Store(data Backup) (Backup, error)
+	Delete(topic string, sequenceIds ...int64) error
 }
 
 type DatabaseDriver string
@@ -12,6 +17,7 @@ type DatabaseDriver string
 const (
 	MYSQL  DatabaseDriver = "mysql"
 	CLOVER DatabaseDriver = "clover"
+	FILE   DatabaseDriver = "file"
 )
 
 type Database struct {
@@ -21,3 +27,23 @@ type Database struct {
 func (d *Database) NewCloverDatabase(db *clover.DB) Databaser {
 	return NewCloverDatabase(db)
 }
+
+func (d *Database) NewCloverDeadLetterDatabase(db *clover.DB) DeadLetterDatabaser {
+	return NewCloverDeadLetterDatabase(db)
+}
+
+func (d *Database) NewCloverScheduleDatabase(db *clover.DB) ScheduleDatabaser {
+	return NewCloverScheduleDatabase(db)
+}
+
+func (d *Database) NewFileDatabase(path string) Databaser {
+	return NewFileDatabase(path)
+}
+
+func (d *Database) NewFileDeadLetterDatabase(path string) DeadLetterDatabaser {
+	return NewFileDeadLetterDatabase(path)
+}
+
+func (d *Database) NewFileScheduleDatabase(path string) ScheduleDatabaser {
+	return NewFileScheduleDatabase(path)
+}
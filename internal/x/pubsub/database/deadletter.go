@@ -0,0 +1,66 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeadLetter is the message that exhausts the retries of a subscriber
+type DeadLetter struct {
+	Id           string            `json:"id"`
+	Topic        string            `json:"topic"`
+	SubscriberId string            `json:"subscriberId"`
+	MessageId    string            `json:"messageId"`
+	Key          string            `json:"key,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	PublishedAt  time.Time         `json:"publishedAt"`
+	Message      []byte            `json:"message"`
+	Error        string            `json:"error"`
+	Attempts     int               `json:"attempts"`
+	FailedAt     time.Time         `json:"failedAt"`
+}
+
+func (d DeadLetter) MapStringInterface() (map[string]interface{}, error) {
+	mapStringIntf := make(map[string]interface{})
+	j, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(j, &mapStringIntf)
+	if err != nil {
+		return nil, err
+	}
+	return mapStringIntf, nil
+}
+
+type DeadLetters []DeadLetter
+
+// FilterDeadLetter selects the dead letters of the topic, every dead letter
+// of the topic is selected when the ids are empty
+type FilterDeadLetter struct {
+	Topic string
+	Ids   []string
+}
+
+func (f FilterDeadLetter) match(d DeadLetter) bool {
+	if d.Topic != f.Topic {
+		return false
+	}
+	if len(f.Ids) == 0 {
+		return true
+	}
+	for _, id := range f.Ids {
+		if d.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetterDatabaser stores the dead letters, they're returned in the failed order
+type DeadLetterDatabaser interface {
+	StoreDeadLetter(deadLetter DeadLetter) error
+	FindDeadLetters(filter FilterDeadLetter) (DeadLetters, error)
+	DeleteDeadLetters(filter FilterDeadLetter) error
+}
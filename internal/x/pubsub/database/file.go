@@ -1,21 +1,428 @@
 package database
 
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrDatabaseIsLocked is returned when the directory is written by another database, e.g. another process
+	ErrDatabaseIsLocked error = errors.New("err: database is locked by another writer")
+	// ErrDatabaseIsReadOnly is returned on the writes of the reader
+	ErrDatabaseIsReadOnly error = errors.New("err: database is read only")
+	// ErrDatabaseIsClosed is returned after the database is closed, the lock isn't taken again
+	ErrDatabaseIsClosed error = errors.New("err: database is closed")
+)
+
+const (
+	// lockExtension is the extension of the lock files, the writer of the directory holds them
+	lockExtension    = ".lock"
+	segmentExtension = ".wal"
+	// compactRecords is the number of the records before the segment is compacted,
+	// it's compacted when most of the records are deleted
+	compactRecords = 1024
+)
+
+type fileOperation string
+
+const (
+	fileOperationStore  fileOperation = "store"
+	fileOperationDelete fileOperation = "delete"
+)
+
+// fileRecord is a line of the segment
+type fileRecord struct {
+	Operation   fileOperation `json:"op"`
+	Backup      *Backup       `json:"backup,omitempty"`
+	SequenceIds []int64       `json:"sequenceIds,omitempty"`
+}
+
+// FileDatabase is the write-ahead log on the files, every topic has its own segment file in
+// the directory. The stored messages and the deletions are appended to the segment and fsync'd,
+// the segment that is mostly deleted is compacted. The writers of the process are serialized,
+// the directory is locked so the other processes can't write to it at the same time
 type FileDatabase struct {
 	path string
+	// readOnly reads the segments without the lock, the torn record isn't truncated
+	readOnly bool
+
+	mtx            sync.Mutex
+	lock           *os.File
+	loaded         bool
+	closed         bool
+	lastSequenceId int64
+	segments       map[string]*segment
+}
+
+// segment is the file of a topic and its stored messages
+type segment struct {
+	file    *os.File
+	backups map[int64]Backup
+	records int
 }
 
 func NewFileDatabase(path string) Databaser {
 	return &FileDatabase{
-		path: path,
+		path:     path,
+		segments: make(map[string]*segment),
+	}
+}
+
+// NewFileDatabaseReader reads the write-ahead log of the directory while another database writes to it,
+// the writes of the reader fail with ErrDatabaseIsReadOnly
+func NewFileDatabaseReader(path string) Databaser {
+	return &FileDatabase{
+		path:     path,
+		readOnly: true,
+		segments: make(map[string]*segment),
+	}
+}
+
+// openLock opens the lock file of the directory and locks it, it fails with
+// ErrDatabaseIsLocked while another database holds the lock
+func openLock(dir, name string) (*os.File, error) {
+	file, err := os.OpenFile(filepath.Join(dir, name+lockExtension), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// load opens the segments of the directory once, the sequence continues after the stored messages
+func (f *FileDatabase) load() error {
+	if f.closed {
+		return ErrDatabaseIsClosed
+	}
+	if f.loaded {
+		return nil
+	}
+
+	if err := os.MkdirAll(f.path, 0o755); err != nil {
+		return err
+	}
+	if !f.readOnly && f.lock == nil {
+		lock, err := openLock(f.path, "wal")
+		if err != nil {
+			return err
+		}
+		f.lock = lock
+	}
+
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentExtension) {
+			continue
+		}
+
+		topic, err := url.PathUnescape(strings.TrimSuffix(entry.Name(), segmentExtension))
+		if err != nil {
+			continue
+		}
+
+		seg, err := f.openSegment(topic)
+		if err != nil {
+			return err
+		}
+		f.segments[topic] = seg
+
+		for sequenceId := range seg.backups {
+			if sequenceId > f.lastSequenceId {
+				f.lastSequenceId = sequenceId
+			}
+		}
 	}
+
+	f.loaded = true
+	return nil
 }
 
-func (f *FileDatabase) RetrieveAndDelete(topic string) (Backups, error) {
-	// todo: implement golang file access
-	return nil, nil
+func (f *FileDatabase) segmentPath(topic string) string {
+	return filepath.Join(f.path, url.PathEscape(topic)+segmentExtension)
 }
 
-func (f *FileDatabase) Store(data Backup) error {
-	// todo: implement golang file access
+// openSegment reads the records of the segment, the torn record of a crash is truncated
+func (f *FileDatabase) openSegment(topic string) (*segment, error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if f.readOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(f.segmentPath(topic), flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{
+		file:    file,
+		backups: make(map[int64]Backup),
+	}
+
+	var (
+		offset int64
+		reader = bufio.NewReader(file)
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		var record fileRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			break
+		}
+		seg.apply(record)
+		offset += int64(len(line))
+	}
+
+	if f.readOnly {
+		return seg, nil
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return seg, nil
+}
+
+func (seg *segment) apply(record fileRecord) {
+	seg.records++
+	switch record.Operation {
+	case fileOperationStore:
+		if record.Backup != nil {
+			seg.backups[record.Backup.SequenceId] = *record.Backup
+		}
+	case fileOperationDelete:
+		for _, sequenceId := range record.SequenceIds {
+			delete(seg.backups, sequenceId)
+		}
+	}
+}
+
+// append writes the record and waits until it's on the disk
+func (seg *segment) append(record fileRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := seg.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := seg.file.Sync(); err != nil {
+		return err
+	}
+
+	seg.apply(record)
 	return nil
 }
+
+func (seg *segment) sorted() Backups {
+	backups := make(Backups, 0, len(seg.backups))
+	for _, backup := range seg.backups {
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].SequenceId < backups[j].SequenceId
+	})
+	return backups
+}
+
+// compact rewrites the segment with the stored messages only, the file is
+// replaced after the new one is on the disk
+func (f *FileDatabase) compact(topic string, seg *segment) error {
+	var buf bytes.Buffer
+	backups := seg.sorted()
+	for i := range backups {
+		line, err := json.Marshal(fileRecord{
+			Operation: fileOperationStore,
+			Backup:    &backups[i],
+		})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	path := f.segmentPath(topic)
+	tmp, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		tmp.Close()
+		return err
+	}
+	if dir, err := os.Open(f.path); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	seg.file.Close()
+	seg.file = tmp
+	seg.records = len(backups)
+	_, err = seg.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (f *FileDatabase) segment(topic string) (*segment, error) {
+	if seg, exists := f.segments[topic]; exists {
+		return seg, nil
+	}
+
+	seg, err := f.openSegment(topic)
+	if err != nil {
+		return nil, err
+	}
+	f.segments[topic] = seg
+	return seg, nil
+}
+
+func (f *FileDatabase) Retrieve(topic string) (Backups, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	seg, exists := f.segments[topic]
+	if !exists {
+		return nil, nil
+	}
+	return seg.sorted(), nil
+}
+
+func (f *FileDatabase) Store(data Backup) (Backup, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.readOnly {
+		return Backup{}, ErrDatabaseIsReadOnly
+	}
+	if err := f.load(); err != nil {
+		return Backup{}, err
+	}
+
+	seg, err := f.segment(data.Topic)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	data.SequenceId = f.lastSequenceId + 1
+	if err := seg.append(fileRecord{
+		Operation: fileOperationStore,
+		Backup:    &data,
+	}); err != nil {
+		return Backup{}, err
+	}
+
+	f.lastSequenceId = data.SequenceId
+	return data, nil
+}
+
+func (f *FileDatabase) Delete(topic string, sequenceIds ...int64) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.readOnly {
+		return ErrDatabaseIsReadOnly
+	}
+	if err := f.load(); err != nil {
+		return err
+	}
+
+	seg, exists := f.segments[topic]
+	if !exists {
+		return nil
+	}
+
+	deleted := make([]int64, 0, len(sequenceIds))
+	for _, sequenceId := range sequenceIds {
+		if _, exists := seg.backups[sequenceId]; exists {
+			deleted = append(deleted, sequenceId)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	if err := seg.append(fileRecord{
+		Operation:   fileOperationDelete,
+		SequenceIds: deleted,
+	}); err != nil {
+		return err
+	}
+
+	// the drained segment is emptied in place
+	if len(seg.backups) == 0 {
+		return seg.truncate()
+	}
+	if seg.records >= compactRecords && seg.records > 2*len(seg.backups) {
+		return f.compact(topic, seg)
+	}
+	return nil
+}
+
+func (seg *segment) truncate() error {
+	if err := seg.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := seg.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	seg.records = 0
+	return seg.file.Sync()
+}
+
+// Close closes the segment files and releases the lock of the directory
+func (f *FileDatabase) Close() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var errs []error
+	for topic, seg := range f.segments {
+		errs = append(errs, seg.file.Close())
+		delete(f.segments, topic)
+	}
+	if f.lock != nil {
+		errs = append(errs, f.lock.Close())
+		f.lock = nil
+	}
+	f.loaded = false
+	f.closed = true
+	return errors.Join(errs...)
+}
@@ -0,0 +1,32 @@
+package database
+
+// FileDeadLetterDatabase keeps the dead letters in a file next to the segments of the write-ahead log,
+// it's used when the pubsub has no clover database
+type FileDeadLetterDatabase struct {
+	records *recordFile[DeadLetter]
+}
+
+func NewFileDeadLetterDatabase(path string) DeadLetterDatabaser {
+	return &FileDeadLetterDatabase{
+		records: newRecordFile(path, "dead-letters", func(deadLetter DeadLetter) string {
+			return deadLetter.Id
+		}),
+	}
+}
+
+func (db *FileDeadLetterDatabase) StoreDeadLetter(deadLetter DeadLetter) error {
+	return db.records.store(deadLetter)
+}
+
+func (db *FileDeadLetterDatabase) FindDeadLetters(filter FilterDeadLetter) (DeadLetters, error) {
+	return db.records.find(filter.match)
+}
+
+func (db *FileDeadLetterDatabase) DeleteDeadLetters(filter FilterDeadLetter) error {
+	return db.records.delete(filter.match)
+}
+
+// Close closes the file of the dead letters
+func (db *FileDeadLetterDatabase) Close() error {
+	return db.records.close()
+}
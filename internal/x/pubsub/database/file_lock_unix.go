@@ -0,0 +1,21 @@
+//go:build !windows
+
+package database
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile takes the exclusive lock of the file without waiting, the lock is released when
+// the file is closed or the process exits
+func lockFile(file *os.File) error {
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return ErrDatabaseIsLocked
+		}
+		return err
+	}
+	return nil
+}
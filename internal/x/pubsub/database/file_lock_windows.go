@@ -0,0 +1,10 @@
+//go:build windows
+
+package database
+
+import "os"
+
+// lockFile doesn't lock on windows, the writers of the directory are only serialized in the process
+func lockFile(file *os.File) error {
+	return nil
+}
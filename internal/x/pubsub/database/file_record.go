@@ -0,0 +1,271 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// recordFileExtension isn't the extension of the segments, the record files aren't read as topics
+const recordFileExtension = ".records"
+
+// fileEntry is a line of the record file
+type fileEntry[T any] struct {
+	Operation fileOperation `json:"op"`
+	Record    *T            `json:"record,omitempty"`
+	Ids       []string      `json:"ids,omitempty"`
+}
+
+// recordFile keeps the records with an id in a file of the directory, the stored records and the
+// deletions are appended and fsync'd, the file is rewritten when most of the records are deleted
+type recordFile[T any] struct {
+	dir  string
+	name string
+	path string
+	id   func(T) string
+
+	mtx     sync.Mutex
+	lock    *os.File
+	loaded  bool
+	closed  bool
+	file    *os.File
+	records []T
+	lines   int
+}
+
+func newRecordFile[T any](dir, name string, id func(T) string) *recordFile[T] {
+	return &recordFile[T]{
+		dir:  dir,
+		name: name,
+		path: filepath.Join(dir, name+recordFileExtension),
+		id:   id,
+	}
+}
+
+// load locks the file and reads the records once, the torn record of a crash is truncated
+func (r *recordFile[T]) load() error {
+	if r.closed {
+		return ErrDatabaseIsClosed
+	}
+	if r.loaded {
+		return nil
+	}
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+	if r.lock == nil {
+		lock, err := openLock(r.dir, r.name)
+		if err != nil {
+			return err
+		}
+		r.lock = lock
+	}
+	file, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	var (
+		offset int64
+		reader = bufio.NewReader(file)
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		var entry fileEntry[T]
+		if err := json.Unmarshal(line, &entry); err != nil {
+			break
+		}
+		r.apply(entry)
+		offset += int64(len(line))
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.loaded = true
+	return nil
+}
+
+func (r *recordFile[T]) apply(entry fileEntry[T]) {
+	r.lines++
+	switch entry.Operation {
+	case fileOperationStore:
+		if entry.Record != nil {
+			r.records = append(r.records, *entry.Record)
+		}
+	case fileOperationDelete:
+		deleted := make(map[string]bool, len(entry.Ids))
+		for _, id := range entry.Ids {
+			deleted[id] = true
+		}
+		records := make([]T, 0, len(r.records))
+		for _, record := range r.records {
+			if !deleted[r.id(record)] {
+				records = append(records, record)
+			}
+		}
+		r.records = records
+	}
+}
+
+// append writes the entry and waits until it's on the disk
+func (r *recordFile[T]) append(entry fileEntry[T]) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := r.file.Sync(); err != nil {
+		return err
+	}
+
+	r.apply(entry)
+	return nil
+}
+
+func (r *recordFile[T]) store(record T) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+	return r.append(fileEntry[T]{
+		Operation: fileOperationStore,
+		Record:    &record,
+	})
+}
+
+// find returns the matching records in the stored order
+func (r *recordFile[T]) find(match func(T) bool) ([]T, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	records := []T{}
+	for _, record := range r.records {
+		if match(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *recordFile[T]) delete(match func(T) bool) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	ids := []string{}
+	for _, record := range r.records {
+		if match(record) {
+			ids = append(ids, r.id(record))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.append(fileEntry[T]{
+		Operation: fileOperationDelete,
+		Ids:       ids,
+	}); err != nil {
+		return err
+	}
+	if r.lines >= compactRecords && r.lines > 2*len(r.records) {
+		return r.compact()
+	}
+	return nil
+}
+
+func (r *recordFile[T]) close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var errs []error
+	if r.loaded {
+		errs = append(errs, r.file.Close())
+	}
+	if r.lock != nil {
+		errs = append(errs, r.lock.Close())
+		r.lock = nil
+	}
+	r.loaded = false
+	r.closed = true
+	r.records = nil
+	r.lines = 0
+	return errors.Join(errs...)
+}
+
+// compact rewrites the file with the stored records only, the file is
+// replaced after the new one is on the disk
+func (r *recordFile[T]) compact() error {
+	var buf bytes.Buffer
+	for i := range r.records {
+		line, err := json.Marshal(fileEntry[T]{
+			Operation: fileOperationStore,
+			Record:    &r.records[i],
+		})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp, err := os.OpenFile(r.path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(r.path+".tmp", r.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	if dir, err := os.Open(r.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	r.file.Close()
+	r.file = tmp
+	r.lines = len(r.records)
+	_, err = r.file.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,42 @@
+package database
+
+import "sort"
+
+// FileScheduleDatabase keeps the scheduled messages in a file next to the segments of the write-ahead log,
+// it's used when the pubsub has no clover database
+type FileScheduleDatabase struct {
+	records *recordFile[Schedule]
+}
+
+func NewFileScheduleDatabase(path string) ScheduleDatabaser {
+	return &FileScheduleDatabase{
+		records: newRecordFile(path, "schedules", func(schedule Schedule) string {
+			return schedule.Id
+		}),
+	}
+}
+
+func (db *FileScheduleDatabase) StoreSchedule(schedule Schedule) error {
+	return db.records.store(schedule)
+}
+
+func (db *FileScheduleDatabase) FindSchedules(filter FilterSchedule) (Schedules, error) {
+	schedules, err := db.records.find(filter.match)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].DeliverAt.Before(schedules[j].DeliverAt)
+	})
+	return schedules, nil
+}
+
+func (db *FileScheduleDatabase) DeleteSchedules(filter FilterSchedule) error {
+	return db.records.delete(filter.match)
+}
+
+// Close closes the file of the schedules
+func (db *FileScheduleDatabase) Close() error {
+	return db.records.close()
+}
@@ -0,0 +1,214 @@
+package database_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func messages(t *testing.T, db database.Databaser, topic string) []string {
+	t.Helper()
+	backups, err := db.Retrieve(topic)
+	require.NoError(t, err)
+
+	messages := []string{}
+	for i, backup := range backups {
+		if i > 0 {
+			assert.Less(t, backups[i-1].SequenceId, backup.SequenceId)
+		}
+		messages = append(messages, string(backup.Message))
+	}
+	return messages
+}
+
+func store(t *testing.T, db database.Databaser, topic string, values ...string) database.Backups {
+	t.Helper()
+	backups := database.Backups{}
+	for _, value := range values {
+		backup, err := db.Store(database.Backup{Topic: topic, Message: []byte(value)})
+		require.NoError(t, err)
+		backups = append(backups, backup)
+	}
+	return backups
+}
+
+func TestFileDatabase(t *testing.T) {
+	dir := t.TempDir()
+	db := database.NewFileDatabase(dir)
+
+	backups := store(t, db, "pubsub:distribute-config", "1", "2", "3")
+	store(t, db, "pubsub:webhook-event", "event")
+	require.NoError(t, db.Delete("pubsub:distribute-config", backups[1].SequenceId))
+
+	assert.Equal(t, []string{"1", "3"}, messages(t, db, "pubsub:distribute-config"))
+	assert.Equal(t, []string{"event"}, messages(t, db, "pubsub:webhook-event"))
+	assert.Equal(t, []string{}, messages(t, db, "unknown"))
+
+	// the segments are read again after the restart, the sequence continues
+	require.NoError(t, db.(*database.FileDatabase).Close())
+	reopened := database.NewFileDatabase(dir)
+	assert.Equal(t, []string{"1", "3"}, messages(t, reopened, "pubsub:distribute-config"))
+	assert.Equal(t, []string{"event"}, messages(t, reopened, "pubsub:webhook-event"))
+
+	backup := store(t, reopened, "pubsub:distribute-config", "4")[0]
+	assert.Equal(t, int64(5), backup.SequenceId)
+}
+
+func TestFileDatabaseTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	db := database.NewFileDatabase(dir)
+	store(t, db, "topic", "1", "2")
+	require.NoError(t, db.(*database.FileDatabase).Close())
+
+	// the process is killed in the middle of the write
+	file, err := os.OpenFile(filepath.Join(dir, "topic.wal"), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"store","backup":{"sequ`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened := database.NewFileDatabase(dir)
+	assert.Equal(t, []string{"1", "2"}, messages(t, reopened, "topic"))
+
+	// the torn record is truncated, the next record is readable
+	store(t, reopened, "topic", "3")
+	require.NoError(t, reopened.(*database.FileDatabase).Close())
+	assert.Equal(t, []string{"1", "2", "3"}, messages(t, database.NewFileDatabase(dir), "topic"))
+}
+
+func TestFileDatabaseConcurrentStore(t *testing.T) {
+	db := database.NewFileDatabase(t.TempDir())
+
+	wg := sync.WaitGroup{}
+	for writer := 0; writer < 8; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				_, err := db.Store(database.Backup{Topic: "topic", Message: []byte(fmt.Sprintf("%d-%d", writer, i))})
+				assert.NoError(t, err)
+			}
+		}(writer)
+	}
+	wg.Wait()
+
+	backups, err := db.Retrieve("topic")
+	require.NoError(t, err)
+	require.Len(t, backups, 200)
+	for i, backup := range backups {
+		assert.Equal(t, int64(i+1), backup.SequenceId)
+	}
+}
+
+func TestFileDatabaseCompaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "topic.wal")
+	db := database.NewFileDatabase(dir)
+
+	values := make([]string, 600)
+	for i := range values {
+		values[i] = fmt.Sprintf("message-%d", i)
+	}
+	backups := store(t, db, "topic", values...)
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	full := stat.Size()
+
+	// most of the records are deleted, the segment is rewritten with the stored messages
+	for _, backup := range backups[:599] {
+		require.NoError(t, db.Delete("topic", backup.SequenceId))
+	}
+	stat, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, stat.Size(), full/2)
+	assert.Equal(t, []string{"message-599"}, messages(t, database.NewFileDatabaseReader(dir), "topic"))
+
+	// the drained segment is emptied
+	require.NoError(t, db.Delete("topic", backups[599].SequenceId))
+	stat, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, stat.Size())
+}
+
+func TestFileRecords(t *testing.T) {
+	dir := t.TempDir()
+	schedules := database.NewFileScheduleDatabase(dir)
+	deadLetters := database.NewFileDeadLetterDatabase(dir)
+
+	now := time.Now()
+	for i, id := range []string{"1", "2", "3"} {
+		require.NoError(t, schedules.StoreSchedule(database.Schedule{
+			Id:        id,
+			Topic:     "topic",
+			DeliverAt: now.Add(time.Duration(3-i) * time.Minute),
+		}))
+		require.NoError(t, deadLetters.StoreDeadLetter(database.DeadLetter{Id: id, Topic: "topic"}))
+	}
+	require.NoError(t, schedules.DeleteSchedules(database.FilterSchedule{Topic: "topic", Ids: []string{"2"}}))
+	require.NoError(t, deadLetters.DeleteDeadLetters(database.FilterDeadLetter{Topic: "topic", Ids: []string{"2"}}))
+	require.NoError(t, schedules.(*database.FileScheduleDatabase).Close())
+	require.NoError(t, deadLetters.(*database.FileDeadLetterDatabase).Close())
+
+	// the process is killed in the middle of the write
+	file, err := os.OpenFile(filepath.Join(dir, "schedules.records"), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"store","record":{"id`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// the records are read again after the restart, the schedules in the delivery order
+	// and the dead letters in the failed order
+	reopenedSchedules, err := database.NewFileScheduleDatabase(dir).FindSchedules(database.FilterSchedule{Topic: "topic"})
+	require.NoError(t, err)
+	require.Len(t, reopenedSchedules, 2)
+	assert.Equal(t, "3", reopenedSchedules[0].Id)
+	assert.Equal(t, "1", reopenedSchedules[1].Id)
+
+	reopenedDeadLetters, err := database.NewFileDeadLetterDatabase(dir).FindDeadLetters(database.FilterDeadLetter{Topic: "topic"})
+	require.NoError(t, err)
+	require.Len(t, reopenedDeadLetters, 2)
+	assert.Equal(t, "1", reopenedDeadLetters[0].Id)
+	assert.Equal(t, "3", reopenedDeadLetters[1].Id)
+
+	// the record files aren't read as the segments of the write-ahead log
+	assert.Equal(t, []string{}, messages(t, database.NewFileDatabase(dir), "schedules"))
+}
+
+func TestFileDatabaseLock(t *testing.T) {
+	dir := t.TempDir()
+	writer := database.NewFileDatabase(dir)
+	store(t, writer, "topic", "1")
+
+	// the second writer of the directory is kept out until the first one is closed
+	second := database.NewFileDatabase(dir)
+	_, err := second.Store(database.Backup{Topic: "topic", Message: []byte("2")})
+	assert.ErrorIs(t, err, database.ErrDatabaseIsLocked)
+	_, err = second.Retrieve("topic")
+	assert.ErrorIs(t, err, database.ErrDatabaseIsLocked)
+
+	schedules := database.NewFileScheduleDatabase(dir)
+	require.NoError(t, schedules.StoreSchedule(database.Schedule{Id: "1", Topic: "topic"}))
+	err = database.NewFileScheduleDatabase(dir).StoreSchedule(database.Schedule{Id: "2", Topic: "topic"})
+	assert.ErrorIs(t, err, database.ErrDatabaseIsLocked)
+
+	// the reader doesn't take the lock
+	reader := database.NewFileDatabaseReader(dir)
+	assert.Equal(t, []string{"1"}, messages(t, reader, "topic"))
+	_, err = reader.Store(database.Backup{Topic: "topic", Message: []byte("2")})
+	assert.ErrorIs(t, err, database.ErrDatabaseIsReadOnly)
+
+	// the closed writer doesn't take the lock again
+	require.NoError(t, writer.(*database.FileDatabase).Close())
+	_, err = writer.Retrieve("topic")
+	assert.ErrorIs(t, err, database.ErrDatabaseIsClosed)
+	store(t, second, "topic", "2")
+	assert.Equal(t, []string{"1", "2"}, messages(t, second, "topic"))
+}
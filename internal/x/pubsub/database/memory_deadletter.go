@@ -0,0 +1,49 @@
+package database
+
+import "sync"
+
+// MemoryDeadLetterDatabase keeps the dead letters until the process exits,
+// it's used when the pubsub has no database
+type MemoryDeadLetterDatabase struct {
+	mtx         sync.RWMutex
+	deadLetters DeadLetters
+}
+
+func NewMemoryDeadLetterDatabase() DeadLetterDatabaser {
+	return &MemoryDeadLetterDatabase{}
+}
+
+func (db *MemoryDeadLetterDatabase) StoreDeadLetter(deadLetter DeadLetter) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.deadLetters = append(db.deadLetters, deadLetter)
+	return nil
+}
+
+func (db *MemoryDeadLetterDatabase) FindDeadLetters(filter FilterDeadLetter) (DeadLetters, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	deadLetters := DeadLetters{}
+	for _, deadLetter := range db.deadLetters {
+		if filter.match(deadLetter) {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+	return deadLetters, nil
+}
+
+func (db *MemoryDeadLetterDatabase) DeleteDeadLetters(filter FilterDeadLetter) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	deadLetters := DeadLetters{}
+	for _, deadLetter := range db.deadLetters {
+		if !filter.match(deadLetter) {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+	db.deadLetters = deadLetters
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryScheduleDatabase keeps the scheduled messages until the process exits,
+// it's used when the pubsub has no clover database
+type MemoryScheduleDatabase struct {
+	mtx       sync.RWMutex
+	schedules Schedules
+}
+
+func NewMemoryScheduleDatabase() ScheduleDatabaser {
+	return &MemoryScheduleDatabase{}
+}
+
+func (db *MemoryScheduleDatabase) StoreSchedule(schedule Schedule) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.schedules = append(db.schedules, schedule)
+	return nil
+}
+
+func (db *MemoryScheduleDatabase) FindSchedules(filter FilterSchedule) (Schedules, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	schedules := Schedules{}
+	for _, schedule := range db.schedules {
+		if filter.match(schedule) {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].DeliverAt.Before(schedules[j].DeliverAt)
+	})
+	return schedules, nil
+}
+
+func (db *MemoryScheduleDatabase) DeleteSchedules(filter FilterSchedule) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	schedules := Schedules{}
+	for _, schedule := range db.schedules {
+		if !filter.match(schedule) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	db.schedules = schedules
+	return nil
+}
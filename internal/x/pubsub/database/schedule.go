@@ -0,0 +1,63 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schedule is the message that is published at the delivery time
+type Schedule struct {
+	Id          string            `json:"id"`
+	Topic       string            `json:"topic"`
+	Key         string            `json:"key,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	PublishedAt time.Time         `json:"publishedAt"`
+	DeliverAt   time.Time         `json:"deliverAt"`
+	Message     []byte            `json:"message"`
+}
+
+func (s Schedule) MapStringInterface() (map[string]interface{}, error) {
+	mapStringIntf := make(map[string]interface{})
+	j, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(j, &mapStringIntf)
+	if err != nil {
+		return nil, err
+	}
+	return mapStringIntf, nil
+}
+
+type Schedules []Schedule
+
+// FilterSchedule selects the schedules of the topic, every schedule
+// of the topic is selected when the ids are empty
+type FilterSchedule struct {
+	Topic string
+	Ids   []string
+}
+
+func (f FilterSchedule) match(s Schedule) bool {
+	if s.Topic != f.Topic {
+		return false
+	}
+	if len(f.Ids) == 0 {
+		return true
+	}
+	for _, id := range f.Ids {
+		if s.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleDatabaser stores the scheduled messages until they're published,
+// they're returned in the delivery order
+type ScheduleDatabaser interface {
+	StoreSchedule(schedule Schedule) error
+	FindSchedules(filter FilterSchedule) (Schedules, error)
+	DeleteSchedules(filter FilterSchedule) error
+}
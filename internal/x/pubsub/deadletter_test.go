@@ -0,0 +1,219 @@
+package pubsub_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+	"github.com/ostafen/clover"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errHandler = errors.New("err: handler")
+
+// newRetryPubsub registers a topic whose consumer is retried quickly
+func newRetryPubsub(t *testing.T, handler pubsub.SubscriberHandler, opts ...pubsub.PubsubOption) *pubsub.Pubsub {
+	ps := pubsub.NewPubsub(opts...)
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	ps.ConsumerRegister("test-topic-1", handler,
+		pubsub.PubsubSetMaxElapsedTime(100*time.Millisecond),
+		pubsub.PubsubSetRetryWaitTime(5*time.Millisecond))
+	require.NoError(t, ps.Listen())
+	return ps
+}
+
+func waitDeadLetters(t *testing.T, ps *pubsub.Pubsub, expected int) database.DeadLetters {
+	t.Helper()
+	var deadLetters database.DeadLetters
+	require.Eventually(t, func() bool {
+		var err error
+		deadLetters, err = ps.DeadLetters("test-topic-1")
+		require.NoError(t, err)
+		return len(deadLetters) == expected
+	}, 5*time.Second, 5*time.Millisecond)
+	return deadLetters
+}
+
+func TestRetry(t *testing.T) {
+	testCases := []struct {
+		name string
+		// fail tells whether the attempt fails
+		fail             func(attempt int32) error
+		expectedAttempts int32
+		deadLetter       bool
+	}{
+		{
+			name: "succeed after the retries",
+			fail: func(attempt int32) error {
+				if attempt < 3 {
+					return errHandler
+				}
+				return nil
+			},
+			expectedAttempts: 3,
+		},
+		{
+			name: "exhaust the retries",
+			fail: func(attempt int32) error {
+				return errHandler
+			},
+			deadLetter: true,
+		},
+		{
+			name: "permanent error is not retried",
+			fail: func(attempt int32) error {
+				return pubsub.Permanent(errHandler)
+			},
+			expectedAttempts: 1,
+			deadLetter:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ps := newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				// every attempt reads the whole message
+				assert.Equal(t, "hello world", string(data))
+
+				return tc.fail(attempts.Add(1))
+			})
+
+			require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("hello world")))
+
+			if !tc.deadLetter {
+				require.Eventually(t, func() bool {
+					return attempts.Load() == tc.expectedAttempts
+				}, 5*time.Second, 5*time.Millisecond)
+				time.Sleep(50 * time.Millisecond)
+				assert.Equal(t, tc.expectedAttempts, attempts.Load())
+
+				deadLetters, err := ps.DeadLetters("test-topic-1")
+				require.NoError(t, err)
+				assert.Empty(t, deadLetters)
+				return
+			}
+
+			deadLetters := waitDeadLetters(t, ps, 1)
+			assert.Equal(t, "test-topic-1", deadLetters[0].Topic)
+			assert.Equal(t, "hello world", string(deadLetters[0].Message))
+			assert.Contains(t, deadLetters[0].Error, errHandler.Error())
+			assert.Equal(t, int(attempts.Load()), deadLetters[0].Attempts)
+			if tc.expectedAttempts > 0 {
+				assert.Equal(t, tc.expectedAttempts, attempts.Load())
+			} else {
+				assert.Greater(t, attempts.Load(), int32(1))
+			}
+		})
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	cloverDB, err := clover.Open(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { cloverDB.Close() })
+
+	testCases := []struct {
+		name string
+		opts []pubsub.PubsubOption
+	}{
+		{
+			name: "memory",
+		},
+		{
+			name: "clover",
+			opts: []pubsub.PubsubOption{pubsub.SetCloverForBackup(cloverDB)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var healthy atomic.Bool
+			received := make(chan string, 4)
+			ps := newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+				if !healthy.Load() {
+					return pubsub.Permanent(errHandler)
+				}
+				data, _ := io.ReadAll(r)
+				received <- string(data)
+				return nil
+			}, tc.opts...)
+
+			for i, message := range []string{"first", "second", "third"} {
+				require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(message)))
+				waitDeadLetters(t, ps, i+1)
+			}
+			deadLetters := waitDeadLetters(t, ps, 3)
+			assert.Equal(t, "first", string(deadLetters[0].Message))
+			assert.Equal(t, "third", string(deadLetters[2].Message))
+
+			_, err := ps.DeadLetters("unknown")
+			assert.ErrorIs(t, err, pubsub.ErrTopicIsNotExists)
+			_, err = ps.PurgeDeadLetters("test-topic-1", "unknown")
+			assert.ErrorIs(t, err, pubsub.ErrDeadLetterNotFound)
+
+			purged, err := ps.PurgeDeadLetters("test-topic-1", deadLetters[1].Id)
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+			waitDeadLetters(t, ps, 2)
+
+			// the replayed messages are dispatched in the failed order
+			healthy.Store(true)
+			replayed, err := ps.ReplayDeadLetters("test-topic-1")
+			require.NoError(t, err)
+			assert.Equal(t, 2, replayed)
+			assert.Equal(t, "first", waitReceived(t, received))
+			assert.Equal(t, "third", waitReceived(t, received))
+			waitDeadLetters(t, ps, 0)
+
+			purged, err = ps.PurgeDeadLetters("test-topic-1")
+			require.NoError(t, err)
+			assert.Equal(t, 0, purged)
+		})
+	}
+}
+
+func TestDeadLetterRecovery(t *testing.T) {
+	for _, driver := range backupDrivers {
+		t.Run(driver.name, func(t *testing.T) {
+			opt, _, crash := driver.open(t, t.TempDir())
+			ps := newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+				return pubsub.Permanent(errHandler)
+			}, opt)
+			require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("1")))
+			waitDeadLetters(t, ps, 1)
+
+			// the dead letter is kept after the restart and replayed to the new subscriber
+			opt, _, _ = driver.open(t, crash())
+			received := make(chan string, 5)
+			ps = newRetryPubsub(t, receiver(received), opt)
+			deadLetters := waitDeadLetters(t, ps, 1)
+			assert.Equal(t, "1", string(deadLetters[0].Message))
+
+			replayed, err := ps.ReplayDeadLetters("test-topic-1")
+			require.NoError(t, err)
+			assert.Equal(t, 1, replayed)
+			assert.Equal(t, "1", waitReceived(t, received))
+			waitDeadLetters(t, ps, 0)
+		})
+	}
+}
+
+func waitReceived(t *testing.T, received <-chan string) string {
+	t.Helper()
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the message")
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package pubsub
+
+import (
+	"hash/fnv"
+)
+
+// PubsubSetGroup puts the subscriber into the consumer group, every message of the topic goes
+// to one member of the group while the other groups and the subscribers without group receive
+// their own copy. The members join and leave by ConsumerRegister and Unsubscribe
+func PubsubSetGroup(group string) SubscriberOption {
+	return func(ps *subscriber) {
+		ps.group = group
+	}
+}
+
+// receivers returns the subscribers that receive the message, every subscriber without group
+// and one member of every group. The keyed message goes to the member of its key, so the messages
+// of a key are handled in order while the members don't change, the other messages are handed
+// over round robin. It's only called by the dispatcher of the topic
+func (p *publisher) receivers(subscribers []*subscriber, key string) []*subscriber {
+	receivers := make([]*subscriber, 0, len(subscribers))
+	groups := make(map[string][]*subscriber)
+	order := []string{}
+	for _, subscriber := range subscribers {
+		if subscriber.group == "" {
+			receivers = append(receivers, subscriber)
+			continue
+		}
+		// the leaving member doesn't receive the message of its group
+		if subscriber.isUnsubscribed() {
+			continue
+		}
+		if _, exists := groups[subscriber.group]; !exists {
+			order = append(order, subscriber.group)
+		}
+		groups[subscriber.group] = append(groups[subscriber.group], subscriber)
+	}
+
+	for _, group := range order {
+		members := groups[group]
+		if key != "" {
+			hash := fnv.New32a()
+			hash.Write([]byte(key))
+			receivers = append(receivers, members[hash.Sum32()%uint32(len(members))])
+			continue
+		}
+
+		cursor := p.cursors[group] % len(members)
+		p.cursors[group] = cursor + 1
+		receivers = append(receivers, members[cursor])
+	}
+	return receivers
+}
@@ -0,0 +1,129 @@
+package pubsub_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// groupReceiver records the messages of the members
+type groupReceiver struct {
+	mtx      sync.Mutex
+	received map[string][]string
+}
+
+func (g *groupReceiver) handler(member string) pubsub.SubscriberHandler {
+	return func(ctx context.Context, id string, r io.Reader) error {
+		data, _ := io.ReadAll(r)
+		g.mtx.Lock()
+		defer g.mtx.Unlock()
+		g.received[member] = append(g.received[member], string(data))
+		return nil
+	}
+}
+
+// wait returns the received messages of the members after the count is received
+func (g *groupReceiver) wait(t *testing.T, count int) map[string][]string {
+	t.Helper()
+	received := map[string][]string{}
+	require.Eventually(t, func() bool {
+		g.mtx.Lock()
+		defer g.mtx.Unlock()
+		total := 0
+		for member, messages := range g.received {
+			received[member] = append([]string{}, messages...)
+			total += len(messages)
+		}
+		return total == count
+	}, 5*time.Second, 5*time.Millisecond)
+	return received
+}
+
+func TestConsumerGroup(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+	require.NoError(t, ps.Listen())
+
+	receiver := &groupReceiver{received: map[string][]string{}}
+	for _, member := range []string{"worker-1", "worker-2"} {
+		require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver.handler(member),
+			pubsub.PubsubSetSubscriberId(member), pubsub.PubsubSetGroup("workers")))
+	}
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver.handler("audit"),
+		pubsub.PubsubSetSubscriberId("audit")))
+
+	groups, err := ps.Groups("test-topic-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"workers": {"worker-1", "worker-2"}}, groups)
+
+	// every message goes to one worker, the subscriber without group receives every message
+	for i := 0; i < 4; i++ {
+		require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(fmt.Sprint(i))))
+	}
+	received := receiver.wait(t, 8)
+	assert.Equal(t, []string{"0", "1", "2", "3"}, received["audit"])
+	assert.ElementsMatch(t, []string{"0", "1", "2", "3"}, append(received["worker-1"], received["worker-2"]...))
+	assert.Len(t, received["worker-1"], 2)
+	assert.Len(t, received["worker-2"], 2)
+
+	// the messages of a key go to the same worker
+	for i := 0; i < 4; i++ {
+		require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+			Key:  "key",
+			Body: []byte(fmt.Sprint("keyed-", i)),
+		}))
+	}
+	received = receiver.wait(t, 16)
+	keyed := 0
+	for _, member := range []string{"worker-1", "worker-2"} {
+		if len(received[member]) > 2 {
+			assert.Equal(t, []string{"keyed-0", "keyed-1", "keyed-2", "keyed-3"}, received[member][2:])
+			keyed++
+		}
+	}
+	assert.Equal(t, 1, keyed)
+
+	// the remaining member receives every message of the group after the other leaves
+	require.NoError(t, ps.Unsubscribe("test-topic-1", "worker-1"))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(fmt.Sprint("left-", i))))
+	}
+	received = receiver.wait(t, 20)
+	assert.Equal(t, []string{"left-0", "left-1"}, received["worker-2"][len(received["worker-2"])-2:])
+
+	groups, err = ps.Groups("test-topic-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"workers": {"worker-2"}}, groups)
+
+	_, err = ps.Groups("test-topic-2")
+	assert.ErrorIs(t, err, pubsub.ErrTopicIsNotExists)
+}
+
+func TestConsumerGroupWriteAheadLog(t *testing.T) {
+	dir := t.TempDir()
+	read := func() database.Databaser { return database.NewFileDatabaseReader(dir) }
+
+	ps := pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+	require.NoError(t, ps.Listen())
+
+	// the message is acknowledged by one member of the group
+	receiver := &groupReceiver{received: map[string][]string{}}
+	for _, member := range []string{"worker-1", "worker-2"} {
+		require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver.handler(member),
+			pubsub.PubsubSetSubscriberId(member), pubsub.PubsubSetGroup("workers")))
+	}
+	for _, message := range []string{"1", "2"} {
+		require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(message)))
+	}
+	receiver.wait(t, 2)
+	waitWriteAheadLog(t, read)
+}
@@ -0,0 +1,260 @@
+package pubsub_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver sends the received messages to the channel
+func receiver(received chan<- string) pubsub.SubscriberHandler {
+	return func(ctx context.Context, id string, r io.Reader) error {
+		data, _ := io.ReadAll(r)
+		received <- string(data)
+		return nil
+	}
+}
+
+func TestLateSubscriber(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	ps.TopicRegister("test-topic-2", pubsub.PubsubSetMaxBufferCapacity(5))
+
+	// the topics without subscriber don't fail the listening
+	require.NoError(t, ps.Listen())
+
+	err := ps.Publish("test-topic-1", pubsub.SendString("queued"))
+	assert.ErrorIs(t, err, pubsub.ErrConsumerIsNotExists)
+	assert.Equal(t, 1, ps.Len("test-topic-1"))
+
+	// the subscriber that is registered after the listening receives right away
+	received := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+	assert.Equal(t, "queued", waitReceived(t, received))
+
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("published")))
+	assert.Equal(t, "published", waitReceived(t, received))
+
+	err = ps.ConsumerRegister("test-topic-3", receiver(received))
+	assert.ErrorIs(t, err, pubsub.ErrTopicIsNotExists)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	require.NoError(t, ps.Listen())
+
+	first, second := make(chan string, 5), make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(first), pubsub.PubsubSetSubscriberId("first")))
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(second), pubsub.PubsubSetSubscriberId("second")))
+
+	ids, err := ps.Subscribers("test-topic-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, ids)
+
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("1")))
+	assert.Equal(t, "1", waitReceived(t, first))
+	assert.Equal(t, "1", waitReceived(t, second))
+
+	require.NoError(t, ps.Unsubscribe("test-topic-1", "first"))
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("2")))
+	assert.Equal(t, "2", waitReceived(t, second))
+	select {
+	case message := <-first:
+		t.Fatalf("the unsubscribed subscriber receives %s", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	testCases := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{
+			name: "duplicate subscriber",
+			call: func() error {
+				return ps.ConsumerRegister("test-topic-1", receiver(second), pubsub.PubsubSetSubscriberId("second"))
+			},
+			expected: pubsub.ErrSubscriberIsExists,
+		},
+		{
+			name: "unknown subscriber",
+			call: func() error {
+				return ps.Unsubscribe("test-topic-1", "first")
+			},
+			expected: pubsub.ErrSubscriberIsNotExists,
+		},
+		{
+			name: "unknown topic",
+			call: func() error {
+				return ps.Unsubscribe("test-topic-2", "second")
+			},
+			expected: pubsub.ErrTopicIsNotExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.call(), tc.expected)
+		})
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	dir := t.TempDir()
+	read := func() database.Databaser { return database.NewFileDatabaseReader(dir) }
+
+	ps := pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	require.NoError(t, ps.Listen())
+
+	// the slow subscriber doesn't hold the other one while its queue has room
+	slow := make(chan struct{})
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+		<-slow
+		return nil
+	}, pubsub.PubsubSetSubscriberId("slow")))
+	received := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received), pubsub.PubsubSetSubscriberId("fast")))
+
+	for _, message := range []string{"1", "2", "3"} {
+		require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+			Key:  "client-1",
+			Body: []byte(message),
+		}))
+	}
+	for _, expected := range []string{"1", "2", "3"} {
+		assert.Equal(t, expected, waitReceived(t, received))
+	}
+	waitWriteAheadLog(t, read, "1", "2", "3")
+
+	// the queued messages of the unsubscribed subscriber are skipped
+	require.NoError(t, ps.Unsubscribe("test-topic-1", "slow"))
+	close(slow)
+	waitWriteAheadLog(t, read)
+}
+
+func TestTopicDelete(t *testing.T) {
+	dir := t.TempDir()
+	read := func() database.Databaser { return database.NewFileDatabaseReader(dir) }
+
+	ps := pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	require.NoError(t, ps.Listen())
+	for _, message := range []string{"1", "2"} {
+		assert.ErrorIs(t, ps.Publish("test-topic-1", pubsub.SendString(message)), pubsub.ErrConsumerIsNotExists)
+	}
+	waitWriteAheadLog(t, read, "1", "2")
+
+	// the queued messages are discarded with the topic
+	require.NoError(t, ps.TopicDelete("test-topic-1"))
+	waitWriteAheadLog(t, read)
+	assert.ErrorIs(t, ps.Publish("test-topic-1", pubsub.SendString("3")), pubsub.ErrTopicIsNotExists)
+	assert.ErrorIs(t, ps.TopicDelete("test-topic-1"), pubsub.ErrTopicIsNotExists)
+	assert.Empty(t, ps.Topics())
+
+	// the topic is registered again without the discarded messages
+	received := make(chan string, 5)
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("4")))
+	assert.Equal(t, "4", waitReceived(t, received))
+}
+
+// TestLifecycleConcurrency registers, publishes, unsubscribes and deletes the topics
+// concurrently, it's meant to run with the race detector
+func TestLifecycleConcurrency(t *testing.T) {
+	const (
+		topics  = 4
+		workers = 8
+	)
+
+	ps := pubsub.NewPubsub()
+	require.NoError(t, ps.Listen())
+
+	var (
+		wg       sync.WaitGroup
+		received atomic.Int64
+		stop     = make(chan struct{})
+	)
+	topic := func() string {
+		return fmt.Sprintf("test-topic-%d", rand.Intn(topics))
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for iteration := 0; ; iteration++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				switch rand.Intn(6) {
+				case 0:
+					ps.TopicRegister(topic(), pubsub.PubsubSetMaxBufferCapacity(100))
+				case 1:
+					ps.ConsumerRegister(topic(), func(ctx context.Context, id string, r io.Reader) error {
+						received.Add(1)
+						return nil
+					}, pubsub.PubsubSetSubscriberId(fmt.Sprintf("%d-%d", worker, iteration)),
+						pubsub.PubsubSetMaxWorker(2),
+						pubsub.PubsubSetAsyncProcess(iteration%2 == 0))
+				case 2:
+					topic := topic()
+					if ids, err := ps.Subscribers(topic); err == nil && len(ids) > 0 {
+						ps.Unsubscribe(topic, ids[rand.Intn(len(ids))])
+					}
+				case 3:
+					if rand.Intn(10) == 0 {
+						ps.TopicDelete(topic())
+					}
+				default:
+					ps.PublishMessage(topic(), pubsub.Message{
+						Key:  fmt.Sprintf("key-%d", rand.Intn(3)),
+						Body: []byte("message"),
+					})
+					ps.Stats(topic())
+				}
+			}
+		}(i)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	close(stop)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// the publishers that wait for a full topic are released by the deletion
+	for waiting := true; waiting; {
+		for _, topic := range ps.Topics() {
+			ps.TopicDelete(topic)
+		}
+		select {
+		case <-done:
+			waiting = false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	for _, topic := range ps.Topics() {
+		require.NoError(t, ps.TopicDelete(topic))
+	}
+	assert.Empty(t, ps.Topics())
+	assert.Positive(t, received.Load())
+}
@@ -5,8 +5,45 @@ import (
 	"encoding/json"
 	"io"
 	"strings"
+	"time"
 )
 
+// Headers are the metadata of the message
+type Headers map[string]string
+
+const (
+	HeaderCorrelationId = "Correlation-Id"
+	HeaderOriginUser    = "Origin-User"
+)
+
+// Message is the published message. The messages with the same key are processed in the
+// published order by every subscriber, the message without key has no order. The id and
+// the publish time are set by the pubsub when they're empty, the topic is always set
+type Message struct {
+	Id          string
+	Topic       string
+	Key         string
+	Headers     Headers
+	PublishedAt time.Time
+	Body        []byte
+}
+
+// messageReader is the reader of the body that is passed to the handler
+type messageReader struct {
+	*bytes.Reader
+	message Message
+}
+
+// ReadMessage returns the key, the headers and the publish time of the message
+// from the reader that is passed to the handler
+func ReadMessage(r io.Reader) (Message, bool) {
+	reader, ok := r.(*messageReader)
+	if !ok {
+		return Message{}, false
+	}
+	return reader.message, true
+}
+
 type MessageHandler func() (io.Reader, error)
 
 func SendString(data string) MessageHandler {
@@ -0,0 +1,199 @@
+package pubsub_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageOrder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		workers int
+		async   bool
+	}{
+		{
+			name:    "sync workers",
+			workers: 4,
+		},
+		{
+			name:    "more workers than partitions",
+			workers: 10000,
+		},
+		{
+			name:    "async",
+			workers: 4,
+			async:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			const (
+				keys     = 4
+				messages = 50
+			)
+
+			var (
+				mtx      sync.Mutex
+				received = make(map[string][]int)
+				consumed atomic.Int32
+				inFlight atomic.Int32
+				parallel atomic.Bool
+			)
+			ps := pubsub.NewPubsub()
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(keys*messages))
+			ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+				if inFlight.Add(1) > 1 {
+					parallel.Store(true)
+				}
+				defer inFlight.Add(-1)
+
+				message, ok := pubsub.ReadMessage(r)
+				require.True(t, ok)
+				time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+
+				sequence, err := strconv.Atoi(string(message.Body))
+				require.NoError(t, err)
+
+				mtx.Lock()
+				received[message.Key] = append(received[message.Key], sequence)
+				mtx.Unlock()
+				consumed.Add(1)
+				return nil
+			}, pubsub.PubsubSetMaxWorker(tc.workers), pubsub.PubsubSetAsyncProcess(tc.async))
+			require.NoError(t, ps.Listen())
+
+			for i := 0; i < messages; i++ {
+				for key := 0; key < keys; key++ {
+					require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+						Key:  fmt.Sprintf("client-%d", key),
+						Body: []byte(strconv.Itoa(i)),
+					}))
+				}
+			}
+
+			require.Eventually(t, func() bool {
+				return consumed.Load() == keys*messages
+			}, 5*time.Second, 5*time.Millisecond)
+
+			// the keys are consumed in parallel, every key in the published order
+			assert.True(t, parallel.Load())
+			expected := make([]int, messages)
+			for i := range expected {
+				expected[i] = i
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			for key := 0; key < keys; key++ {
+				assert.Equal(t, expected, received[fmt.Sprintf("client-%d", key)])
+			}
+		})
+	}
+}
+
+func TestPublishMessage(t *testing.T) {
+	received := make(chan pubsub.Message, 10)
+	ids := make(chan string, 10)
+	var attempts atomic.Int32
+	ps := newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+		ids <- id
+		if attempts.Add(1) == 1 {
+			return errHandler
+		}
+
+		message, ok := pubsub.ReadMessage(r)
+		require.True(t, ok)
+		// the handler still reads the body from the reader
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, message.Body, body)
+
+		received <- message
+		return nil
+	})
+
+	publishedAt := time.Now()
+	require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+		Key: "client-1",
+		Headers: pubsub.Headers{
+			pubsub.HeaderCorrelationId: "correlation-1",
+			pubsub.HeaderOriginUser:    "user-1",
+		},
+		Body: []byte("message-1"),
+	}))
+
+	var message pubsub.Message
+	select {
+	case message = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message is not received")
+	}
+
+	assert.NotEmpty(t, message.Id)
+	assert.Equal(t, "client-1", message.Key)
+	assert.Equal(t, "correlation-1", message.Headers[pubsub.HeaderCorrelationId])
+	assert.Equal(t, "user-1", message.Headers[pubsub.HeaderOriginUser])
+	assert.False(t, message.PublishedAt.Before(publishedAt))
+
+	// the retry has the id of the message
+	assert.Equal(t, message.Id, <-ids)
+	assert.Equal(t, message.Id, <-ids)
+
+	_, ok := pubsub.ReadMessage(strings.NewReader("message-1"))
+	assert.False(t, ok)
+}
+
+func TestMessageRecovery(t *testing.T) {
+	for _, driver := range backupDrivers {
+		t.Run(driver.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// the message isn't consumed before the crash
+			opt, read, crash := driver.open(t, dir)
+			ps := pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+			err := ps.PublishMessage("test-topic-1", pubsub.Message{
+				Id:      "message-1",
+				Key:     "client-1",
+				Headers: pubsub.Headers{pubsub.HeaderCorrelationId: "correlation-1"},
+				Body:    []byte("1"),
+			})
+			assert.ErrorIs(t, err, pubsub.ErrConsumerIsNotExists)
+			waitWriteAheadLog(t, read, "1")
+
+			opt, _, _ = driver.open(t, crash())
+			received := make(chan pubsub.Message, 10)
+			ps = pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+			ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+				message, _ := pubsub.ReadMessage(r)
+				received <- message
+				return nil
+			})
+			require.NoError(t, ps.Listen())
+
+			select {
+			case message := <-received:
+				assert.Equal(t, "message-1", message.Id)
+				assert.Equal(t, "client-1", message.Key)
+				assert.Equal(t, "correlation-1", message.Headers[pubsub.HeaderCorrelationId])
+				assert.False(t, message.PublishedAt.IsZero())
+				assert.Equal(t, []byte("1"), message.Body)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the message is not recovered")
+			}
+		})
+	}
+}
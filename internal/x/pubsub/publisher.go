@@ -1,12 +1,63 @@
 package pubsub
 
 import (
-	"io"
-	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
 )
 
+// envelope is the queued message, the sequence id is its entry in the write-ahead log,
+// it's zero when the pubsub has no database
+type envelope struct {
+	sequenceId int64
+	message    Message
+	// subscriberId is the subscriber of the replayed dead letter, the message goes to
+	// every subscriber when it's empty or the subscriber is gone
+	subscriberId string
+}
+
+// newEnvelope reads the message of the write-ahead log entry
+func newEnvelope(topic string, backup database.Backup) envelope {
+	return envelope{
+		sequenceId:   backup.SequenceId,
+		subscriberId: backup.SubscriberId,
+		message: Message{
+			Id:          backup.MessageId,
+			Topic:       topic,
+			Key:         backup.Key,
+			Headers:     backup.Headers,
+			PublishedAt: backup.PublishedAt,
+			Body:        backup.Message,
+		},
+	}
+}
+
 type publisher struct {
-	message chan io.Reader
+	// mtx keeps the order of the queue the same as the write-ahead log
+	mtx     sync.Mutex
+	message chan envelope
+	stats   *topicStats
+	// spilled is the number of the messages that are only in the write-ahead log because
+	// the queue was full, the later messages are spilled too until the dispatcher reads
+	// them from the log after the queued ones. lastQueued is the sequence id of the last
+	// message that is handed over to the dispatcher, they're guarded by mtx
+	spilled    atomic.Int64
+	lastQueued int64
+	// spill wakes up the dispatcher when a message is spilled
+	spill chan struct{}
+	// recovered is closed after the unacknowledged messages are queued again,
+	// the new messages are queued after them
+	recovered     chan struct{}
+	recoveredOnce sync.Once
+	// subscribed is closed when a subscriber is registered, it's replaced by the pubsub
+	// under its lock. The dispatcher waits on it while the topic has no subscriber
+	subscribed chan struct{}
+	// deleted is closed when the topic is deleted
+	deleted    chan struct{}
+	deleteOnce sync.Once
+	// cursors are the round robin of the consumer groups, they're only used by the dispatcher
+	cursors map[string]int
 }
 
 type publisherOptions struct {
@@ -15,39 +66,106 @@ type publisherOptions struct {
 
 func newPublisher(options publisherOptions) *publisher {
 	pub := &publisher{
-		message: make(chan io.Reader, options.bufferCapacity),
+		message:    make(chan envelope, options.bufferCapacity),
+		stats:      newTopicStats(),
+		recovered:  make(chan struct{}),
+		subscribed: make(chan struct{}),
+		deleted:    make(chan struct{}),
+		spill:      make(chan struct{}, 1),
+		cursors:    make(map[string]int),
 	}
 
 	return pub
 }
 
-func (p *publisher) publish(message MessageHandler) error {
-	data, err := message()
-	if err != nil {
-		return err
+// queue queues the message of the write-ahead log without waiting, the message is spilled when the
+// queue is full or other messages are spilled before it. The caller holds the lock
+func (p *publisher) queue(message envelope) {
+	if p.spilled.Load() == 0 {
+		select {
+		case p.message <- message:
+			p.lastQueued = message.sequenceId
+			return
+		default:
+		}
 	}
 
-	p.message <- data
-	return nil
+	p.spilled.Add(1)
+	select {
+	case p.spill <- struct{}{}:
+	default:
+	}
 }
 
-func (p *publisher) close() {
-	close(p.message)
+// unspill marks the spilled message as handed over to the dispatcher, the caller holds the lock
+func (p *publisher) unspill(sequenceId int64) {
+	p.lastQueued = sequenceId
+	p.spilled.Add(-1)
 }
 
-func (p *publisher) retrieveMessages() ([]string, error) {
-	messages := []string{}
-	for message := range p.message {
-		buf := new(strings.Builder)
-		_, err := io.Copy(buf, message)
-		if err != nil {
-			return nil, err
-		}
+// publish queues the message, it gives up when the topic is deleted or the pubsub is shut down
+func (p *publisher) publish(message envelope, shutdown <-chan bool) bool {
+	select {
+	case p.message <- message:
+		return true
+	case <-p.deleted:
+		return false
+	case <-shutdown:
+		return false
+	}
+}
+
+// tryPublish queues the message when the queue has room
+func (p *publisher) tryPublish(message envelope) bool {
+	if p.isDeleted() {
+		return false
+	}
+	select {
+	case p.message <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *publisher) recover() {
+	p.recoveredOnce.Do(func() {
+		close(p.recovered)
+	})
+}
+
+// subscribe wakes up the dispatcher that waits for a subscriber
+func (p *publisher) subscribe() {
+	close(p.subscribed)
+	p.subscribed = make(chan struct{})
+}
+
+func (p *publisher) delete() {
+	p.deleteOnce.Do(func() {
+		close(p.deleted)
+	})
+}
 
-		messages = append(messages, buf.String())
+func (p *publisher) isDeleted() bool {
+	select {
+	case <-p.deleted:
+		return true
+	default:
+		return false
 	}
+}
 
-	return messages, nil
+// retrieveMessages takes the queued messages out of the queue
+func (p *publisher) retrieveMessages() []envelope {
+	messages := []envelope{}
+	for {
+		select {
+		case message := <-p.message:
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
 }
 
 func (p *publisher) capacity() int {
@@ -55,5 +173,5 @@ func (p *publisher) capacity() int {
 }
 
 func (p *publisher) len() int {
-	return len(p.message)
+	return len(p.message) + int(p.spilled.Load())
 }
@@ -1,27 +1,66 @@
 package pubsub
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"io"
 	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
 	"github.com/ostafen/clover"
 )
 
 var (
-	ErrTopicIsNotExists    error = errors.New("err: topic is not exists")
-	ErrConsumerIsNotExists error = errors.New("err: your message buffer already full but you don't have any consumer yet")
+	ErrTopicIsNotExists      error = errors.New("err: topic is not exists")
+	ErrConsumerIsNotExists   error = errors.New("err: your message buffer already full but you don't have any consumer yet")
+	ErrDeadLetterNotFound    error = errors.New("err: dead letter is not found")
+	ErrSubscriberIsExists    error = errors.New("err: subscriber is already exists")
+	ErrSubscriberIsNotExists error = errors.New("err: subscriber is not exists")
+	ErrPubsubIsShutdown      error = errors.New("err: pubsub is shut down")
+	ErrInvalidTopicPattern   error = errors.New("err: topic pattern is invalid")
+	ErrScheduleIsExists      error = errors.New("err: message is already scheduled")
+	ErrScheduleNotFound      error = errors.New("err: scheduled message is not found")
+	// errTopicIsBusy is returned to the scheduler when the topic can't queue the message right away
+	errTopicIsBusy error = errors.New("err: topic is busy")
 )
 
 type Pubsub struct {
-	shutdown          chan bool
-	database          database.Databaser
+	shutdown     chan bool
+	shutdownOnce *sync.Once
+	database     database.Databaser
+	deadLetter   database.DeadLetterDatabaser
+	schedule     database.ScheduleDatabaser
+	scheduler    *scheduler
+
+	// ctx is the context of the handlers, it's cancelled when the shutdown
+	// stops waiting for the in-flight messages
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mtx guards the topics and the subscribers, they're registered
+	// and removed while the dispatchers run
+	mtx               sync.RWMutex
+	listening         bool
 	publisher         map[string]*publisher
 	subscriber        map[string][]*subscriber
 	subscriberCounter int
+	// patterns are the subscriptions of the topic patterns, they subscribe
+	// to the matched topics, also to the topics that are registered later
+	patterns []*patternSubscription
+}
+
+// patternSubscription subscribes the handler to every topic that matches the pattern,
+// every topic has its own subscriber with the id of the subscription
+type patternSubscription struct {
+	id      string
+	pattern string
+	handler SubscriberHandler
+	opts    []SubscriberOption
 }
 
 type PubsubOption func(pb *Pubsub)
@@ -32,20 +71,44 @@ func SetCloverForBackup(db *clover.DB) PubsubOption {
 			DatabaseDriver: database.CLOVER,
 		}
 		pb.database = database.NewCloverDatabase(db)
+		pb.deadLetter = database.NewCloverDeadLetterDatabase(db)
+		pb.schedule = database.NewCloverScheduleDatabase(db)
+	}
+}
+
+// SetFileForBackup keeps the write-ahead log in the segment files of the directory,
+// the pubsub runs without clover. The dead letters and the scheduled messages are kept in the same directory
+func SetFileForBackup(path string) PubsubOption {
+	return func(pb *Pubsub) {
+		database := database.Database{
+			DatabaseDriver: database.FILE,
+		}
+		pb.database = database.NewFileDatabase(path)
+		pb.deadLetter = database.NewFileDeadLetterDatabase(path)
+		pb.schedule = database.NewFileScheduleDatabase(path)
 	}
 }
 
 func NewPubsub(opts ...PubsubOption) *Pubsub {
+	ctx, cancel := context.WithCancel(context.Background())
 	pubsub := &Pubsub{
-		shutdown:   make(chan bool),
-		publisher:  make(map[string]*publisher),
-		subscriber: make(map[string][]*subscriber),
+		shutdown:     make(chan bool),
+		shutdownOnce: &sync.Once{},
+		ctx:          ctx,
+		cancel:       cancel,
+		publisher:    make(map[string]*publisher),
+		subscriber:   make(map[string][]*subscriber),
+		deadLetter:   database.NewMemoryDeadLetterDatabase(),
+		schedule:     database.NewMemoryScheduleDatabase(),
+		scheduler:    newScheduler(),
 	}
 
 	for _, opt := range opts {
 		opt(pubsub)
 	}
 
+	go pubsub.deliverSchedules()
+
 	return pubsub
 }
 
@@ -61,7 +124,7 @@ func PubsubSetMaxBufferCapacity(max int) PubsubRegisterOpt {
 	}
 }
 
-// register the callback
+// register the callback, the topic that is already registered is kept
 func (ps *Pubsub) TopicRegister(topic string, opts ...PubsubRegisterOpt) {
 	var pubsubRegisterOption PubsubRegisterOptions
 
@@ -69,87 +132,524 @@ func (ps *Pubsub) TopicRegister(topic string, opts ...PubsubRegisterOpt) {
 		opt(&pubsubRegisterOption)
 	}
 
-	ps.publisher[topic] = newPublisher(publisherOptions{
+	ps.mtx.Lock()
+	if _, exists := ps.publisher[topic]; exists {
+		ps.mtx.Unlock()
+		return
+	}
+	pub := newPublisher(publisherOptions{
 		bufferCapacity: pubsubRegisterOption.maxBufferCapacity,
 	})
+	ps.publisher[topic] = pub
+
+	// the patterns that match the new topic subscribe to it
+	for _, pattern := range ps.patterns {
+		if _, matched := MatchTopic(pattern.pattern, topic); !matched {
+			continue
+		}
+		if _, err := ps.subscribe(topic, pub, pattern.handler, pattern.opts...); err != nil {
+			log.Printf("failed to subscribe %s of %s to %s, err: %s\n", pattern.id, pattern.pattern, topic, err)
+		}
+	}
+	ps.mtx.Unlock()
+
+	// recover the messages that aren't acknowledged before the last shutdown or crash,
+	// the channel may be full until the consumers listen
+	go ps.checkBackup(topic, pub)
+	go ps.checkSchedules(topic)
+	go ps.dispatcher(topic, pub)
+}
+
+// TopicDelete removes the topic and its subscribers, the queued messages
+// and the dead letters of the topic are discarded
+func (ps *Pubsub) TopicDelete(topic string) error {
+	ps.mtx.Lock()
+	pub, exists := ps.publisher[topic]
+	if !exists {
+		ps.mtx.Unlock()
+		return ErrTopicIsNotExists
+	}
+	subscribers := ps.subscriber[topic]
+	delete(ps.publisher, topic)
+	delete(ps.subscriber, topic)
+	ps.mtx.Unlock()
+
+	pub.delete()
+	for _, subscriber := range subscribers {
+		subscriber.unsubscribe()
+	}
+
+	// the publishing message is stored before it's discarded
+	pub.mtx.Lock()
+	defer pub.mtx.Unlock()
+	pub.retrieveMessages()
+	pub.spilled.Store(0)
+
+	if ps.database != nil {
+		backups, err := ps.database.Retrieve(topic)
+		if err != nil {
+			return err
+		}
+		sequenceIds := make([]int64, 0, len(backups))
+		for _, backup := range backups {
+			sequenceIds = append(sequenceIds, backup.SequenceId)
+		}
+		if len(sequenceIds) > 0 {
+			if err := ps.database.Delete(topic, sequenceIds...); err != nil {
+				return err
+			}
+		}
+	}
+
+	ps.scheduler.cancelTopic(topic)
+	if err := ps.schedule.DeleteSchedules(database.FilterSchedule{
+		Topic: topic,
+	}); err != nil {
+		return err
+	}
 
-	// check is there backup for this topic
-	go ps.CheckBackup(topic)
+	return ps.deadLetter.DeleteDeadLetters(database.FilterDeadLetter{
+		Topic: topic,
+	})
 }
 
+// ConsumerRegister subscribes the handler to the topic or to the topic pattern, e.g. config.*
+// or app.{id}.>. The pattern subscribes to the registered topics that match it and to the
+// topics that are registered later, the handler reads the topic of the message by ReadMessage
 func (ps *Pubsub) ConsumerRegister(topic string, handler SubscriberHandler, opts ...SubscriberOption) error {
-	defer func() {
-		ps.subscriberCounter++
-	}()
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	if isTopicPattern(topic) {
+		return ps.patternRegister(topic, handler, opts...)
+	}
+
+	pub, exists := ps.publisher[topic]
+	if !exists {
+		return ErrTopicIsNotExists
+	}
+
+	_, err := ps.subscribe(topic, pub, handler, opts...)
+	return err
+}
+
+// subscribe registers the subscriber to the topic, the caller holds the lock
+func (ps *Pubsub) subscribe(topic string, pub *publisher, handler SubscriberHandler, opts ...SubscriberOption) (*subscriber, error) {
+	newSubscriber := newSubscriber(ps.ctx, topic, ps.shutdown, pub.stats, ps.storeDeadLetter)
+	newSubscriber.registerSubscriberHandler(handler, pub.capacity(), opts...)
+	for _, subscriber := range ps.subscriber[topic] {
+		if subscriber.id == newSubscriber.id {
+			return nil, ErrSubscriberIsExists
+		}
+	}
 
-	newSubscriber := newSubscriber()
-	newSubscriber.registerSubscriberHandler(handler, opts...)
 	ps.subscriber[topic] = append(ps.subscriber[topic], newSubscriber)
+	ps.subscriberCounter++
+
+	// the late subscriber receives right away
+	if ps.listening {
+		newSubscriber.listen()
+	}
+	pub.subscribe()
+	return newSubscriber, nil
+}
 
-	go ps.dispatcher(topic)
+// patternRegister subscribes the handler to the topics that match the pattern, the caller holds the lock
+func (ps *Pubsub) patternRegister(pattern string, handler SubscriberHandler, opts ...SubscriberOption) error {
+	if err := validateTopicPattern(pattern); err != nil {
+		return err
+	}
+
+	// every matched topic has a subscriber with the same id
+	probe := newSubscriber(ps.ctx, pattern, ps.shutdown, nil, nil)
+	probe.registerSubscriberHandler(handler, 0, opts...)
+	for _, subscription := range ps.patterns {
+		if subscription.pattern == pattern && subscription.id == probe.id {
+			return ErrSubscriberIsExists
+		}
+	}
+
+	subscription := &patternSubscription{
+		id:      probe.id,
+		pattern: pattern,
+		handler: handler,
+		opts:    append(append([]SubscriberOption{}, opts...), PubsubSetSubscriberId(probe.id)),
+	}
+	ps.patterns = append(ps.patterns, subscription)
+
+	for topic, pub := range ps.publisher {
+		if _, matched := MatchTopic(pattern, topic); !matched {
+			continue
+		}
+		if _, err := ps.subscribe(topic, pub, subscription.handler, subscription.opts...); err != nil {
+			log.Printf("failed to subscribe %s of %s to %s, err: %s\n", subscription.id, pattern, topic, err)
+		}
+	}
 	return nil
 }
 
-// consume the message
-func (ps *Pubsub) Listen() error {
-	for topic, _ := range ps.publisher {
-		subscribers, exists := ps.subscriber[topic]
-		if !exists || subscribers == nil || len(subscribers) == 0 {
-			return ErrTopicIsNotExists
+// Unsubscribe removes the subscriber from the topic, the message in process is finished
+// and the messages that aren't handed over to the subscriber are skipped. The subscription
+// of a topic pattern is removed from every matched topic
+func (ps *Pubsub) Unsubscribe(topic, id string) error {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	if isTopicPattern(topic) {
+		return ps.patternUnsubscribe(topic, id)
+	}
+
+	if _, exists := ps.publisher[topic]; !exists {
+		return ErrTopicIsNotExists
+	}
+	if !ps.unsubscribe(topic, id) {
+		return ErrSubscriberIsNotExists
+	}
+	return nil
+}
+
+// unsubscribe removes the subscriber from the topic, the caller holds the lock
+func (ps *Pubsub) unsubscribe(topic, id string) bool {
+	var unsubscribed *subscriber
+	subscribers := make([]*subscriber, 0, len(ps.subscriber[topic]))
+	for _, subscriber := range ps.subscriber[topic] {
+		if subscriber.id == id {
+			unsubscribed = subscriber
+			continue
 		}
+		subscribers = append(subscribers, subscriber)
+	}
+	if unsubscribed == nil {
+		return false
+	}
 
-		go ps.listener(topic)
+	ps.subscriber[topic] = subscribers
+	unsubscribed.unsubscribe()
+	return true
+}
+
+// patternUnsubscribe removes the subscription of the pattern, the caller holds the lock
+func (ps *Pubsub) patternUnsubscribe(pattern, id string) error {
+	patterns := make([]*patternSubscription, 0, len(ps.patterns))
+	for _, subscription := range ps.patterns {
+		if subscription.pattern == pattern && subscription.id == id {
+			continue
+		}
+		patterns = append(patterns, subscription)
+	}
+	if len(patterns) == len(ps.patterns) {
+		return ErrSubscriberIsNotExists
+	}
+	ps.patterns = patterns
+
+	for topic := range ps.publisher {
+		if _, matched := MatchTopic(pattern, topic); matched {
+			ps.unsubscribe(topic, id)
+		}
+	}
+	return nil
+}
+
+// Subscribers returns the ids of the subscribers of the topic or of the topic pattern
+func (ps *Pubsub) Subscribers(topic string) ([]string, error) {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
+	if isTopicPattern(topic) {
+		ids := []string{}
+		for _, subscription := range ps.patterns {
+			if subscription.pattern == topic {
+				ids = append(ids, subscription.id)
+			}
+		}
+		return ids, nil
+	}
+
+	if _, exists := ps.publisher[topic]; !exists {
+		return nil, ErrTopicIsNotExists
+	}
+
+	ids := []string{}
+	for _, subscriber := range ps.subscribers(topic) {
+		ids = append(ids, subscriber.id)
+	}
+	return ids, nil
+}
+
+// Listen starts the subscribers, the subscriber that is registered later starts right away
+func (ps *Pubsub) Listen() error {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	ps.listening = true
+	for _, subscribers := range ps.subscriber {
+		for _, subscriber := range subscribers {
+			subscriber.listen()
+		}
 	}
 
 	return nil
 }
 
-func (ps Pubsub) dispatcher(topic string) {
+// subscribers returns the subscribers of the topic with a handler, the caller holds the lock
+func (ps *Pubsub) subscribers(topic string) []*subscriber {
+	subscribers := make([]*subscriber, 0, len(ps.subscriber[topic]))
+	for _, subscriber := range ps.subscriber[topic] {
+		if subscriber.handler == nil {
+			continue
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers
+}
+
+// Groups returns the ids of the members of the consumer groups of the topic
+func (ps *Pubsub) Groups(topic string) (map[string][]string, error) {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
+	if _, exists := ps.publisher[topic]; !exists {
+		return nil, ErrTopicIsNotExists
+	}
+
+	groups := make(map[string][]string)
+	for _, subscriber := range ps.subscribers(topic) {
+		if subscriber.group != "" {
+			groups[subscriber.group] = append(groups[subscriber.group], subscriber.id)
+		}
+	}
+	return groups, nil
+}
+
+// waitSubscribers returns the subscribers of the topic, it waits until one is registered
+func (ps *Pubsub) waitSubscribers(topic string, pub *publisher) ([]*subscriber, bool) {
 	for {
+		ps.mtx.RLock()
+		subscribers := ps.subscribers(topic)
+		subscribed := pub.subscribed
+		ps.mtx.RUnlock()
+
+		if len(subscribers) > 0 {
+			return subscribers, true
+		}
+
 		select {
 		case <-ps.shutdown:
+			return nil, false
+		case <-pub.deleted:
+			return nil, false
+		case <-subscribed:
+		}
+	}
+}
+
+func (ps *Pubsub) dispatcher(topic string, pub *publisher) {
+	for {
+		// the messages stay queued while the topic has no subscriber
+		if _, ok := ps.waitSubscribers(topic, pub); !ok {
 			return
-		case message := <-ps.publisher[topic].message:
-			buff := new(bytes.Buffer)
-			_, err := io.Copy(buff, message)
-			if err != nil {
-				continue
-			}
+		}
 
-			for _, subscriber := range ps.subscriber[topic] {
-				if subscriber.handler == nil {
-					continue
-				}
+		select {
+		case <-ps.shutdown:
+			return
+		case <-pub.deleted:
+			return
+		case message := <-pub.message:
+			if !ps.dispatchQueued(topic, pub, message) {
+				return
+			}
+			continue
+		default:
+		}
 
-				duplicateMessage := bytes.NewBufferString(buff.String())
+		// the spilled messages are published after the queued ones
+		if pub.spilled.Load() > 0 {
+			if !ps.dispatchSpilled(topic, pub) {
+				return
+			}
+			continue
+		}
 
-				go subscriber.dispatcher(duplicateMessage)
+		select {
+		case <-ps.shutdown:
+			return
+		case <-pub.deleted:
+			return
+		case <-pub.spill:
+		case message := <-pub.message:
+			if !ps.dispatchQueued(topic, pub, message) {
+				return
 			}
 		}
 	}
 }
 
-func (ps Pubsub) listener(topic string) {
-	for _, subscriber := range ps.subscriber[topic] {
-		if subscriber.handler == nil {
+func (ps *Pubsub) dispatchQueued(topic string, pub *publisher, message envelope) bool {
+	pub.stats.dispatching.Store(1)
+	if !ps.dispatch(topic, pub, message) {
+		return false
+	}
+	pub.stats.dispatching.Store(0)
+	return true
+}
+
+// dispatchSpilled reads the spilled messages from the write-ahead log and dispatches them in order
+func (ps *Pubsub) dispatchSpilled(topic string, pub *publisher) bool {
+	pub.mtx.Lock()
+	lastQueued := pub.lastQueued
+	pub.mtx.Unlock()
+
+	backups, err := ps.database.Retrieve(topic)
+	if err != nil {
+		log.Printf("failed to read the spilled messages of %s, err: %s\n", topic, err)
+		select {
+		case <-ps.shutdown:
+			return false
+		case <-pub.deleted:
+			return false
+		case <-time.After(time.Second):
+			return true
+		}
+	}
+
+	for _, backup := range backups {
+		if backup.SequenceId <= lastQueued {
 			continue
 		}
+		pub.mtx.Lock()
+		if pub.spilled.Load() == 0 {
+			// the topic is deleted while the spilled messages are read
+			pub.mtx.Unlock()
+			return true
+		}
+		pub.unspill(backup.SequenceId)
+		pub.mtx.Unlock()
+
+		if !ps.dispatchQueued(topic, pub, newEnvelope(topic, backup)) {
+			return false
+		}
+	}
+	return true
+}
+
+// dispatch queues the message for the subscribers in the queued order, every subscriber consumes
+// its own queue so a slow one doesn't hold the others until its queue is full
+func (ps *Pubsub) dispatch(topic string, pub *publisher, message envelope) bool {
+	// the subscribers may be gone while the message is taken
+	subscribers, ok := ps.waitSubscribers(topic, pub)
+	if !ok {
+		return false
+	}
+
+	// a group receives the message once, the message is skipped when every receiver is leaving
+	receivers := ps.receivers(topic, pub, subscribers, message)
+	if len(receivers) == 0 {
+		delivery{done: ps.ack(topic, message.sequenceId, 1)}.ack()
+		return true
+	}
+	done := ps.ack(topic, message.sequenceId, len(receivers))
+	for _, subscriber := range receivers {
+		if !subscriber.dispatcher(delivery{message: message.message, done: done}, ps.shutdown) {
+			return false
+		}
+	}
+	return true
+}
+
+// receivers returns the subscribers of the message, the replayed dead letter only goes
+// to the subscriber that failed it while it's subscribed
+func (ps *Pubsub) receivers(topic string, pub *publisher, subscribers []*subscriber, message envelope) []*subscriber {
+	if message.subscriberId != "" {
+		if target := ps.findSubscriber(topic, message.subscriberId); target != nil {
+			return []*subscriber{target}
+		}
+	}
+	return pub.receivers(subscribers, message.message.Key)
+}
 
-		go subscriber.listen()
+// ack deletes the message from the write-ahead log after every subscriber is done with it,
+// the message that isn't acknowledged is recovered on the next start
+func (ps *Pubsub) ack(topic string, sequenceId int64, subscribers int) func() {
+	if ps.database == nil || sequenceId == 0 {
+		return nil
+	}
+
+	var remaining atomic.Int32
+	remaining.Store(int32(subscribers))
+	return func() {
+		if remaining.Add(-1) > 0 {
+			return
+		}
+		if err := ps.database.Delete(topic, sequenceId); err != nil {
+			log.Printf("failed to acknowledge the message %d of %s, err: %s\n", sequenceId, topic, err)
+		}
 	}
 }
 
-func (ps *Pubsub) Publish(topic string, message MessageHandler) error {
+func (ps *Pubsub) topic(topic string) (*publisher, bool) {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
 	pub, exists := ps.publisher[topic]
+	return pub, exists
+}
+
+// Publish publishes the message without key and headers
+func (ps *Pubsub) Publish(topic string, message MessageHandler, opts ...PublishOpt) error {
+	if _, exists := ps.topic(topic); !exists {
+		return ErrTopicIsNotExists
+	}
+
+	reader, err := message()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return ps.PublishMessage(topic, Message{
+		Body: data,
+	}, opts...)
+}
+
+// PublishMessage publishes the message with its key and headers, the messages
+// of the same key are processed in the published order by every subscriber.
+// The message with a delay or a delivery time is scheduled, it's cancelled by its id
+func (ps *Pubsub) PublishMessage(topic string, message Message, opts ...PublishOpt) error {
+	var publishOption PublishOptions
+	for _, opt := range opts {
+		opt(&publishOption)
+	}
+
+	pub, exists := ps.topic(topic)
 	if !exists {
 		return ErrTopicIsNotExists
 	}
 
-	if err := pub.publish(message); err != nil {
+	message.Topic = topic
+	if message.Id == "" {
+		message.Id = uuid.New().String()
+	}
+	if message.PublishedAt.IsZero() {
+		message.PublishedAt = time.Now()
+	}
+
+	if publishOption.deliverAt.After(time.Now()) {
+		return ps.scheduleMessage(message, publishOption.deliverAt)
+	}
+
+	if err := ps.enqueue(topic, pub, envelope{message: message}, !publishOption.noWait); err != nil {
 		return err
 	}
+	pub.stats.published.Add(1)
 
-	if _, exists := ps.subscriber[topic]; !exists {
+	ps.mtx.RLock()
+	subscribers := len(ps.subscribers(topic))
+	ps.mtx.RUnlock()
+	if subscribers == 0 {
 		log.Printf("topic %s doesn have subscriber the message will store to the memory, current message: %d\n", topic, pub.len())
 		return ErrConsumerIsNotExists
 	}
@@ -159,21 +659,281 @@ func (ps *Pubsub) Publish(topic string, message MessageHandler) error {
 	return nil
 }
 
-func (ps Pubsub) Capacity(topic string) int {
-	return ps.publisher[topic].capacity()
+// enqueue queues the message after the recovered ones, so the order of the topic is kept.
+// It returns errTopicIsBusy instead of waiting for the recovery or the room of the queue when wait is false
+func (ps *Pubsub) enqueue(topic string, pub *publisher, message envelope, wait bool) error {
+	if wait {
+		<-pub.recovered
+	} else {
+		select {
+		case <-pub.recovered:
+		default:
+			return errTopicIsBusy
+		}
+	}
+
+	if ps.database != nil {
+		return ps.store(topic, pub, message)
+	}
+	if !wait {
+		if !pub.tryPublish(message) {
+			return errTopicIsBusy
+		}
+		return nil
+	}
+	if !pub.publish(message, ps.shutdown) {
+		// the memory queue has nothing to spill to, the publisher waits for the room
+		// without holding the lock
+		if pub.isDeleted() {
+			return ErrTopicIsNotExists
+		}
+		return ErrPubsubIsShutdown
+	}
+	return nil
 }
 
-func (ps Pubsub) Len(topic string) int {
-	return ps.publisher[topic].len()
+// store writes the message to the write-ahead log and queues it, the message is spilled to the log
+// when the queue is full so the publisher never waits for the consumers
+func (ps *Pubsub) store(topic string, pub *publisher, message envelope) error {
+	// the write-ahead log and the queue have the same order
+	pub.mtx.Lock()
+	defer pub.mtx.Unlock()
+
+	if pub.isDeleted() {
+		return ErrTopicIsNotExists
+	}
+	select {
+	case <-ps.shutdown:
+		return ErrPubsubIsShutdown
+	default:
+	}
+
+	backup, err := ps.database.Store(database.Backup{
+		Topic:        topic,
+		MessageId:    message.message.Id,
+		Key:          message.message.Key,
+		Headers:      message.message.Headers,
+		PublishedAt:  message.message.PublishedAt,
+		Message:      message.message.Body,
+		SubscriberId: message.subscriberId,
+	})
+	if err != nil {
+		return err
+	}
+	message.sequenceId = backup.SequenceId
+	pub.queue(message)
+	return nil
+}
+
+func (ps *Pubsub) Capacity(topic string) int {
+	pub, exists := ps.topic(topic)
+	if !exists {
+		return 0
+	}
+	return pub.capacity()
+}
+
+func (ps *Pubsub) Len(topic string) int {
+	pub, exists := ps.topic(topic)
+	if !exists {
+		return 0
+	}
+	return pub.len()
 }
 
+// Stats returns the counters of the topic
+func (ps *Pubsub) Stats(topic string) (Stats, error) {
+	ps.mtx.RLock()
+	pub, exists := ps.publisher[topic]
+	subscribers := ps.subscribers(topic)
+	ps.mtx.RUnlock()
+	if !exists {
+		return Stats{}, ErrTopicIsNotExists
+	}
+
+	stats := Stats{
+		Topic:         topic,
+		Published:     pub.stats.published.Load(),
+		Delivered:     pub.stats.delivered.Load(),
+		Failed:        pub.stats.failed.Load(),
+		Retried:       pub.stats.retried.Load(),
+		DeadLettered:  pub.stats.deadLettered.Load(),
+		QueueDepth:    pub.len() + int(pub.stats.dispatching.Load()),
+		QueueCapacity: pub.capacity(),
+		Scheduled:     ps.scheduler.len(topic),
+		InFlight:      pub.stats.inFlight.Load(),
+		Subscribers:   len(subscribers),
+		Latency:       pub.stats.histogram(),
+	}
+	for _, subscriber := range subscribers {
+		stats.QueueDepth += subscriber.pending()
+	}
+	return stats, nil
+}
+
+// AllStats returns the counters of every registered topic
+func (ps *Pubsub) AllStats() []Stats {
+	topics := ps.Topics()
+	stats := make([]Stats, 0, len(topics))
+	for _, topic := range topics {
+		topicStats, err := ps.Stats(topic)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, topicStats)
+	}
+	return stats
+}
+
+// Topics returns the registered topics
+func (ps *Pubsub) Topics() []string {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
+	topics := make([]string, 0, len(ps.publisher))
+	for topic := range ps.publisher {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (ps *Pubsub) storeDeadLetter(deadLetter database.DeadLetter) {
+	if err := ps.deadLetter.StoreDeadLetter(deadLetter); err != nil {
+		log.Printf("failed to store the dead letter of %s, message: %s, err: %s\n", deadLetter.Topic, deadLetter.Message, err)
+	}
+}
+
+// DeadLetters returns the messages of the topic that exhaust the retries, the oldest first
+func (ps *Pubsub) DeadLetters(topic string) (database.DeadLetters, error) {
+	if _, exists := ps.topic(topic); !exists {
+		return nil, ErrTopicIsNotExists
+	}
+
+	return ps.deadLetter.FindDeadLetters(database.FilterDeadLetter{
+		Topic: topic,
+	})
+}
+
+// ReplayDeadLetters publishes the dead letters again in the failed order, to the subscriber that
+// failed them or to every subscriber of the topic when the subscriber is gone, e.g. after a restart.
+// Every dead letter of the topic is replayed when the ids are empty. A dead letter is removed after
+// its message is queued and written to the write-ahead log, it's replayed again at worst after a crash
+func (ps *Pubsub) ReplayDeadLetters(topic string, ids ...string) (int, error) {
+	deadLetters, err := ps.findDeadLetters(topic, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	pub, exists := ps.topic(topic)
+	if !exists {
+		return 0, ErrTopicIsNotExists
+	}
+
+	for i, deadLetter := range deadLetters {
+		if err := ps.enqueue(topic, pub, envelope{
+			subscriberId: deadLetter.SubscriberId,
+			message: Message{
+				Id:          deadLetter.MessageId,
+				Topic:       topic,
+				Key:         deadLetter.Key,
+				Headers:     deadLetter.Headers,
+				PublishedAt: deadLetter.PublishedAt,
+				Body:        deadLetter.Message,
+			},
+		}, true); err != nil {
+			return i, err
+		}
+		if err := ps.deleteDeadLetters(topic, deadLetters[i:i+1]); err != nil {
+			return i + 1, err
+		}
+	}
+
+	return len(deadLetters), nil
+}
+
+// PurgeDeadLetters removes the dead letters of the topic, every dead letter
+// of the topic is removed when the ids are empty
+func (ps *Pubsub) PurgeDeadLetters(topic string, ids ...string) (int, error) {
+	deadLetters, err := ps.findDeadLetters(topic, ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(deadLetters) == 0 {
+		return 0, nil
+	}
+
+	if err := ps.deleteDeadLetters(topic, deadLetters); err != nil {
+		return 0, err
+	}
+	return len(deadLetters), nil
+}
+
+func (ps *Pubsub) findDeadLetters(topic string, ids []string) (database.DeadLetters, error) {
+	if _, exists := ps.topic(topic); !exists {
+		return nil, ErrTopicIsNotExists
+	}
+
+	deadLetters, err := ps.deadLetter.FindDeadLetters(database.FilterDeadLetter{
+		Topic: topic,
+		Ids:   ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 && len(deadLetters) != len(ids) {
+		return nil, ErrDeadLetterNotFound
+	}
+	return deadLetters, nil
+}
+
+// deleteDeadLetters removes the found dead letters only, the letters
+// that fail in the meantime are kept
+func (ps *Pubsub) deleteDeadLetters(topic string, deadLetters database.DeadLetters) error {
+	ids := make([]string, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		ids = append(ids, deadLetter.Id)
+	}
+
+	return ps.deadLetter.DeleteDeadLetters(database.FilterDeadLetter{
+		Topic: topic,
+		Ids:   ids,
+	})
+}
+
+func (ps *Pubsub) findSubscriber(topic, id string) *subscriber {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
+	for _, subscriber := range ps.subscribers(topic) {
+		if subscriber.id == id {
+			return subscriber
+		}
+	}
+	return nil
+}
+
+// CheckBackup recovers the messages of the topic that aren't acknowledged before the last
+// shutdown or crash, they're queued again in the published order before the new messages.
+// It's called once by TopicRegister
 func (ps *Pubsub) CheckBackup(topic string) error {
+	pub, exists := ps.topic(topic)
+	if !exists {
+		return ErrTopicIsNotExists
+	}
+	return ps.checkBackup(topic, pub)
+}
+
+func (ps *Pubsub) checkBackup(topic string, pub *publisher) error {
+	defer pub.recover()
+
 	if ps.database == nil {
 		return nil
 	}
 	log.Println("checking backup...")
-	backups, err := ps.database.RetrieveAndDelete(topic)
+	backups, err := ps.database.Retrieve(topic)
 	if err != nil {
+		log.Printf("failed to recover the messages of %s, err: %s\n", topic, err)
 		return err
 	}
 	if len(backups) == 0 {
@@ -183,65 +943,94 @@ func (ps *Pubsub) CheckBackup(topic string) error {
 
 	log.Printf("found %d backups, start publish...\n", len(backups))
 
+	// the messages keep their entries in the write-ahead log until they're acknowledged,
+	// the ones that don't fit in the queue are read from the log again by the dispatcher
+	pub.mtx.Lock()
 	for _, backup := range backups {
-		if err := ps.Publish(backup.Topic, SendBytes(backup.Message)); err != nil {
-			return err
-		}
+		pub.queue(newEnvelope(topic, backup))
 	}
+	pub.mtx.Unlock()
 
 	log.Printf("success retrieve %d message...\n", len(backups))
 	return nil
 }
 
-func (ps *Pubsub) shutdownSubscriber(topic string) {
-	ps.shutdown <- true
-	for idx, _ := range ps.subscriber[topic] {
-		ps.subscriber[topic][idx].close()
-	}
-}
-
-func (ps *Pubsub) shutdownPublisher(topic string) {
-	ps.publisher[topic].close()
-}
-
+// Shutdown stops the dispatching and waits for the in-flight messages until the context is done,
+// then the handlers are cancelled. The messages that aren't settled are kept in the write-ahead
+// log and recovered on the next start
 func (ps *Pubsub) Shutdown(ctx context.Context) error {
 	log.Println("Shutting down pubsub")
-	if ps.database == nil {
-		log.Println("no database was selected")
-		return nil
-	}
-	log.Println("backup message from queue")
 
-	backups := database.Backups{}
+	// every dispatcher and subscriber is stopped
+	ps.shutdownOnce.Do(func() {
+		close(ps.shutdown)
+	})
+
+	ps.mtx.RLock()
+	publishers := make(map[string]*publisher, len(ps.publisher))
 	for topic, publisher := range ps.publisher {
-		ps.shutdownSubscriber(topic)
-		ps.shutdownPublisher(topic)
+		publishers[topic] = publisher
+	}
+	ps.mtx.RUnlock()
+
+	inFlight := ps.drain(ctx, publishers)
+	ps.cancel()
+	defer ps.close()
+
+	queued := 0
+	for topic, publisher := range publishers {
 		log.Printf("Topic: %s has already been shutdown\n", topic)
-		messages, err := publisher.retrieveMessages()
-		if err != nil {
-			return err
-		}
+		queued += len(publisher.retrieveMessages()) + int(publisher.spilled.Load())
+	}
 
-		for _, message := range messages {
-			backups = append(backups, database.Backup{
-				Topic:   topic,
-				Message: []byte(message),
-			})
-		}
+	if ps.database == nil {
+		log.Println("no database was selected")
+		return nil
 	}
 
-	if len(backups) == 0 {
+	if queued == 0 && inFlight == 0 {
 		log.Println("there is no message from queue")
 		return nil
 	}
 
-	for _, backup := range backups {
-		err := ps.database.Store(backup)
-		if err != nil {
-			return err
+	// the queued messages are already in the write-ahead log, they're recovered on the next start
+	log.Printf("%d queued and %d in-flight messages are kept in the write-ahead log\n", queued, inFlight)
+	return nil
+}
+
+// close closes the stores that hold files, e.g. the lock of the file backup is released
+// for the next start. The clover database is closed by its owner
+func (ps *Pubsub) close() {
+	for _, store := range []any{ps.database, ps.deadLetter, ps.schedule} {
+		closer, ok := store.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			log.Printf("failed to close the backup, err: %s\n", err)
 		}
 	}
+}
 
-	log.Printf("success backup %d message from queue\n", len(backups))
-	return nil
+// drain waits until the handlers finish the in-flight messages or the context is done,
+// it returns the number of the messages that are still in flight
+func (ps *Pubsub) drain(ctx context.Context, publishers map[string]*publisher) int64 {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var inFlight int64
+		for _, publisher := range publishers {
+			inFlight += publisher.stats.inFlight.Load()
+		}
+		if inFlight == 0 {
+			return 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return inFlight
+		case <-ticker.C:
+		}
+	}
 }
@@ -24,11 +24,12 @@ func TestPubsub(t *testing.T) {
 			pubsub.PubsubSetMaxBufferCapacity(5),
 		)
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			buf := new(strings.Builder)
 			io.Copy(buf, r)
 			actual <- buf.String()
 			close(actual)
+			return nil
 		}, pubsub.PubsubSetMaxWorker(5))
 
 		go ps.Listen()
@@ -48,20 +49,22 @@ func TestPubsub(t *testing.T) {
 			pubsub.PubsubSetMaxBufferCapacity(5),
 		)
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			defer wg.Done()
 			fmt.Println(r, id, "consumer 1")
 			buf1 := new(strings.Builder)
 			io.Copy(buf1, r)
 			actual <- buf1.String()
+			return nil
 		}, pubsub.PubsubSetMaxWorker(1))
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			defer wg.Done()
 			fmt.Println(r, id, "consumer 2")
 			buf2 := new(strings.Builder)
 			io.Copy(buf2, r)
 			actual <- buf2.String()
+			return nil
 		}, pubsub.PubsubSetMaxWorker(2))
 
 		go ps.Listen()
@@ -87,11 +90,12 @@ func TestPubsub(t *testing.T) {
 			pubsub.PubsubSetMaxBufferCapacity(5),
 		)
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			buf := new(strings.Builder)
 			io.Copy(buf, r)
 			actual <- buf.String()
 			close(actual)
+			return nil
 		}, pubsub.PubsubSetMaxWorker(5))
 
 		go ps.Listen()
@@ -112,11 +116,12 @@ func TestPubsub(t *testing.T) {
 			pubsub.PubsubSetMaxBufferCapacity(5),
 		)
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			var resp JSON
 			json.NewDecoder(r).Decode(&resp)
 			actual <- resp
 			close(actual)
+			return nil
 		}, pubsub.PubsubSetMaxWorker(5))
 
 		go ps.Listen()
@@ -145,18 +150,20 @@ func TestPubsub(t *testing.T) {
 			pubsub.PubsubSetMaxBufferCapacity(5),
 		)
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			defer wg.Done()
 			var resp JSON
 			json.NewDecoder(r).Decode(&resp)
 			actual <- resp
+			return nil
 		}, pubsub.PubsubSetMaxWorker(5))
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			defer wg.Done()
 			var resp JSON
 			json.NewDecoder(r).Decode(&resp)
 			actual <- resp
+			return nil
 		}, pubsub.PubsubSetMaxWorker(2))
 
 		go ps.Listen()
@@ -225,8 +232,9 @@ func TestShutdown(t *testing.T) {
 			pubsub.PubsubSetMaxBufferCapacity(5),
 		)
 
-		ps.ConsumerRegister("test-topic-1", func(id string, r io.Reader) {
+		ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
 			fmt.Println(id, r)
+			return nil
 		}, pubsub.PubsubSetMaxWorker(5))
 
 		go ps.Listen()
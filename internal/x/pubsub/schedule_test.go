@@ -0,0 +1,192 @@
+package pubsub_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledMessage(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	received := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+	require.NoError(t, ps.Listen())
+
+	// the messages are published in the delivery order
+	start := time.Now()
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("2"), pubsub.PubsubSetDelay(200*time.Millisecond)))
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("1"), pubsub.PubsubSetDeliverAt(start.Add(100*time.Millisecond))))
+	require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+		Id:   "cancelled",
+		Body: []byte("cancelled"),
+	}, pubsub.PubsubSetDelay(150*time.Millisecond)))
+
+	stats, err := ps.Stats("test-topic-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Scheduled)
+
+	require.NoError(t, ps.CancelSchedule("cancelled"))
+	assert.Equal(t, "1", waitReceived(t, received))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, "2", waitReceived(t, received))
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// the past delivery time is published right away
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("3"), pubsub.PubsubSetDeliverAt(start)))
+	assert.Equal(t, "3", waitReceived(t, received))
+
+	testCases := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{
+			name: "delivered message",
+			call: func() error {
+				return ps.CancelSchedule("cancelled")
+			},
+			expected: pubsub.ErrScheduleNotFound,
+		},
+		{
+			name: "duplicate id",
+			call: func() error {
+				message := pubsub.Message{Id: "duplicate"}
+				require.NoError(t, ps.PublishMessage("test-topic-1", message, pubsub.PubsubSetDelay(time.Hour)))
+				return ps.PublishMessage("test-topic-1", message, pubsub.PubsubSetDelay(time.Hour))
+			},
+			expected: pubsub.ErrScheduleIsExists,
+		},
+		{
+			name: "unknown topic",
+			call: func() error {
+				return ps.Publish("test-topic-2", pubsub.SendString("4"), pubsub.PubsubSetDelay(time.Hour))
+			},
+			expected: pubsub.ErrTopicIsNotExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.call(), tc.expected)
+		})
+	}
+}
+
+func TestScheduleRetry(t *testing.T) {
+	// the segment of the topic can't be opened, the due message fails to be written
+	dir := t.TempDir()
+	segment := filepath.Join(dir, "test-topic-1.wal")
+	require.NoError(t, os.Mkdir(segment, 0o755))
+
+	ps := pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	received := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+	require.NoError(t, ps.Listen())
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("1"), pubsub.PubsubSetDelay(10*time.Millisecond)))
+
+	select {
+	case message := <-received:
+		t.Fatalf("the message is received without the write-ahead log: %s", message)
+	case <-time.After(200 * time.Millisecond):
+	}
+	stats, err := ps.Stats("test-topic-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Scheduled)
+
+	// the message is published on the next attempt
+	require.NoError(t, os.Remove(segment))
+	assert.Equal(t, "1", waitReceived(t, received))
+}
+
+func TestScheduleBusyTopic(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(1))
+	ps.TopicRegister("test-topic-2", pubsub.PubsubSetMaxBufferCapacity(1))
+	require.NoError(t, ps.Listen())
+
+	// the queue of the first topic is full, the due message of the other topic isn't held by it
+	err := ps.Publish("test-topic-1", pubsub.SendString("1"))
+	assert.ErrorIs(t, err, pubsub.ErrConsumerIsNotExists)
+	require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("2"), pubsub.PubsubSetDelay(10*time.Millisecond)))
+	require.NoError(t, ps.Publish("test-topic-2", pubsub.SendString("3"), pubsub.PubsubSetDelay(20*time.Millisecond)))
+
+	second := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-2", receiver(second)))
+	assert.Equal(t, "3", waitReceived(t, second))
+
+	// the due message is published when the queue has room
+	first := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(first)))
+	assert.Equal(t, "1", waitReceived(t, first))
+	assert.Equal(t, "2", waitReceived(t, first))
+}
+
+func TestScheduleRecovery(t *testing.T) {
+	for _, driver := range backupDrivers {
+		t.Run(driver.name, func(t *testing.T) {
+			opt, _, crash := driver.open(t, t.TempDir())
+			ps := pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+			deliverAt := time.Now().Add(300 * time.Millisecond)
+			for _, message := range []string{"1", "2"} {
+				require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+					Id:   message,
+					Body: []byte(message),
+				}, pubsub.PubsubSetDeliverAt(deliverAt)))
+			}
+			require.NoError(t, ps.CancelSchedule("2"))
+
+			// the scheduled message is published at its delivery time after the restart
+			opt, _, _ = driver.open(t, crash())
+			ps = pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+			received := make(chan string, 5)
+			require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+			require.NoError(t, ps.Listen())
+
+			assert.Equal(t, "1", waitReceived(t, received))
+			assert.False(t, time.Now().Before(deliverAt))
+			select {
+			case message := <-received:
+				t.Fatalf("the cancelled message is received: %s", message)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestScheduleStoreFailure(t *testing.T) {
+	// the file of the schedules can't be opened, the message fails to be stored
+	dir := t.TempDir()
+	records := filepath.Join(dir, "schedules.records")
+	require.NoError(t, os.Mkdir(records, 0o755))
+
+	ps := pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	received := make(chan string, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+	require.NoError(t, ps.Listen())
+
+	message := pubsub.Message{
+		Id:   "1",
+		Body: []byte("1"),
+	}
+	assert.Error(t, ps.PublishMessage("test-topic-1", message, pubsub.PubsubSetDelay(time.Millisecond)))
+	select {
+	case message := <-received:
+		t.Fatalf("the message that isn't stored is published: %s", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the id of the failed message can be scheduled again
+	require.NoError(t, os.Remove(records))
+	require.NoError(t, ps.PublishMessage("test-topic-1", message, pubsub.PubsubSetDelay(time.Millisecond)))
+	assert.Equal(t, "1", waitReceived(t, received))
+}
@@ -0,0 +1,417 @@
+package pubsub
+
+import (
+	"container/heap"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+)
+
+type PublishOpt func(ps *PublishOptions)
+
+type PublishOptions struct {
+	deliverAt time.Time
+	// noWait fails the publish with errTopicIsBusy instead of waiting for the topic
+	noWait bool
+}
+
+// PubsubSetDelay schedules the message to be published after the delay
+func PubsubSetDelay(delay time.Duration) PublishOpt {
+	return func(ps *PublishOptions) {
+		ps.deliverAt = time.Now().Add(delay)
+	}
+}
+
+// PubsubSetDeliverAt schedules the message to be published at the time,
+// the message of a past time is published right away
+func PubsubSetDeliverAt(deliverAt time.Time) PublishOpt {
+	return func(ps *PublishOptions) {
+		ps.deliverAt = deliverAt
+	}
+}
+
+const (
+	// scheduleRetryInterval is the wait of the due message that fails to be published, it's
+	// doubled on every attempt up to scheduleMaxRetryInterval
+	scheduleRetryInterval    = 100 * time.Millisecond
+	scheduleMaxRetryInterval = 30 * time.Second
+)
+
+// publishNoWait publishes the message without waiting for the topic, the due messages
+// of the other topics aren't held by a topic that is recovering or full
+func publishNoWait() PublishOpt {
+	return func(ps *PublishOptions) {
+		ps.noWait = true
+	}
+}
+
+// scheduledMessage is the message that waits for its delivery time
+type scheduledMessage struct {
+	message   Message
+	deliverAt time.Time
+	// sequence keeps the scheduled order of the messages with the same delivery time
+	sequence int64
+	index    int
+	// attempts is the number of the failed publishes of the due message
+	attempts int
+}
+
+// scheduleQueue is the priority queue of the scheduled messages, the earliest delivery first
+type scheduleQueue []*scheduledMessage
+
+func (q scheduleQueue) Len() int { return len(q) }
+
+func (q scheduleQueue) Less(i, j int) bool {
+	if q[i].deliverAt.Equal(q[j].deliverAt) {
+		return q[i].sequence < q[j].sequence
+	}
+	return q[i].deliverAt.Before(q[j].deliverAt)
+}
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x any) {
+	message := x.(*scheduledMessage)
+	message.index = len(*q)
+	*q = append(*q, message)
+}
+
+func (q *scheduleQueue) Pop() any {
+	old := *q
+	message := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return message
+}
+
+// scheduler keeps the scheduled messages of the registered topics until they're due,
+// the messages are indexed by their id to be cancelled
+type scheduler struct {
+	mtx      sync.Mutex
+	queue    scheduleQueue
+	messages map[string]*scheduledMessage
+	// reserved are the ids of the messages that are being stored or published by their topic,
+	// the id can't be scheduled again until the stored message is written or removed
+	reserved map[string]string
+	sequence int64
+	// wake is signaled when the earliest delivery time may be changed
+	wake chan struct{}
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		messages: make(map[string]*scheduledMessage),
+		reserved: make(map[string]string),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// push schedules the message, it's false when the id is already scheduled or reserved
+func (s *scheduler) push(message Message, deliverAt time.Time) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, exists := s.reserved[message.Id]; exists {
+		return false
+	}
+	return s.schedule(&scheduledMessage{
+		message:   message,
+		deliverAt: deliverAt,
+	})
+}
+
+// reserve keeps the id of the message that is being stored,
+// it's false when the id is already scheduled or reserved
+func (s *scheduler) reserve(message Message) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, exists := s.messages[message.Id]; exists {
+		return false
+	}
+	if _, exists := s.reserved[message.Id]; exists {
+		return false
+	}
+	s.reserved[message.Id] = message.Topic
+	return true
+}
+
+// unreserve releases the id, it's false when the reservation is dropped with its topic
+func (s *scheduler) unreserve(id string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, exists := s.reserved[id]
+	delete(s.reserved, id)
+	return exists
+}
+
+// pushReserved schedules the stored message of the reserved id,
+// it's false when the reservation is dropped with its topic
+func (s *scheduler) pushReserved(message Message, deliverAt time.Time) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, exists := s.reserved[message.Id]; !exists {
+		return false
+	}
+	delete(s.reserved, message.Id)
+	return s.schedule(&scheduledMessage{
+		message:   message,
+		deliverAt: deliverAt,
+	})
+}
+
+// retry schedules the due message again after its backoff, the message is dropped
+// when its topic is deleted in the meantime
+func (s *scheduler) retry(scheduled *scheduledMessage, now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, exists := s.reserved[scheduled.message.Id]; !exists {
+		return
+	}
+	delete(s.reserved, scheduled.message.Id)
+
+	wait := scheduleMaxRetryInterval
+	if scheduled.attempts < 16 {
+		wait = min(scheduleRetryInterval<<scheduled.attempts, scheduleMaxRetryInterval)
+	}
+	scheduled.attempts++
+	scheduled.deliverAt = now.Add(wait)
+	s.schedule(scheduled)
+}
+
+// schedule queues the message, the caller holds the lock
+func (s *scheduler) schedule(scheduled *scheduledMessage) bool {
+	if _, exists := s.messages[scheduled.message.Id]; exists {
+		return false
+	}
+
+	s.sequence++
+	scheduled.sequence = s.sequence
+	heap.Push(&s.queue, scheduled)
+	s.messages[scheduled.message.Id] = scheduled
+	s.notify()
+	return true
+}
+
+// cancel removes the scheduled message, it's false when the message isn't scheduled
+func (s *scheduler) cancel(id string) (Message, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	scheduled, exists := s.messages[id]
+	if !exists {
+		return Message{}, false
+	}
+	heap.Remove(&s.queue, scheduled.index)
+	delete(s.messages, id)
+	s.notify()
+	return scheduled.message, true
+}
+
+// cancelTopic removes the scheduled messages of the topic
+func (s *scheduler) cancelTopic(topic string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for id, scheduled := range s.messages {
+		if scheduled.message.Topic != topic {
+			continue
+		}
+		heap.Remove(&s.queue, scheduled.index)
+		delete(s.messages, id)
+	}
+	for id, reservedTopic := range s.reserved {
+		if reservedTopic == topic {
+			delete(s.reserved, id)
+		}
+	}
+	s.notify()
+}
+
+// due takes the earliest message out of the queue when its delivery time has come, its id is
+// reserved until it's published. Otherwise it returns the duration until the earliest delivery
+func (s *scheduler) due(now time.Time) (*scheduledMessage, time.Duration, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, -1, false
+	}
+	if wait := s.queue[0].deliverAt.Sub(now); wait > 0 {
+		return nil, wait, false
+	}
+
+	scheduled := heap.Pop(&s.queue).(*scheduledMessage)
+	delete(s.messages, scheduled.message.Id)
+	s.reserved[scheduled.message.Id] = scheduled.message.Topic
+	return scheduled, 0, true
+}
+
+func (s *scheduler) len(topic string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	count := 0
+	for _, scheduled := range s.messages {
+		if scheduled.message.Topic == topic {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleMessage stores the message until its delivery time, the stored messages
+// are scheduled again when their topic is registered after a restart. The message
+// is scheduled after it's stored, so the due message never outlives its stored copy
+func (ps *Pubsub) scheduleMessage(message Message, deliverAt time.Time) error {
+	if !ps.scheduler.reserve(message) {
+		return ErrScheduleIsExists
+	}
+
+	if err := ps.schedule.StoreSchedule(database.Schedule{
+		Id:          message.Id,
+		Topic:       message.Topic,
+		Key:         message.Key,
+		Headers:     message.Headers,
+		PublishedAt: message.PublishedAt,
+		DeliverAt:   deliverAt,
+		Message:     message.Body,
+	}); err != nil {
+		ps.scheduler.unreserve(message.Id)
+		return err
+	}
+
+	// the topic is deleted while the message is stored
+	if !ps.scheduler.pushReserved(message, deliverAt) {
+		if err := ps.schedule.DeleteSchedules(database.FilterSchedule{
+			Topic: message.Topic,
+			Ids:   []string{message.Id},
+		}); err != nil {
+			log.Printf("failed to remove the scheduled message %s of %s, err: %s\n", message.Id, message.Topic, err)
+		}
+		return ErrTopicIsNotExists
+	}
+
+	log.Printf("success scheduling the message %s to %s at %s\n", message.Id, message.Topic, deliverAt)
+	return nil
+}
+
+// CancelSchedule removes the scheduled message before it's published
+func (ps *Pubsub) CancelSchedule(id string) error {
+	message, exists := ps.scheduler.cancel(id)
+	if !exists {
+		return ErrScheduleNotFound
+	}
+
+	return ps.schedule.DeleteSchedules(database.FilterSchedule{
+		Topic: message.Topic,
+		Ids:   []string{id},
+	})
+}
+
+// checkSchedules schedules the stored messages of the topic again
+func (ps *Pubsub) checkSchedules(topic string) error {
+	schedules, err := ps.schedule.FindSchedules(database.FilterSchedule{
+		Topic: topic,
+	})
+	if err != nil {
+		log.Printf("failed to recover the scheduled messages of %s, err: %s\n", topic, err)
+		return err
+	}
+
+	for _, schedule := range schedules {
+		ps.scheduler.push(Message{
+			Id:          schedule.Id,
+			Topic:       topic,
+			Key:         schedule.Key,
+			Headers:     schedule.Headers,
+			PublishedAt: schedule.PublishedAt,
+			Body:        schedule.Message,
+		}, schedule.DeliverAt)
+	}
+	return nil
+}
+
+// deliverSchedules publishes the scheduled messages when they're due until the pubsub is shut down
+func (ps *Pubsub) deliverSchedules() {
+	for {
+		scheduled, wait, due := ps.scheduler.due(time.Now())
+		if due {
+			if !ps.deliverSchedule(scheduled) {
+				return
+			}
+			continue
+		}
+
+		// the scheduler without message waits for the next one
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-timeout:
+		case <-ps.scheduler.wake:
+		case <-ps.shutdown:
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// deliverSchedule publishes the due message, the stored message is removed after it's
+// in the write-ahead log. The message that fails to be published is scheduled again after
+// a backoff. It's false when the pubsub is shut down, the message is kept in the database
+// to be scheduled again on the next start
+func (ps *Pubsub) deliverSchedule(scheduled *scheduledMessage) bool {
+	message := scheduled.message
+	err := ps.PublishMessage(message.Topic, message, publishNoWait())
+	switch {
+	case errors.Is(err, ErrPubsubIsShutdown):
+		return false
+	case errors.Is(err, errTopicIsBusy):
+		ps.scheduler.retry(scheduled, time.Now())
+		return true
+	case errors.Is(err, ErrTopicIsNotExists):
+		// the stored message is kept, it's scheduled again when the topic is registered
+		log.Printf("the topic %s of the scheduled message %s is not registered\n", message.Topic, message.Id)
+		ps.scheduler.unreserve(message.Id)
+		return true
+	case errors.Is(err, ErrConsumerIsNotExists):
+		// the message is queued for the subscriber that comes later
+	case err != nil:
+		log.Printf("failed to publish the scheduled message %s to %s, retry it, err: %s\n", message.Id, message.Topic, err)
+		ps.scheduler.retry(scheduled, time.Now())
+		return true
+	}
+
+	if err := ps.schedule.DeleteSchedules(database.FilterSchedule{
+		Topic: message.Topic,
+		Ids:   []string{message.Id},
+	}); err != nil {
+		log.Printf("failed to remove the scheduled message %s of %s, err: %s\n", message.Id, message.Topic, err)
+	}
+	ps.scheduler.unreserve(message.Id)
+	return true
+}
@@ -0,0 +1,110 @@
+package pubsub_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+
+	// the first call ignores its context, the key waits until it returns and the call is retried
+	received := make(chan string, 5)
+	var attempts, running atomic.Int32
+	var overlapped atomic.Bool
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+		if running.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		defer running.Add(-1)
+
+		if attempts.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+			return ctx.Err()
+		}
+		data, _ := io.ReadAll(r)
+		received <- string(data)
+		return nil
+	}, pubsub.PubsubSetTimeout(50*time.Millisecond),
+		pubsub.PubsubSetMaxElapsedTime(5*time.Second),
+		pubsub.PubsubSetRetryWaitTime(10*time.Millisecond)))
+	require.NoError(t, ps.Listen())
+
+	for _, message := range []string{"1", "2"} {
+		require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+			Key:  "client-1",
+			Body: []byte(message),
+		}))
+	}
+	assert.Equal(t, "1", waitReceived(t, received))
+	assert.Equal(t, "2", waitReceived(t, received))
+	assert.False(t, overlapped.Load())
+
+	stats := waitStats(t, ps, func(stats pubsub.Stats) bool {
+		return stats.Delivered == 2
+	})
+	assert.Equal(t, int64(1), stats.Failed)
+	assert.Equal(t, int64(1), stats.Retried)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	dir := t.TempDir()
+	read := func() database.Databaser { return database.NewFileDatabaseReader(dir) }
+
+	ps := pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+
+	// "1" is finished in the drain, "2" is given up and its handler is cancelled
+	started := make(chan string, 5)
+	cancelled := make(chan error, 5)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+		data, _ := io.ReadAll(r)
+		started <- string(data)
+		if string(data) == "1" {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	}, pubsub.PubsubSetAsyncProcess(true), pubsub.PubsubSetMaxElapsedTime(time.Minute)))
+	require.NoError(t, ps.Listen())
+
+	for _, message := range []string{"1", "2"} {
+		require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(message)))
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, []string{waitReceived(t, started), waitReceived(t, started)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	require.NoError(t, ps.Shutdown(ctx))
+
+	select {
+	case err := <-cancelled:
+		assert.True(t, errors.Is(err, context.Canceled))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler isn't cancelled")
+	}
+	waitWriteAheadLog(t, read, "2")
+
+	// the given up message isn't dead-lettered, it's recovered on the next start
+	received := make(chan string, 5)
+	ps = pubsub.NewPubsub(pubsub.SetFileForBackup(dir))
+	ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(5))
+	deadLetters, err := ps.DeadLetters("test-topic-1")
+	require.NoError(t, err)
+	assert.Empty(t, deadLetters)
+	require.NoError(t, ps.ConsumerRegister("test-topic-1", receiver(received)))
+	require.NoError(t, ps.Listen())
+	assert.Equal(t, "2", waitReceived(t, received))
+}
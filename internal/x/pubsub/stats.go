@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the handler latency histogram
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// topicStats are the counters of a topic, they're shared by its subscribers
+type topicStats struct {
+	published    atomic.Int64
+	delivered    atomic.Int64
+	failed       atomic.Int64
+	retried      atomic.Int64
+	deadLettered atomic.Int64
+	inFlight     atomic.Int64
+	// dispatching is the message that the dispatcher holds until the subscribers take it
+	dispatching atomic.Int64
+	// latency counts the handler calls per bucket, the last one is over every bucket
+	latency    []atomic.Int64
+	latencySum atomic.Int64
+}
+
+func newTopicStats() *topicStats {
+	return &topicStats{
+		latency: make([]atomic.Int64, len(LatencyBuckets)+1),
+	}
+}
+
+// observe records a handler call
+func (s *topicStats) observe(duration time.Duration, err error) {
+	if err != nil {
+		s.failed.Add(1)
+	}
+
+	bucket := len(LatencyBuckets)
+	for i, upperBound := range LatencyBuckets {
+		if duration <= upperBound {
+			bucket = i
+			break
+		}
+	}
+	s.latency[bucket].Add(1)
+	s.latencySum.Add(int64(duration))
+}
+
+// Stats are the counters of a topic since the start. Delivered and dead-lettered count the messages
+// of every subscriber, failed counts the failed handler calls and retried the calls after the first one
+type Stats struct {
+	Topic        string
+	Published    int64
+	Delivered    int64
+	Failed       int64
+	Retried      int64
+	DeadLettered int64
+	// QueueDepth is the number of the messages that wait for a handler
+	QueueDepth    int
+	QueueCapacity int
+	// Scheduled is the number of the messages that wait for their delivery time
+	Scheduled   int
+	InFlight    int64
+	Subscribers int
+	Latency     Histogram
+}
+
+// Histogram is the latency of the handler calls, the counts of the buckets are cumulative
+type Histogram struct {
+	Buckets []HistogramBucket
+	Count   int64
+	Sum     time.Duration
+}
+
+type HistogramBucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+func (s *topicStats) histogram() Histogram {
+	histogram := Histogram{
+		Buckets: make([]HistogramBucket, 0, len(LatencyBuckets)),
+		Sum:     time.Duration(s.latencySum.Load()),
+	}
+	for i, upperBound := range LatencyBuckets {
+		histogram.Count += s.latency[i].Load()
+		histogram.Buckets = append(histogram.Buckets, HistogramBucket{
+			UpperBound: upperBound,
+			Count:      histogram.Count,
+		})
+	}
+	histogram.Count += s.latency[len(LatencyBuckets)].Load()
+	return histogram
+}
@@ -0,0 +1,119 @@
+package pubsub_test
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitStats(t *testing.T, ps *pubsub.Pubsub, condition func(stats pubsub.Stats) bool) pubsub.Stats {
+	t.Helper()
+	var stats pubsub.Stats
+	require.Eventually(t, func() bool {
+		var err error
+		stats, err = ps.Stats("test-topic-1")
+		require.NoError(t, err)
+		return condition(stats)
+	}, 5*time.Second, 5*time.Millisecond)
+	return stats
+}
+
+func TestStats(t *testing.T) {
+	var retried atomic.Bool
+	ps := newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+		data, _ := io.ReadAll(r)
+		switch string(data) {
+		case "retry":
+			if retried.CompareAndSwap(false, true) {
+				return errHandler
+			}
+		case "dead":
+			return pubsub.Permanent(errHandler)
+		}
+		return nil
+	})
+
+	for _, message := range []string{"ok", "retry", "dead"} {
+		require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(message)))
+	}
+
+	stats := waitStats(t, ps, func(stats pubsub.Stats) bool {
+		return stats.Delivered+stats.DeadLettered == 3 && stats.InFlight == 0
+	})
+	assert.Equal(t, "test-topic-1", stats.Topic)
+	assert.Equal(t, int64(3), stats.Published)
+	assert.Equal(t, int64(2), stats.Delivered)
+	assert.Equal(t, int64(2), stats.Failed)
+	assert.Equal(t, int64(1), stats.Retried)
+	assert.Equal(t, int64(1), stats.DeadLettered)
+	assert.Equal(t, 0, stats.QueueDepth)
+	assert.Equal(t, 5, stats.QueueCapacity)
+	assert.Equal(t, 1, stats.Subscribers)
+
+	// every handler call is in the histogram
+	assert.Equal(t, int64(4), stats.Latency.Count)
+	require.Len(t, stats.Latency.Buckets, len(pubsub.LatencyBuckets))
+	for i, bucket := range stats.Latency.Buckets {
+		assert.Equal(t, pubsub.LatencyBuckets[i], bucket.UpperBound)
+		assert.LessOrEqual(t, bucket.Count, stats.Latency.Count)
+	}
+	assert.Equal(t, int64(4), stats.Latency.Buckets[len(stats.Latency.Buckets)-1].Count)
+
+	_, err := ps.Stats("test-topic-2")
+	assert.ErrorIs(t, err, pubsub.ErrTopicIsNotExists)
+	assert.Len(t, ps.AllStats(), 1)
+}
+
+func TestStatsQueueDepth(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []pubsub.SubscriberOption
+		key  string
+	}{
+		{
+			name: "sync",
+		},
+		{
+			name: "async keyed",
+			opts: []pubsub.SubscriberOption{pubsub.PubsubSetAsyncProcess(true)},
+			key:  "client-1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := make(chan struct{})
+			ps := pubsub.NewPubsub()
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+			ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+				<-release
+				return nil
+			}, tc.opts...)
+			require.NoError(t, ps.Listen())
+
+			for i := 0; i < 4; i++ {
+				require.NoError(t, ps.PublishMessage("test-topic-1", pubsub.Message{
+					Key:  tc.key,
+					Body: []byte("message"),
+				}))
+			}
+
+			// the first message is handled, the others wait in the queue
+			waitStats(t, ps, func(stats pubsub.Stats) bool {
+				return stats.InFlight == 1 && stats.QueueDepth == 3
+			})
+
+			close(release)
+			stats := waitStats(t, ps, func(stats pubsub.Stats) bool {
+				return stats.Delivered == 4 && stats.InFlight == 0
+			})
+			assert.Equal(t, 0, stats.QueueDepth)
+		})
+	}
+}
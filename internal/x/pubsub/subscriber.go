@@ -1,15 +1,31 @@
 package pubsub
 
 import (
+	"bytes"
+	"context"
+	"hash/fnv"
 	"io"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/google/uuid"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
 	"github.com/rs/zerolog/log"
 )
 
-type SubscriberHandler func(id string, r io.Reader)
+// SubscriberHandler consumes the message, the message is retried while the handler returns
+// an error until the max elapsed time of the subscriber, then it's moved to the dead letters.
+// The id is the message id, the key and the headers are read by ReadMessage. The context is
+// done on the timeout of the subscriber and when the shutdown gives up on the in-flight messages
+type SubscriberHandler func(ctx context.Context, id string, r io.Reader) error
+
+// Permanent wraps the error of the message that can't succeed on retry, e.g. a malformed message,
+// the message is moved to the dead letters right away
+func Permanent(err error) error {
+	return backoff.Permanent(err)
+}
 
 type SubscriberOpt func(s *subscriber)
 
@@ -38,26 +54,56 @@ func SetSubscriberRetryWaitTime(retryWaitTime time.Duration) SubscriberOpt {
 }
 
 type subscriber struct {
-	id               string
-	shutdownListener chan bool
-	async            bool
-	maxWorker        int
-	maxElapsedTime   time.Duration
-	retryWaitTime    time.Duration
-	handler          SubscriberHandler
-	message          chan io.Reader
+	id    string
+	topic string
+	// group is the consumer group, the members of a group compete for the messages
+	group string
+	// shutdown stops the workers, the messages that aren't consumed are recovered
+	// from the write-ahead log on the next start
+	shutdown <-chan bool
+	// ctx is the context of the handler calls, it's cancelled when the shutdown
+	// stops waiting for the in-flight messages
+	ctx context.Context
+	// unsubscribed stops the workers, the messages that aren't consumed are skipped
+	unsubscribed    chan struct{}
+	unsubscribeOnce sync.Once
+	listenOnce      sync.Once
+	async           bool
+	maxWorker       int
+	maxElapsedTime  time.Duration
+	retryWaitTime   time.Duration
+	// timeout is the timeout of every handler call, there's no timeout when it's zero
+	timeout time.Duration
+	handler SubscriberHandler
+	// message is the queue of the messages without a key
+	message chan delivery
+	// partitions are the queues of the keyed messages, one per worker up to a few per
+	// processor. The messages of a key are always consumed by the same worker in the sync mode
+	partitions []chan delivery
+	// keys are the pending messages of the keys in the async mode,
+	// a key is drained by one goroutine at a time
+	keysMtx sync.Mutex
+	keys    map[string][]delivery
+	// deadLetter stores the message that exhausts the retries
+	deadLetter func(deadLetter database.DeadLetter)
+	stats      *topicStats
 }
 
-func newSubscriber() *subscriber {
+func newSubscriber(ctx context.Context, topic string, shutdown <-chan bool, stats *topicStats, deadLetter func(deadLetter database.DeadLetter)) *subscriber {
 	id := uuid.New()
 	sub := &subscriber{
-		id:               id.String(),
-		shutdownListener: make(chan bool),
-		async:            false,
-		maxWorker:        1,
-		maxElapsedTime:   1 * time.Second,
-		retryWaitTime:    3 * time.Second,
-		message:          make(chan io.Reader),
+		id:             id.String(),
+		topic:          topic,
+		deadLetter:     deadLetter,
+		stats:          stats,
+		ctx:            ctx,
+		shutdown:       shutdown,
+		unsubscribed:   make(chan struct{}),
+		async:          false,
+		maxWorker:      1,
+		maxElapsedTime: 1 * time.Second,
+		retryWaitTime:  3 * time.Second,
+		keys:           make(map[string][]delivery),
 	}
 
 	if sub.maxWorker == 0 {
@@ -67,8 +113,20 @@ func newSubscriber() *subscriber {
 	return sub
 }
 
+// partitionsPerProc caps the partitions of a subscriber, the keys are hashed onto
+// the partitions whatever the number of the workers is
+const partitionsPerProc = 4
+
 type SubscriberOption func(s *subscriber)
 
+// PubsubSetSubscriberId sets the id of the subscriber, it's unique in the topic. The id is
+// used to unsubscribe and to replay the dead letters to the subscriber after a restart
+func PubsubSetSubscriberId(id string) SubscriberOption {
+	return func(ps *subscriber) {
+		ps.id = id
+	}
+}
+
 func PubsubSetMaxWorker(max int) SubscriberOption {
 	return func(ps *subscriber) {
 		ps.maxWorker = max
@@ -93,56 +151,318 @@ func PubsubSetRetryWaitTime(duration time.Duration) SubscriberOption {
 	}
 }
 
-func (s *subscriber) registerSubscriberHandler(handler SubscriberHandler, opts ...SubscriberOption) {
+// PubsubSetTimeout sets the timeout of every handler call, the call that times out is retried.
+// The handler that ignores the context keeps its worker and key until it returns
+func PubsubSetTimeout(duration time.Duration) SubscriberOption {
+	return func(ps *subscriber) {
+		ps.timeout = duration
+	}
+}
+
+// registerSubscriberHandler sets up the queues of the subscriber, every queue buffers up to the capacity
+// of the topic so a slow subscriber or key only holds the topic back when its queue is full
+func (s *subscriber) registerSubscriberHandler(handler SubscriberHandler, capacity int, opts ...SubscriberOption) {
 	for _, opt := range opts {
 		opt(s)
 	}
+	if s.maxWorker < 1 {
+		s.maxWorker = 1
+	}
 	s.handler = handler
+
+	s.message = make(chan delivery, capacity)
+	s.partitions = make([]chan delivery, min(s.maxWorker, runtime.GOMAXPROCS(0)*partitionsPerProc))
+	for i := range s.partitions {
+		s.partitions[i] = make(chan delivery, capacity)
+	}
 }
 
+// listen starts the workers once
 func (s *subscriber) listen() {
-	for i := 1; i <= s.maxWorker; i++ {
-		go s.consume()
+	s.listenOnce.Do(func() {
+		for i := 0; i < s.maxWorker; i++ {
+			// the workers after the partitions only consume the messages without a key
+			var partition chan delivery
+			if i < len(s.partitions) {
+				partition = s.partitions[i]
+			}
+			go s.consume(partition)
+		}
+	})
+}
+
+// unsubscribe stops the workers, the message in process is finished
+func (s *subscriber) unsubscribe() {
+	s.unsubscribeOnce.Do(func() {
+		close(s.unsubscribed)
+	})
+}
+
+func (s *subscriber) isUnsubscribed() bool {
+	select {
+	case <-s.unsubscribed:
+		return true
+	default:
+		return false
 	}
 }
 
-func (s *subscriber) dispatcher(data io.Reader) {
-	s.message <- data
+// delivery is the message of a subscriber, done acknowledges the message
+// after it's consumed or moved to the dead letters
+type delivery struct {
+	message Message
+	done    func()
 }
 
-func (s *subscriber) consume() {
+func (d delivery) ack() {
+	if d.done != nil {
+		d.done()
+	}
+}
+
+// dispatcher queues the message for the workers, it waits while the queue is full and gives up on
+// the shutdown, the message is recovered from the write-ahead log on the next start.
+// The message is skipped when the subscriber is unsubscribed
+func (s *subscriber) dispatcher(message delivery, shutdown <-chan bool) bool {
+	// the keyed message of the async mode is queued in the dispatched order
+	if s.async && message.message.Key != "" {
+		s.enqueue(message)
+		return true
+	}
+
+	select {
+	case s.queue(message.message.Key) <- message:
+		// the message may be queued while the workers leave
+		if s.isUnsubscribed() {
+			s.discard()
+		}
+		return true
+	case <-s.unsubscribed:
+		message.ack()
+		return true
+	case <-shutdown:
+		return false
+	}
+}
+
+// queue returns the queue of the message, the keyed message goes to the partition of its key
+func (s *subscriber) queue(key string) chan delivery {
+	if key == "" || len(s.partitions) == 0 {
+		return s.message
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return s.partitions[hash.Sum32()%uint32(len(s.partitions))]
+}
+
+func (s *subscriber) consume(partition <-chan delivery) {
 	for {
 		select {
-		case <-s.shutdownListener:
+		case <-s.shutdown:
 			return
-		case messageReader := <-s.message:
-			id := uuid.New()
-			backoffExponential := backoff.NewExponentialBackOff()
-			backoffExponential.MaxInterval = s.retryWaitTime
-			backoffExponential.MaxElapsedTime = s.maxElapsedTime
-
+		case <-s.unsubscribed:
+			s.discard()
+			return
+		case message := <-partition:
+			s.handle(message)
+		case message := <-s.message:
 			if s.async {
-				go backoff.Retry(func() error {
-					s.handler(id.String(), messageReader)
-					return nil
-				}, backoffExponential)
+				go s.handle(message)
 				continue
 			}
+			s.handle(message)
+		}
+	}
+}
 
-			err := backoff.Retry(func() error {
-				s.handler(id.String(), messageReader)
-				return nil
-			}, backoffExponential)
-			if err != nil {
-				log.Error().Err(err).Msg("error consume message")
+// discard skips the queued messages of the unsubscribed subscriber
+func (s *subscriber) discard() {
+	for _, queue := range append([]chan delivery{s.message}, s.partitions...) {
+		for discarded := false; !discarded; {
+			select {
+			case message := <-queue:
+				message.ack()
+			default:
+				discarded = true
 			}
 		}
 	}
 }
 
-func (s *subscriber) close() {
-	if s.handler == nil {
+// handle processes the message, the message that is given up by the shutdown isn't
+// acknowledged and it's recovered from the write-ahead log on the next start
+func (s *subscriber) handle(message delivery) {
+	s.stats.inFlight.Add(1)
+	settled := s.process(message.message)
+	s.stats.inFlight.Add(-1)
+	if settled {
+		message.ack()
+	}
+}
+
+// enqueue queues the keyed message of the async mode, the message is handled
+// after the previous messages of its key
+func (s *subscriber) enqueue(message delivery) {
+	key := message.message.Key
+
+	if s.isUnsubscribed() {
+		message.ack()
 		return
 	}
-	s.shutdownListener <- true
+
+	s.keysMtx.Lock()
+	pending, draining := s.keys[key]
+	s.keys[key] = append(pending, message)
+	s.keysMtx.Unlock()
+
+	if !draining {
+		go s.drain(key)
+	}
+}
+
+// pending is the number of the messages in the queues of the subscriber
+// and the keyed messages that wait for the previous message of their key
+func (s *subscriber) pending() int {
+	s.keysMtx.Lock()
+	defer s.keysMtx.Unlock()
+
+	pending := len(s.message)
+	for _, partition := range s.partitions {
+		pending += len(partition)
+	}
+	for _, messages := range s.keys {
+		pending += len(messages)
+	}
+	return pending
+}
+
+// drain handles the pending messages of the key in the queued order, it stops
+// on the shutdown and skips the pending messages after the unsubscription
+func (s *subscriber) drain(key string) {
+	for {
+		select {
+		case <-s.shutdown:
+			s.keysMtx.Lock()
+			delete(s.keys, key)
+			s.keysMtx.Unlock()
+			return
+		default:
+		}
+
+		s.keysMtx.Lock()
+		pending := s.keys[key]
+		if len(pending) == 0 || s.isUnsubscribed() {
+			delete(s.keys, key)
+			s.keysMtx.Unlock()
+			for _, message := range pending {
+				message.ack()
+			}
+			return
+		}
+		message := pending[0]
+		s.keys[key] = pending[1:]
+		s.keysMtx.Unlock()
+
+		s.handle(message)
+	}
+}
+
+// process calls the handler until it succeeds or the retries are exhausted,
+// every attempt reads the message from the start. It's false when the shutdown
+// gives up on the message before it's settled
+func (s *subscriber) process(message Message) bool {
+	backoffExponential := backoff.NewExponentialBackOff()
+	backoffExponential.MaxInterval = s.retryWaitTime
+	backoffExponential.MaxElapsedTime = s.maxElapsedTime
+	if s.retryWaitTime > 0 && backoffExponential.InitialInterval > s.retryWaitTime {
+		backoffExponential.InitialInterval = s.retryWaitTime
+	}
+
+	attempts := 0
+	err := backoff.Retry(func() error {
+		attempts++
+		start := time.Now()
+		err := s.call(message)
+		s.stats.observe(time.Since(start), err)
+		return err
+	}, backoff.WithContext(backoffExponential, s.ctx))
+	s.stats.retried.Add(int64(attempts - 1))
+	if err == nil {
+		s.stats.delivered.Add(1)
+		return true
+	}
+	if s.ctx.Err() != nil {
+		log.Warn().
+			Str("topic", s.topic).
+			Str("id", message.Id).
+			Msg("[subscriber.process] the shutdown gives up on the message, it's recovered on the next start")
+		return false
+	}
+	s.stats.deadLettered.Add(1)
+
+	log.Error().
+		Err(err).
+		Str("topic", s.topic).
+		Str("id", message.Id).
+		Str("key", message.Key).
+		Int("attempts", attempts).
+		Msg("[subscriber.process] err: retries are exhausted, move the message to the dead letters")
+
+	if s.deadLetter == nil {
+		return true
+	}
+	s.deadLetter(database.DeadLetter{
+		Id:           uuid.New().String(),
+		Topic:        s.topic,
+		SubscriberId: s.id,
+		MessageId:    message.Id,
+		Key:          message.Key,
+		Headers:      message.Headers,
+		PublishedAt:  message.PublishedAt,
+		Message:      message.Body,
+		Error:        err.Error(),
+		Attempts:     attempts,
+		FailedAt:     time.Now(),
+	})
+	return true
+}
+
+// call calls the handler with the context of the attempt. The handler that overruns the timeout
+// is waited for, so the next attempt and the next message of the key never run beside it. Only
+// the shutdown that gives up on the in-flight messages leaves the handler behind
+func (s *subscriber) call(message Message) error {
+	ctx, cancel := s.ctx, context.CancelFunc(func() {})
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, s.timeout)
+	}
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.handler(ctx, message.Id, &messageReader{
+			Reader:  bytes.NewReader(message.Body),
+			message: message,
+		})
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+	}
+
+	if s.ctx.Err() == nil {
+		log.Warn().
+			Str("topic", s.topic).
+			Str("id", message.Id).
+			Str("key", message.Key).
+			Msg("[subscriber.call] the handler overruns its timeout, the key waits until it returns")
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
 }
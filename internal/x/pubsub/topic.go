@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"strings"
+)
+
+// The topic names are hierarchical, the segments are separated by a dot, e.g. app.42.config.
+// A pattern matches a single segment with * or {name}, and one or more trailing segments with >
+const (
+	topicSeparator = "."
+	singleWildcard = "*"
+	multiWildcard  = ">"
+)
+
+// isTopicPattern tells whether the topic has a wildcard
+func isTopicPattern(topic string) bool {
+	for _, segment := range strings.Split(topic, topicSeparator) {
+		if segment == singleWildcard || segment == multiWildcard || isNamedWildcard(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNamedWildcard(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// validateTopicPattern checks the segments of the pattern, > is only the last segment
+func validateTopicPattern(pattern string) error {
+	segments := strings.Split(pattern, topicSeparator)
+	for i, segment := range segments {
+		if segment == "" {
+			return ErrInvalidTopicPattern
+		}
+		if segment == multiWildcard && i != len(segments)-1 {
+			return ErrInvalidTopicPattern
+		}
+	}
+	return nil
+}
+
+// MatchTopic tells whether the topic matches the pattern, the values
+// of the named segments of the pattern are returned
+func MatchTopic(pattern, topic string) (map[string]string, bool) {
+	patternSegments := strings.Split(pattern, topicSeparator)
+	topicSegments := strings.Split(topic, topicSeparator)
+
+	params := make(map[string]string)
+	for i, segment := range patternSegments {
+		if segment == multiWildcard {
+			return params, i < len(topicSegments)
+		}
+		if i >= len(topicSegments) {
+			return nil, false
+		}
+
+		switch {
+		case segment == singleWildcard:
+		case isNamedWildcard(segment):
+			params[segment[1:len(segment)-1]] = topicSegments[i]
+		case segment != topicSegments[i]:
+			return nil, false
+		}
+	}
+
+	if len(patternSegments) != len(topicSegments) {
+		return nil, false
+	}
+	return params, true
+}
@@ -0,0 +1,165 @@
+package pubsub_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTopic(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pattern  string
+		topic    string
+		matched  bool
+		expected map[string]string
+	}{
+		{
+			name:     "single wildcard",
+			pattern:  "config.*",
+			topic:    "config.app",
+			matched:  true,
+			expected: map[string]string{},
+		},
+		{
+			name:    "single wildcard matches one segment",
+			pattern: "config.*",
+			topic:   "config.app.key",
+		},
+		{
+			name:    "single wildcard needs the segment",
+			pattern: "config.*",
+			topic:   "config",
+		},
+		{
+			name:     "named wildcard",
+			pattern:  "app.{id}.config",
+			topic:    "app.42.config",
+			matched:  true,
+			expected: map[string]string{"id": "42"},
+		},
+		{
+			name:     "multi wildcard",
+			pattern:  "app.{id}.>",
+			topic:    "app.42.config.key",
+			matched:  true,
+			expected: map[string]string{"id": "42"},
+		},
+		{
+			name:    "multi wildcard needs a segment",
+			pattern: "app.{id}.>",
+			topic:   "app.42",
+		},
+		{
+			name:    "different segment",
+			pattern: "app.*.config",
+			topic:   "app.42.webhook",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params, matched := pubsub.MatchTopic(tc.pattern, tc.topic)
+			assert.Equal(t, tc.matched, matched)
+			if tc.matched {
+				assert.Equal(t, tc.expected, params)
+			}
+		})
+	}
+}
+
+func TestPatternSubscriber(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("app.1.config", pubsub.PubsubSetMaxBufferCapacity(5))
+	ps.TopicRegister("app.1.webhook", pubsub.PubsubSetMaxBufferCapacity(5))
+	require.NoError(t, ps.Listen())
+
+	type received struct {
+		topic string
+		id    string
+		body  string
+	}
+	messages := make(chan received, 5)
+	require.NoError(t, ps.ConsumerRegister("app.{id}.config", func(ctx context.Context, id string, r io.Reader) error {
+		message, ok := pubsub.ReadMessage(r)
+		require.True(t, ok)
+		params, _ := pubsub.MatchTopic("app.{id}.config", message.Topic)
+		messages <- received{topic: message.Topic, id: params["id"], body: string(message.Body)}
+		return nil
+	}, pubsub.PubsubSetSubscriberId("config")))
+
+	ids, err := ps.Subscribers("app.{id}.config")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config"}, ids)
+
+	wait := func() received {
+		select {
+		case message := <-messages:
+			return message
+		case <-time.After(5 * time.Second):
+			t.Fatal("the message isn't received")
+			return received{}
+		}
+	}
+
+	// the registered topic and the topic that is registered later are subscribed
+	require.NoError(t, ps.Publish("app.1.config", pubsub.SendString("1")))
+	assert.Equal(t, received{topic: "app.1.config", id: "1", body: "1"}, wait())
+
+	ps.TopicRegister("app.2.config", pubsub.PubsubSetMaxBufferCapacity(5))
+	require.NoError(t, ps.Publish("app.2.config", pubsub.SendString("2")))
+	assert.Equal(t, received{topic: "app.2.config", id: "2", body: "2"}, wait())
+
+	assert.ErrorIs(t, ps.Publish("app.1.webhook", pubsub.SendString("3")), pubsub.ErrConsumerIsNotExists)
+
+	// the unsubscribed pattern is removed from the matched topics
+	require.NoError(t, ps.Unsubscribe("app.{id}.config", "config"))
+	for _, topic := range []string{"app.1.config", "app.2.config"} {
+		ids, err := ps.Subscribers(topic)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	}
+	ps.TopicRegister("app.3.config", pubsub.PubsubSetMaxBufferCapacity(5))
+	ids, err = ps.Subscribers("app.3.config")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	testCases := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{
+			name: "multi wildcard in the middle",
+			call: func() error {
+				return ps.ConsumerRegister("app.>.config", receiver(make(chan string)))
+			},
+			expected: pubsub.ErrInvalidTopicPattern,
+		},
+		{
+			name: "empty segment",
+			call: func() error {
+				return ps.ConsumerRegister("app..*", receiver(make(chan string)))
+			},
+			expected: pubsub.ErrInvalidTopicPattern,
+		},
+		{
+			name: "unknown pattern subscriber",
+			call: func() error {
+				return ps.Unsubscribe("app.{id}.config", "config")
+			},
+			expected: pubsub.ErrSubscriberIsNotExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.call(), tc.expected)
+		})
+	}
+}
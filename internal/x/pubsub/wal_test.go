@@ -0,0 +1,297 @@
+package pubsub_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+	"github.com/ostafen/clover"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitWriteAheadLog(t *testing.T, read func() database.Databaser, expected ...string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		backups, err := read().Retrieve("test-topic-1")
+		require.NoError(t, err)
+
+		messages := []string{}
+		for _, backup := range backups {
+			messages = append(messages, string(backup.Message))
+		}
+		return assert.ObjectsAreEqual(append([]string{}, expected...), messages)
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+// crashCopy copies the files of the directory while the pubsub still holds them open, the
+// copy is what the disk has when the process is killed without closing anything
+func crashCopy(t *testing.T, dir string) string {
+	t.Helper()
+	crashed := t.TempDir()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		copySparse(t, filepath.Join(dir, entry.Name()), filepath.Join(crashed, entry.Name()))
+	}
+	return crashed
+}
+
+// copySparse copies the file and skips the zeroed blocks, the preallocated files of badger
+// stay small in the copy
+func copySparse(t *testing.T, src, dst string) {
+	t.Helper()
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer in.Close()
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+
+	var (
+		size  int64
+		block = make([]byte, 1<<20)
+		zero  = make([]byte, len(block))
+	)
+	for {
+		n, err := io.ReadFull(in, block)
+		if n > 0 {
+			if bytes.Equal(block[:n], zero[:n]) {
+				_, err := out.Seek(int64(n), io.SeekCurrent)
+				require.NoError(t, err)
+			} else {
+				_, err := out.Write(block[:n])
+				require.NoError(t, err)
+			}
+			size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.NoError(t, out.Truncate(size))
+}
+
+// backupDriver opens the write-ahead log of the directory, read returns the database that sees
+// the writes of the pubsub and crash returns the directory as it's left by a killed process
+type backupDriver struct {
+	name string
+	open func(t *testing.T, dir string) (opt pubsub.PubsubOption, read func() database.Databaser, crash func() string)
+}
+
+var backupDrivers = []backupDriver{
+	{
+		name: "clover",
+		open: func(t *testing.T, dir string) (pubsub.PubsubOption, func() database.Databaser, func() string) {
+			cloverDB, err := clover.Open(dir)
+			require.NoError(t, err)
+			t.Cleanup(func() { cloverDB.Close() })
+			db := database.NewCloverDatabase(cloverDB)
+			return pubsub.SetCloverForBackup(cloverDB), func() database.Databaser { return db }, func() string {
+				crashed := crashCopy(t, dir)
+				// the copy is taken, the instance is only closed to free its memory
+				require.NoError(t, cloverDB.Close())
+				return crashed
+			}
+		},
+	},
+	{
+		name: "file",
+		open: func(t *testing.T, dir string) (pubsub.PubsubOption, func() database.Databaser, func() string) {
+			// the segments are cached by the instance, a new one reads the writes of the pubsub
+			return pubsub.SetFileForBackup(dir), func() database.Databaser { return database.NewFileDatabaseReader(dir) }, func() string {
+				crashed := crashCopy(t, dir)
+
+				// the process is killed while it's appending a record
+				segment, err := os.OpenFile(filepath.Join(crashed, "test-topic-1.wal"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+				require.NoError(t, err)
+				_, err = segment.WriteString(`{"op":"store","backup":{"sequenceId":`)
+				require.NoError(t, err)
+				require.NoError(t, segment.Close())
+				return crashed
+			}
+		},
+	},
+}
+
+func TestWriteAheadLogRecovery(t *testing.T) {
+	for _, driver := range backupDrivers {
+		t.Run(driver.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// the consumer acknowledges "1" and never finishes "2", "3" is still queued
+			opt, read, crash := driver.open(t, dir)
+			ps := pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+			ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+				data, _ := io.ReadAll(r)
+				if string(data) == "2" {
+					select {}
+				}
+				return nil
+			})
+			require.NoError(t, ps.Listen())
+
+			for _, message := range []string{"1", "2", "3"} {
+				require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString(message)))
+			}
+			waitWriteAheadLog(t, read, "2", "3")
+
+			// the process is killed, the pubsub isn't shut down
+			opt, read, _ = driver.open(t, crash())
+			received := make(chan string, 10)
+			ps = pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(10))
+			ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+				data, _ := io.ReadAll(r)
+				received <- string(data)
+				return nil
+			})
+			require.NoError(t, ps.Listen())
+
+			// the new message is consumed after the recovered messages
+			require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("4")))
+			for _, expected := range []string{"2", "3", "4"} {
+				assert.Equal(t, expected, waitReceived(t, received))
+			}
+
+			waitWriteAheadLog(t, read)
+		})
+	}
+}
+
+func TestWriteAheadLogSequence(t *testing.T) {
+	dir := t.TempDir()
+	cloverDB, err := clover.Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { cloverDB.Close() })
+
+	db := database.NewCloverDatabase(cloverDB)
+	for _, message := range []string{"1", "2", "3"} {
+		_, err := db.Store(database.Backup{Topic: "test-topic-1", Message: []byte(message)})
+		require.NoError(t, err)
+	}
+	_, err = db.Store(database.Backup{Topic: "test-topic-2", Message: []byte("other")})
+	require.NoError(t, err)
+
+	backups, err := db.Retrieve("test-topic-1")
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	for i, backup := range backups {
+		assert.Equal(t, int64(i+1), backup.SequenceId)
+	}
+
+	require.NoError(t, db.Delete("test-topic-1", backups[1].SequenceId))
+	waitWriteAheadLog(t, func() database.Databaser { return db }, "1", "3")
+
+	// the sequence continues after the stored backups
+	reopened := database.NewCloverDatabase(cloverDB)
+	backup, err := reopened.Store(database.Backup{Topic: "test-topic-1", Message: []byte("4")})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), backup.SequenceId)
+}
+
+func TestWriteAheadLogSpill(t *testing.T) {
+	for _, driver := range backupDrivers {
+		t.Run(driver.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// the topic has no consumer, the messages after the second one only fit in the log
+			opt, read, crash := driver.open(t, dir)
+			ps := pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(2))
+			for _, message := range []string{"1", "2", "3", "4"} {
+				err := ps.Publish("test-topic-1", pubsub.SendString(message))
+				require.ErrorIs(t, err, pubsub.ErrConsumerIsNotExists)
+			}
+			assert.Equal(t, 4, ps.Len("test-topic-1"))
+			waitWriteAheadLog(t, read, "1", "2", "3", "4")
+
+			// the recovered messages don't fit in the queue, the publisher doesn't wait for them
+			opt, read, _ = driver.open(t, crash())
+			ps = pubsub.NewPubsub(opt)
+			ps.TopicRegister("test-topic-1", pubsub.PubsubSetMaxBufferCapacity(2))
+			published := make(chan error, 1)
+			go func() {
+				published <- ps.Publish("test-topic-1", pubsub.SendString("5"))
+			}()
+			select {
+			case err := <-published:
+				require.ErrorIs(t, err, pubsub.ErrConsumerIsNotExists)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the publisher waits for the full topic")
+			}
+
+			// the consumer receives the spilled messages in the published order
+			received := make(chan string, 10)
+			ps.ConsumerRegister("test-topic-1", func(ctx context.Context, id string, r io.Reader) error {
+				data, _ := io.ReadAll(r)
+				received <- string(data)
+				return nil
+			})
+			require.NoError(t, ps.Listen())
+			require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("6")))
+			for _, expected := range []string{"1", "2", "3", "4", "5", "6"} {
+				assert.Equal(t, expected, waitReceived(t, received))
+			}
+
+			waitWriteAheadLog(t, read)
+		})
+	}
+}
+
+func TestWriteAheadLogReplay(t *testing.T) {
+	for _, driver := range backupDrivers {
+		t.Run(driver.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// the message is a dead letter, its replay is never finished
+			var replaying atomic.Bool
+			opt, read, crash := driver.open(t, dir)
+			ps := newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+				if replaying.Load() {
+					select {}
+				}
+				return pubsub.Permanent(errHandler)
+			}, opt)
+			require.NoError(t, ps.Publish("test-topic-1", pubsub.SendString("1")))
+			waitDeadLetters(t, ps, 1)
+			waitWriteAheadLog(t, read)
+
+			// the dead letter is removed after its message is in the write-ahead log
+			replaying.Store(true)
+			replayed, err := ps.ReplayDeadLetters("test-topic-1")
+			require.NoError(t, err)
+			assert.Equal(t, 1, replayed)
+			waitDeadLetters(t, ps, 0)
+			backups, err := read().Retrieve("test-topic-1")
+			require.NoError(t, err)
+			require.Len(t, backups, 1)
+			assert.Equal(t, "1", string(backups[0].Message))
+			assert.NotEmpty(t, backups[0].SubscriberId)
+
+			// the replayed message is recovered after a crash
+			opt, read, _ = driver.open(t, crash())
+			received := make(chan string, 10)
+			newRetryPubsub(t, func(ctx context.Context, id string, r io.Reader) error {
+				data, _ := io.ReadAll(r)
+				received <- string(data)
+				return nil
+			}, opt)
+			assert.Equal(t, "1", waitReceived(t, received))
+			waitWriteAheadLog(t, read)
+		})
+	}
+}
@@ -4,14 +4,18 @@ package main
 
 import (
 	"context"
+	"os"
 	"runtime"
+	"time"
 
 	"github.com/nurcahyaari/coma/config"
 	"github.com/nurcahyaari/coma/container"
 	"github.com/nurcahyaari/coma/infrastructure/database"
 	"github.com/nurcahyaari/coma/infrastructure/integration/coma"
+	"github.com/nurcahyaari/coma/internal/agent"
 	"github.com/nurcahyaari/coma/internal/graceful"
 	"github.com/nurcahyaari/coma/internal/logger"
+	"github.com/nurcahyaari/coma/internal/protocols/grpc"
 	"github.com/nurcahyaari/coma/internal/protocols/http"
 	httprouter "github.com/nurcahyaari/coma/internal/protocols/http/router"
 	"github.com/nurcahyaari/coma/internal/x/pubsub"
@@ -19,10 +23,14 @@ import (
 	applicationsvc "github.com/nurcahyaari/coma/src/application/application/service"
 	authrepo "github.com/nurcahyaari/coma/src/application/auth/repository"
 	authsvc "github.com/nurcahyaari/coma/src/application/auth/service"
+	signingsvc "github.com/nurcahyaari/coma/src/application/signing/service"
 	userrepo "github.com/nurcahyaari/coma/src/application/user/repository"
 	usersvc "github.com/nurcahyaari/coma/src/application/user/service"
+	webhookrepo "github.com/nurcahyaari/coma/src/application/webhook/repository"
+	webhooksvc "github.com/nurcahyaari/coma/src/application/webhook/service"
 	"github.com/rs/zerolog/log"
 
+	grpchandler "github.com/nurcahyaari/coma/src/handlers/grpc"
 	httphandler "github.com/nurcahyaari/coma/src/handlers/http"
 	"github.com/nurcahyaari/coma/src/handlers/localpubsub"
 	websockethandler "github.com/nurcahyaari/coma/src/handlers/websocket"
@@ -32,16 +40,22 @@ import (
 //@in header
 //@name Authorization
 
-func initHttpProtocol(cfg config.Config, c container.Service) *http.Http {
+func initHttpProtocol(cfg config.Config, c container.Service, localPubsubHandler *localpubsub.LocalPubsub) *http.Http {
 	handler := httphandler.NewHttpHandler(c)
 
-	websocketHandler := websockethandler.NewWebsocketHandler(c)
+	websocketHandler := websockethandler.NewWebsocketHandler(&cfg, c)
 	router := httprouter.NewHttpRouter(
 		handler,
-		websocketHandler)
+		websocketHandler,
+		localPubsubHandler)
 	return http.New(cfg, router)
 }
 
+func initGrpcProtocol(cfg config.Config, c container.Service) *grpc.Grpc {
+	handler := grpchandler.NewGrpcHandler(&cfg, c)
+	return grpc.New(cfg, handler)
+}
+
 func initDependencies(cfg config.Config) container.Container {
 	var (
 		c = container.Container{
@@ -70,23 +84,30 @@ func initDependencies(cfg config.Config) container.Container {
 	authRepo := authrepo.New(cloverDB)
 	applicationRepo := applicationrepo.New(cloverDB)
 	userRepo := userrepo.New(cloverDB)
+	webhookRepo := webhookrepo.New(cloverDB)
 
 	containerRepo := container.Repository{
-		RepositoryAuthReader:                     authRepo.NewRepositoryReader(),
-		RepositoryAuthWriter:                     authRepo.NewRepositoryWriter(),
-		AuthRepositorier:                         authRepo,
-		RepositoryApplicationWriter:              applicationRepo.NewRepositoryApplicationWriter(),
-		RepositoryApplicationReader:              applicationRepo.NewRepositoryApplicationReader(),
-		RepositoryApplicationKeyWriter:           applicationRepo.NewRepositoryApplicationKeyWriter(),
-		RepositoryApplicationKeyReader:           applicationRepo.NewRepositoryApplicationKeyReader(),
-		RepositoryApplicationConfigurationWriter: applicationRepo.NewRepositoryApplicationConfigurationWriter(),
-		RepositoryApplicationConfigurationReader: applicationRepo.NewRepositoryApplicationConfigurationReader(),
-		RepositoryUserWriter:                     userRepo.NewRepositoryUserWriter(),
-		RepositoryUserReader:                     userRepo.NewRepositoryUserReader(),
-		RepositoryUserApplicationScopeWriter:     userRepo.NewRepositoryUserApplicationScopeWriter(),
-		RepositoryUserApplicationScopeReader:     userRepo.NewRepositoryUserApplicationScopeReader(),
-		RepositoryUserAuthReader:                 authRepo.NewRepositoryUserAuthReader(),
-		RepositoryUserAuthWriter:                 authRepo.NewRepositoryUserAuthWriter(),
+		RepositoryAuthReader:                             authRepo.NewRepositoryReader(),
+		RepositoryAuthWriter:                             authRepo.NewRepositoryWriter(),
+		AuthRepositorier:                                 authRepo,
+		RepositoryApplicationWriter:                      applicationRepo.NewRepositoryApplicationWriter(),
+		RepositoryApplicationReader:                      applicationRepo.NewRepositoryApplicationReader(),
+		RepositoryApplicationKeyWriter:                   applicationRepo.NewRepositoryApplicationKeyWriter(),
+		RepositoryApplicationKeyReader:                   applicationRepo.NewRepositoryApplicationKeyReader(),
+		RepositoryApplicationConfigurationWriter:         applicationRepo.NewRepositoryApplicationConfigurationWriter(),
+		RepositoryApplicationConfigurationReader:         applicationRepo.NewRepositoryApplicationConfigurationReader(),
+		RepositoryApplicationConfigurationOverrideWriter: applicationRepo.NewRepositoryApplicationConfigurationOverrideWriter(),
+		RepositoryApplicationConfigurationOverrideReader: applicationRepo.NewRepositoryApplicationConfigurationOverrideReader(),
+		RepositoryUserWriter:                             userRepo.NewRepositoryUserWriter(),
+		RepositoryUserReader:                             userRepo.NewRepositoryUserReader(),
+		RepositoryUserApplicationScopeWriter:             userRepo.NewRepositoryUserApplicationScopeWriter(),
+		RepositoryUserApplicationScopeReader:             userRepo.NewRepositoryUserApplicationScopeReader(),
+		RepositoryUserAuthReader:                         authRepo.NewRepositoryUserAuthReader(),
+		RepositoryUserAuthWriter:                         authRepo.NewRepositoryUserAuthWriter(),
+		RepositoryWebhookWriter:                          webhookRepo.NewRepositoryWebhookWriter(),
+		RepositoryWebhookReader:                          webhookRepo.NewRepositoryWebhookReader(),
+		RepositoryWebhookDeliveryWriter:                  webhookRepo.NewRepositoryWebhookDeliveryWriter(),
+		RepositoryWebhookDeliveryReader:                  webhookRepo.NewRepositoryWebhookDeliveryReader(),
 	}
 	if err := containerRepo.Validate(); err != nil {
 		log.Fatal().Errs("error", err).Msg("container repository")
@@ -116,6 +137,13 @@ func initDependencies(cfg config.Config) container.Container {
 	c.Service.UserApplicationScopeServicer = userApplicationScopeSvc
 	c.Service.InternalUserApplicationScopeServicer = userApplicationScopeSvc
 
+	webhookSvc := webhooksvc.NewWebhookService(&cfg, c)
+	c.Service.WebhookServicer = webhookSvc
+	c.Service.InternalWebhookServicer = webhookSvc
+
+	signingSvc := signingsvc.NewSigningService(&cfg, c)
+	c.Service.SigningServicer = signingSvc
+
 	userAuthSvc := authsvc.NewUserAuthService(&cfg, c)
 	c.Service.AuthServicer = userAuthSvc
 	c.Service.LocalUserAuthServicer = userAuthSvc
@@ -127,8 +155,39 @@ func initDependencies(cfg config.Config) container.Container {
 	return c
 }
 
+// runAgent runs "coma agent", the sidecar that renders the configuration to files
+func runAgent(ctx context.Context, args []string) {
+	cfg, err := agent.ParseConfig(args)
+	if err != nil {
+		log.Fatal().Err(err).Msg("agent config")
+	}
+
+	a, err := agent.New(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("agent")
+	}
+
+	go a.Run()
+
+	graceful.GracefulShutdown(
+		ctx,
+		graceful.RequestGraceful{
+			ShutdownPeriod: 10 * time.Second,
+			Operations: map[string]graceful.Operation{
+				"agent": a.Shutdown,
+			},
+		},
+	)
+}
+
 func main() {
 	logger.InitLogger()
+
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent(context.Background(), os.Args[2:])
+		return
+	}
+
 	goos := runtime.GOOS
 
 	log.Info().Msgf("Running on operating system: %s\n", goos)
@@ -142,14 +201,23 @@ func main() {
 
 	localPubsubHandler := localpubsub.NewLocalPubsub(&cfg, c)
 
-	httpProtocol := initHttpProtocol(cfg, *c.Service)
+	httpProtocol := initHttpProtocol(cfg, *c.Service, localPubsubHandler)
+	grpcProtocol := initGrpcProtocol(cfg, *c.Service)
 
 	// init http protocol
 	go httpProtocol.Listen()
 
+	// init grpc protocol
+	go grpcProtocol.Listen()
+
 	// init other protocols here
 	go c.Integration.Coma.Connect()
 
+	// the pending webhook deliveries are attempted in the background, the attempts
+	// that are cut off by the shutdown are resumed on the next start
+	webhookCtx, stopWebhookDeliveries := context.WithCancel(ctx)
+	go c.Service.InternalWebhookServicer.RunWebhookDeliveries(webhookCtx)
+
 	localPubsubHandler.TopicRegistry()
 
 	// listen local pubsub
@@ -162,7 +230,12 @@ func main() {
 			Operations: map[string]graceful.Operation{
 				// place your service that need to graceful shutdown here
 				"http":        httpProtocol.Shutdown,
+				"grpc":        grpcProtocol.Shutdown,
 				"localPubsub": c.Event.LocalPubsub.Shutdown,
+				"webhookDeliveries": func(ctx context.Context) error {
+					stopWebhookDeliveries()
+					return nil
+				},
 			},
 		},
 	)
@@ -0,0 +1,530 @@
+// Package client is the Go client of the coma server. It keeps a websocket
+// connection to the server, reconnects with backoff and serves the last
+// received configuration, so the application keeps working while the server
+// is unreachable.
+//
+//	// the public key of GET /v1/signing-keys, it's fetched by the client on a wss:// url
+//	verificationKey, err := signature.NewVerificationKey(publicKey)
+//	c, err := client.New("ws://127.0.0.1:5898/websocket", key,
+//		client.SetVerificationKeys(verificationKey),
+//		client.SetCacheDir("/var/cache/app"))
+//	c.Start()
+//	defer c.Close(context.Background())
+//
+//	var cfg AppConfig
+//	err = c.Get(ctx, &cfg)
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/nurcahyaari/coma/internal/x/file"
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	SdkName = "coma-go"
+	// Version is released together with the server, the client only speaks
+	// the protocol of the server on the same version
+	Version = "1.0.0"
+)
+
+var (
+	ErrEmptyUrl           = errors.New("err: url cannot be empty")
+	ErrEmptyKey           = errors.New("err: key cannot be empty")
+	ErrEmptyConfiguration = errors.New("err: configuration is empty")
+)
+
+type Client struct {
+	url          string
+	key          string
+	originUrl    string
+	instanceId   string
+	hostname     string
+	labels       []string
+	sdkName      string
+	sdkVersion   string
+	cacheDir     string
+	retryMaxWait time.Duration
+	// reportInterval is the interval of the applied revision report, it's disabled when negative
+	reportInterval time.Duration
+	codec          codec.Codec
+	verification   verification
+
+	mtx       sync.RWMutex
+	snapshot  Snapshot
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	// notifyMtx serializes the watchers, so every watcher sees the revisions in order
+	notifyMtx  sync.Mutex
+	watcherMtx sync.Mutex
+	watchers   map[int]*watcher
+	watcherId  int
+
+	startOnce sync.Once
+	started   atomic.Bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+type watcher struct {
+	fn      func(Snapshot)
+	stopped atomic.Bool
+	// revision is the last notified revision, it's guarded by notifyMtx
+	revision string
+}
+
+// call skips the revision that is already seen, it happens when
+// the watcher is added while the revision is being notified
+func (w *watcher) call(snapshot Snapshot) {
+	if w.stopped.Load() {
+		return
+	}
+	if snapshot.Revision != "" && snapshot.Revision == w.revision {
+		return
+	}
+	w.revision = snapshot.Revision
+	w.fn(snapshot)
+}
+
+// New creates the client of an application key, the url is the websocket
+// endpoint of the server, e.g. ws://127.0.0.1:5898/websocket
+func New(serverUrl, key string, opts ...Option) (*Client, error) {
+	if serverUrl == "" {
+		return nil, ErrEmptyUrl
+	}
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+
+	hostname, _ := os.Hostname()
+	c := &Client{
+		url:            serverUrl,
+		key:            key,
+		instanceId:     hostname,
+		hostname:       hostname,
+		sdkName:        SdkName,
+		sdkVersion:     Version,
+		retryMaxWait:   30 * time.Second,
+		reportInterval: 30 * time.Second,
+		codec:          codec.Default,
+		verification: verification{
+			httpClient: &http.Client{},
+		},
+		ready:    make(chan struct{}),
+		watchers: make(map[int]*watcher),
+		done:     make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if _, err := codec.Parse(string(c.codec.Encoding), string(c.codec.Compression)); err != nil {
+		return nil, err
+	}
+
+	if c.originUrl == "" {
+		originUrl, err := defaultOriginUrl(serverUrl)
+		if err != nil {
+			return nil, err
+		}
+		c.originUrl = originUrl
+	}
+
+	if len(c.verification.pinnedKeys) > 0 {
+		verifier, err := signature.NewVerifier(c.verification.pinnedKeys...)
+		if err != nil {
+			return nil, err
+		}
+		c.verification.verifier = verifier
+	}
+	if c.verification.keysUrl == "" {
+		keysUrl, err := defaultSigningKeysUrl(serverUrl)
+		if err != nil {
+			return nil, err
+		}
+		c.verification.keysUrl = keysUrl
+	}
+	// the fetched keys are trusted, they must come from the server that is authenticated by tls
+	if len(c.verification.pinnedKeys) == 0 {
+		u, err := url.Parse(c.verification.keysUrl)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "https" {
+			return nil, ErrInsecureKeysUrl
+		}
+	}
+
+	return c, nil
+}
+
+func defaultOriginUrl(serverUrl string) (string, error) {
+	u, err := url.Parse(serverUrl)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "http"
+	if u.Scheme == "wss" || u.Scheme == "https" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/", scheme, u.Host), nil
+}
+
+// Start renders the cached configuration, then connects to the server
+// in the background until the client is closed
+func (c *Client) Start() {
+	c.startOnce.Do(func() {
+		c.started.Store(true)
+		go c.run()
+	})
+}
+
+// Close stops the connection, the last configuration can still be read
+func (c *Client) Close(ctx context.Context) error {
+	c.cancel()
+	if !c.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Snapshot waits until the first configuration is received from the server
+// or the cache, use the context to limit the wait
+func (c *Client) Snapshot(ctx context.Context) (Snapshot, error) {
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		return Snapshot{}, ctx.Err()
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.snapshot, nil
+}
+
+// Get decodes the configuration into v
+func (c *Client) Get(ctx context.Context, v any) error {
+	snapshot, err := c.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	return snapshot.Unmarshal(v)
+}
+
+// Lookup decodes a single top level field of the configuration into v
+func (c *Client) Lookup(ctx context.Context, field string, v any) error {
+	snapshot, err := c.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	return snapshot.Lookup(field, v)
+}
+
+// Revision returns the revision of the current configuration,
+// it's empty before the first configuration is received
+func (c *Client) Revision() string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.snapshot.Revision
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+
+	if err := c.restoreCache(); err != nil {
+		log.Warn().Err(err).Msg("[Client.run] cached configuration is not restored")
+	}
+
+	retry := backoff.NewExponentialBackOff()
+	retry.MaxInterval = c.retryMaxWait
+	retry.MaxElapsedTime = 0
+
+	for {
+		err := c.listen(retry.Reset)
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		wait := retry.NextBackOff()
+		log.Warn().
+			Err(err).
+			Dur("retry", wait).
+			Msg("[Client.run] server is unreachable, keep serving the last configuration")
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) dialUrl() (string, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("authorization", c.key)
+	query.Set("sdk", c.sdkName)
+	query.Set("sdkVersion", c.sdkVersion)
+	query.Set("instanceId", c.instanceId)
+	query.Set("hostname", c.hostname)
+	for _, label := range c.labels {
+		query.Add("label", label)
+	}
+	if c.codec != codec.Default {
+		query.Set("encoding", string(c.codec.Encoding))
+		query.Set("compression", string(c.codec.Compression))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// listen receives the configuration until the connection is lost
+func (c *Client) listen(onConnected func()) error {
+	target, err := c.dialUrl()
+	if err != nil {
+		return err
+	}
+
+	conn, err := websocket.Dial(target, "", c.originUrl)
+	if err != nil {
+		// the dial error contains the url, don't log the key
+		var dialErr *websocket.DialError
+		if errors.As(err, &dialErr) {
+			return dialErr.Err
+		}
+		return err
+	}
+	defer conn.Close()
+
+	log.Info().
+		Str("url", c.url).
+		Msg("[Client.listen] connected to the server")
+	onConnected()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	updated := make(chan struct{}, 1)
+	go c.reporter(conn, updated, stop)
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return err
+		}
+
+		if err := c.update(data, true); err != nil {
+			log.Error().
+				Err(err).
+				Msg("[Client.listen] configuration is not applied, keep serving the last configuration")
+			continue
+		}
+
+		select {
+		case updated <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reporter reports the applied revision to the server after every update and on the
+// interval, so the server can detect the drift. It's the only writer of the connection
+func (c *Client) reporter(conn *websocket.Conn, updated <-chan struct{}, stop <-chan struct{}) {
+	if c.reportInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.reportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-updated:
+		case <-ticker.C:
+		}
+
+		revision := c.Revision()
+		if revision == "" {
+			continue
+		}
+
+		message, err := c.codec.Marshal(report{
+			Type:         MessageTypeReport,
+			Subscription: DefaultSubscription,
+			Revision:     revision,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("[Client.reporter] err: marshaling report")
+			return
+		}
+		if err := websocket.Message.Send(conn, message); err != nil {
+			log.Warn().Err(err).Msg("[Client.reporter] err: send report")
+			return
+		}
+	}
+}
+
+func (c *Client) cachePath() string {
+	sum := sha256.Sum256([]byte(c.key))
+	return filepath.Join(c.cacheDir, fmt.Sprintf("%s.json", hex.EncodeToString(sum[:8])))
+}
+
+func (c *Client) restoreCache() error {
+	if c.cacheDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.cachePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("path", c.cachePath()).
+		Msg("[Client.restoreCache] restore the cached configuration")
+	return c.update(data, false)
+}
+
+// decode reads the message of the negotiated codec, the data of the binary encodings
+// is converted to its canonical JSON, so the snapshot and the cache are always JSON
+func (c *Client) decode(data []byte) (Message, []byte, error) {
+	var message Message
+	if c.codec == codec.Default {
+		err := json.Unmarshal(data, &message)
+		return message, data, err
+	}
+
+	if c.codec.IsJSON() {
+		if err := c.codec.Unmarshal(data, &message); err != nil {
+			return Message{}, nil, err
+		}
+	} else {
+		var binary binaryMessage
+		if err := c.codec.Unmarshal(data, &binary); err != nil {
+			return Message{}, nil, err
+		}
+		message = Message{
+			Type:         binary.Type,
+			Subscription: binary.Subscription,
+			ClientKey:    binary.ClientKey,
+			Revision:     binary.Revision,
+			Signature:    binary.Signature,
+			KeyId:        binary.KeyId,
+			IssuedAt:     binary.IssuedAt,
+		}
+		if binary.Data != nil {
+			canonical, err := signature.CanonicalValue(binary.Data)
+			if err != nil {
+				return Message{}, nil, err
+			}
+			message.Data = canonical
+		}
+	}
+
+	data, err := json.Marshal(message)
+	return message, data, err
+}
+
+// update stores the configuration and notifies the watchers, the configuration
+// from the server is verified then cached, the cache is written after the verification.
+// It's only called by the run goroutine
+func (c *Client) update(data []byte, fromServer bool) error {
+	var (
+		message Message
+		err     error
+	)
+	if fromServer {
+		message, data, err = c.decode(data)
+	} else {
+		err = json.Unmarshal(data, &message)
+	}
+	if err != nil {
+		return err
+	}
+	if message.Type != "" && message.Type != MessageTypeConfiguration {
+		return nil
+	}
+	if len(message.Data) == 0 {
+		return ErrEmptyConfiguration
+	}
+	// the configuration that isn't signed by the server for the key is never applied
+	if fromServer {
+		if err := c.verify(message); err != nil {
+			return err
+		}
+	}
+
+	snapshot := Snapshot{
+		Revision: message.Revision,
+		Data:     message.Data,
+	}
+
+	c.mtx.Lock()
+	if message.Revision != "" && message.Revision == c.snapshot.Revision {
+		c.mtx.Unlock()
+		return nil
+	}
+	c.snapshot = snapshot
+	c.mtx.Unlock()
+	c.verification.issuedAt = message.IssuedAt
+
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+
+	if fromServer && c.cacheDir != "" {
+		if err := file.WriteAtomic(c.cachePath(), data, 0600); err != nil {
+			log.Warn().Err(err).Msg("[Client.update] configuration is not cached")
+		}
+	}
+
+	c.notify(snapshot)
+
+	return nil
+}
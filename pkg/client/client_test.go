@@ -0,0 +1,642 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/container"
+	"github.com/nurcahyaari/coma/pkg/client"
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	signingsvc "github.com/nurcahyaari/coma/src/application/signing/service"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/service"
+	httphandler "github.com/nurcahyaari/coma/src/handlers/http"
+	websockethandler "github.com/nurcahyaari/coma/src/handlers/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	clientKey     = "client-key"
+	internalToken = "internal-token"
+)
+
+var signingKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+
+type appConfig struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+type fakeApplicationKeyService struct {
+	service.ApplicationKeyServicer
+}
+
+func (fakeApplicationKeyService) InternalFindApplicationKey(ctx context.Context, request dto.RequestInternalFindApplicationKey) (entity.ApplicationKey, error) {
+	if request.Key != clientKey {
+		return entity.ApplicationKey{}, nil
+	}
+	return entity.ApplicationKey{
+		Id:            "key-id",
+		ApplicationId: "application-id",
+		Key:           clientKey,
+	}, nil
+}
+
+type fakeConfigurationService struct {
+	service.ApplicationConfigurationServicer
+
+	mtx  sync.Mutex
+	data json.RawMessage
+}
+
+func (f *fakeConfigurationService) GetConfigurationViewTypeJSON(ctx context.Context, req dto.RequestGetConfiguration) (dto.ResponseGetConfigurationViewTypeJSON, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return dto.ResponseGetConfigurationViewTypeJSON{
+		ClientKey: req.XClientKey,
+		Data:      f.data,
+	}, nil
+}
+
+func (f *fakeConfigurationService) InternalFindConfigurationOverrides(ctx context.Context, clientKey string) (entity.ConfigurationOverrides, error) {
+	return nil, nil
+}
+
+func (f *fakeConfigurationService) set(data string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.data = json.RawMessage(data)
+}
+
+// server runs the websocket handler of the coma server on a fixed address,
+// so it can be restarted on the same address
+type server struct {
+	t                *testing.T
+	addr             string
+	configurationSvc *fakeConfigurationService
+	handler          *websockethandler.WebsocketHandler
+	httpServer       *http.Server
+}
+
+func newServer(t *testing.T, data string) *server {
+	s := &server{
+		t:                t,
+		configurationSvc: &fakeConfigurationService{},
+	}
+	s.configurationSvc.set(data)
+	s.start()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *server) start() {
+	addr := s.addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(s.t, err)
+	s.addr = listener.Addr().String()
+
+	cfg := &config.Config{
+		Websocket: config.WebsocketConfig{
+			PingInterval:    time.Minute,
+			PongTimeout:     time.Minute,
+			WriteTimeout:    time.Second,
+			SendQueueSize:   16,
+			SendQueuePolicy: "coalesce",
+			InternalToken:   internalToken,
+		},
+		Signing: config.SigningConfig{
+			PrivateKey: signingKey,
+		},
+	}
+	svc := container.Service{
+		ApplicationKeyServicer:           fakeApplicationKeyService{},
+		ApplicationConfigurationServicer: s.configurationSvc,
+		SigningServicer:                  signingsvc.NewSigningService(cfg, container.Container{}),
+	}
+	s.handler = websockethandler.NewWebsocketHandler(cfg, svc)
+
+	router := chi.NewRouter()
+	s.handler.Router(router)
+	router.Get("/v1/signing-keys", httphandler.NewHttpHandler(svc).FindSigningKeys)
+	router.Route("/v1/connections", s.handler.ConnectionRouter)
+
+	s.httpServer = &http.Server{Handler: router}
+	go s.httpServer.Serve(listener)
+}
+
+// stop closes the websocket connections too, they're hijacked from the http server
+func (s *server) stop() {
+	s.httpServer.Close()
+	s.handler.Close()
+}
+
+func (s *server) url() string {
+	return "ws://" + s.addr + "/websocket"
+}
+
+// publish distributes the configuration the same way the server does,
+// through the internal connection
+func (s *server) publish(data string) {
+	s.configurationSvc.set(data)
+
+	wsConfig, err := websocket.NewConfig(s.url()+"?self=true", "http://"+s.addr+"/")
+	require.NoError(s.t, err)
+	wsConfig.Header.Set(config.InternalTokenHeader, internalToken)
+	conn, err := websocket.DialConfig(wsConfig)
+	require.NoError(s.t, err)
+	defer conn.Close()
+
+	sum := sha256.Sum256([]byte(data))
+	message, err := json.Marshal(client.Message{
+		ClientKey: clientKey,
+		Revision:  hex.EncodeToString(sum[:]),
+		Data:      json.RawMessage(data),
+	})
+	require.NoError(s.t, err)
+	require.NoError(s.t, websocket.Message.Send(conn, message))
+}
+
+// newClient pins the verification key of the server, the test server has no tls to fetch it
+func newClient(t *testing.T, url string, opts ...client.Option) *client.Client {
+	signer, err := signature.NewSigner(signingKey)
+	require.NoError(t, err)
+	return newUnpinnedClient(t, url, append(opts, client.SetVerificationKeys(signer.VerificationKey()))...)
+}
+
+func newUnpinnedClient(t *testing.T, url string, opts ...client.Option) *client.Client {
+	opts = append(opts, client.SetRetryMaxWait(200*time.Millisecond))
+	c, err := client.New(url, clientKey, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		c.Close(context.Background())
+	})
+	return c
+}
+
+func timeoutContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestNew(t *testing.T) {
+	signer, err := signature.NewSigner(signingKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		url         string
+		key         string
+		opts        []client.Option
+		expectation error
+	}{
+		{
+			name: "valid",
+			url:  "wss://127.0.0.1:5898/websocket",
+			key:  clientKey,
+		},
+		{
+			name: "pinned keys without tls",
+			url:  "ws://127.0.0.1:5898/websocket",
+			key:  clientKey,
+			opts: []client.Option{client.SetVerificationKeys(signer.VerificationKey())},
+		},
+		{
+			name:        "fetched keys without tls",
+			url:         "ws://127.0.0.1:5898/websocket",
+			key:         clientKey,
+			expectation: client.ErrInsecureKeysUrl,
+		},
+		{
+			name:        "fetched keys from http url",
+			url:         "wss://127.0.0.1:5898/websocket",
+			key:         clientKey,
+			opts:        []client.Option{client.SetSigningKeysUrl("http://127.0.0.1:5898/v1/signing-keys")},
+			expectation: client.ErrInsecureKeysUrl,
+		},
+		{
+			name:        "empty url",
+			key:         clientKey,
+			expectation: client.ErrEmptyUrl,
+		},
+		{
+			name:        "empty key",
+			url:         "ws://127.0.0.1:5898/websocket",
+			expectation: client.ErrEmptyKey,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.New(tc.url, tc.key, tc.opts...)
+			assert.Equal(t, tc.expectation, err)
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := newServer(t, `{"name":"coma","port":8080}`)
+	c := newClient(t, s.url())
+	c.Start()
+
+	var act appConfig
+	require.NoError(t, c.Get(timeoutContext(t), &act))
+	assert.Equal(t, appConfig{Name: "coma", Port: 8080}, act)
+
+	var port int
+	assert.NoError(t, c.Lookup(timeoutContext(t), "port", &port))
+	assert.Equal(t, 8080, port)
+
+	var missing string
+	assert.True(t, errors.Is(c.Lookup(timeoutContext(t), "missing", &missing), client.ErrNotFound))
+}
+
+func TestGetWithoutConfiguration(t *testing.T) {
+	c := newClient(t, "ws://127.0.0.1:1/websocket")
+	c.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var act appConfig
+	assert.ErrorIs(t, c.Get(ctx, &act), context.DeadlineExceeded)
+}
+
+func TestWatchAs(t *testing.T) {
+	s := newServer(t, `{"name":"coma","port":8080}`)
+	c := newClient(t, s.url())
+
+	received := make(chan appConfig, 4)
+	unwatch := client.WatchAs(c, func(value appConfig, err error) {
+		assert.NoError(t, err)
+		received <- value
+	})
+	defer unwatch()
+	c.Start()
+
+	assert.Equal(t, appConfig{Name: "coma", Port: 8080}, waitFor(t, received))
+
+	s.publish(`{"name":"coma","port":9090}`)
+	assert.Equal(t, appConfig{Name: "coma", Port: 9090}, waitFor(t, received))
+
+	// the watcher added later receives the current configuration first
+	late := make(chan appConfig, 1)
+	unwatchLate := client.WatchAs(c, func(value appConfig, err error) {
+		late <- value
+	})
+	defer unwatchLate()
+	assert.Equal(t, appConfig{Name: "coma", Port: 9090}, waitFor(t, late))
+}
+
+func TestSubscribe(t *testing.T) {
+	s := newServer(t, `{"name":"coma","port":8080}`)
+	c := newClient(t, s.url())
+	c.Start()
+
+	ch, unsubscribe := client.Subscribe[appConfig](c)
+	assert.Equal(t, appConfig{Name: "coma", Port: 8080}, waitFor(t, ch))
+
+	s.publish(`{"name":"coma","port":9090}`)
+	assert.Equal(t, appConfig{Name: "coma", Port: 9090}, waitFor(t, ch))
+
+	unsubscribe()
+	_, open := <-ch
+	assert.False(t, open)
+}
+
+func TestReconnect(t *testing.T) {
+	s := newServer(t, `{"name":"coma","port":8080}`)
+	c := newClient(t, s.url())
+
+	received := make(chan appConfig, 4)
+	unwatch := client.WatchAs(c, func(value appConfig, err error) {
+		received <- value
+	})
+	defer unwatch()
+	c.Start()
+
+	assert.Equal(t, appConfig{Name: "coma", Port: 8080}, waitFor(t, received))
+
+	// the configuration is changed while the client is disconnected,
+	// the client receives it when it's connected again
+	s.stop()
+	s.configurationSvc.set(`{"name":"coma","port":9090}`)
+	s.start()
+
+	assert.Equal(t, appConfig{Name: "coma", Port: 9090}, waitFor(t, received))
+}
+
+func TestCacheFallback(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	s := newServer(t, `{"name":"coma","port":8080}`)
+	c := newClient(t, s.url(), client.SetCacheDir(cacheDir))
+	c.Start()
+
+	var act appConfig
+	require.NoError(t, c.Get(timeoutContext(t), &act))
+	require.NoError(t, c.Close(timeoutContext(t)))
+	s.stop()
+
+	// the server is unreachable, the configuration is served from the cache
+	offline := newClient(t, s.url(), client.SetCacheDir(cacheDir))
+	offline.Start()
+
+	act = appConfig{}
+	require.NoError(t, offline.Get(timeoutContext(t), &act))
+	assert.Equal(t, appConfig{Name: "coma", Port: 8080}, act)
+	assert.Equal(t, c.Revision(), offline.Revision())
+}
+
+func TestVerification(t *testing.T) {
+	signer, err := signature.NewSigner(signingKey)
+	require.NoError(t, err)
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherSigner, err := signature.NewSigner(otherKey)
+	require.NoError(t, err)
+
+	// the published keys of the server and of another server, the keys are fetched over https
+	keysServer := func(signer *signature.Signer) *httptest.Server {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []signature.VerificationKey{signer.VerificationKey()},
+			})
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	keys := keysServer(signer)
+	otherKeys := keysServer(otherSigner)
+
+	testCases := []struct {
+		name    string
+		opts    []client.Option
+		applied bool
+	}{
+		{
+			name:    "keys are fetched from the server",
+			opts:    []client.Option{client.SetSigningKeysUrl(keys.URL), client.SetHTTPClient(keys.Client())},
+			applied: true,
+		},
+		{
+			name:    "pinned key",
+			opts:    []client.Option{client.SetVerificationKeys(signer.VerificationKey())},
+			applied: true,
+		},
+		{
+			name: "pinned key of another server",
+			opts: []client.Option{client.SetVerificationKeys(otherSigner.VerificationKey())},
+		},
+		{
+			name: "keys of another server",
+			opts: []client.Option{client.SetSigningKeysUrl(otherKeys.URL), client.SetHTTPClient(otherKeys.Client())},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServer(t, `{"name":"coma","port":8080}`)
+			c := newUnpinnedClient(t, s.url(), tc.opts...)
+			c.Start()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			var act appConfig
+			err := c.Get(ctx, &act)
+			if !tc.applied {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, appConfig{Name: "coma", Port: 8080}, act)
+		})
+	}
+}
+
+// signedMessage signs the configuration with the signing key of the server at the issued at
+func signedMessage(t *testing.T, data string, issuedAt int64) []byte {
+	sum := sha256.Sum256([]byte(data))
+	revision := hex.EncodeToString(sum[:])
+	payload, err := signature.Payload(clientKey, revision, issuedAt, []byte(data))
+	require.NoError(t, err)
+
+	message, err := json.Marshal(client.Message{
+		Type:      client.MessageTypeConfiguration,
+		ClientKey: clientKey,
+		Revision:  revision,
+		Data:      json.RawMessage(data),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload)),
+		KeyId:     signature.KeyId(signingKey.Public().(ed25519.PublicKey)),
+		IssuedAt:  issuedAt,
+	})
+	require.NoError(t, err)
+	return message
+}
+
+func TestOutdatedConfiguration(t *testing.T) {
+	// every connection receives its signed messages, an older one is a replay of a previous revision
+	connections := make(chan [][]byte, 2)
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		for _, message := range <-connections {
+			if err := websocket.Message.Send(conn, message); err != nil {
+				return
+			}
+		}
+		var data []byte
+		for websocket.Message.Receive(conn, &data) == nil {
+		}
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/websocket"
+	cacheDir := t.TempDir()
+	watch := func(c *client.Client) <-chan appConfig {
+		received := make(chan appConfig, 4)
+		unwatch := client.WatchAs(c, func(value appConfig, err error) {
+			received <- value
+		})
+		t.Cleanup(unwatch)
+		c.Start()
+		return received
+	}
+
+	connections <- [][]byte{
+		signedMessage(t, `{"name":"coma","port":8080}`, 2000),
+		signedMessage(t, `{"name":"coma","port":9090}`, 1000),
+		signedMessage(t, `{"name":"coma","port":7070}`, 3000),
+	}
+	c := newClient(t, url, client.SetCacheDir(cacheDir), client.SetReportInterval(-1))
+	received := watch(c)
+	assert.Equal(t, appConfig{Name: "coma", Port: 8080}, waitFor(t, received))
+	assert.Equal(t, appConfig{Name: "coma", Port: 7070}, waitFor(t, received))
+	require.NoError(t, c.Close(timeoutContext(t)))
+
+	// the issued at of the cached configuration is kept after the restart
+	connections <- [][]byte{
+		signedMessage(t, `{"name":"coma","port":8080}`, 2000),
+		signedMessage(t, `{"name":"coma","port":6060}`, 4000),
+	}
+	restarted := newClient(t, url, client.SetCacheDir(cacheDir), client.SetReportInterval(-1))
+	received = watch(restarted)
+	assert.Equal(t, appConfig{Name: "coma", Port: 7070}, waitFor(t, received))
+	assert.Equal(t, appConfig{Name: "coma", Port: 6060}, waitFor(t, received))
+}
+
+func TestEncoding(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []client.Option
+	}{
+		{
+			name: "msgpack",
+			opts: []client.Option{client.SetEncoding(codec.EncodingMsgpack)},
+		},
+		{
+			name: "cbor with gzip",
+			opts: []client.Option{client.SetEncoding(codec.EncodingCBOR), client.SetCompression(codec.CompressionGzip)},
+		},
+		{
+			name: "json with deflate",
+			opts: []client.Option{client.SetCompression(codec.CompressionDeflate)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cacheDir := t.TempDir()
+
+			s := newServer(t, `{"name":"coma","port":8080}`)
+			c := newClient(t, s.url(), append(tc.opts, client.SetCacheDir(cacheDir))...)
+
+			received := make(chan appConfig, 4)
+			unwatch := client.WatchAs(c, func(value appConfig, err error) {
+				assert.NoError(t, err)
+				received <- value
+			})
+			defer unwatch()
+			c.Start()
+
+			assert.Equal(t, appConfig{Name: "coma", Port: 8080}, waitFor(t, received))
+
+			s.publish(`{"name":"coma","port":9090}`)
+			assert.Equal(t, appConfig{Name: "coma", Port: 9090}, waitFor(t, received))
+
+			require.NoError(t, c.Close(timeoutContext(t)))
+			s.stop()
+
+			// the cache is JSON whatever the encoding is
+			offline := newClient(t, s.url(), client.SetCacheDir(cacheDir))
+			offline.Start()
+
+			var act appConfig
+			require.NoError(t, offline.Get(timeoutContext(t), &act))
+			assert.Equal(t, appConfig{Name: "coma", Port: 9090}, act)
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []client.Option
+		expected websockethandler.DriftStatus
+	}{
+		{
+			name:     "reported",
+			expected: websockethandler.DriftStatusInSync,
+		},
+		{
+			name:     "reported in msgpack",
+			opts:     []client.Option{client.SetEncoding(codec.EncodingMsgpack)},
+			expected: websockethandler.DriftStatusInSync,
+		},
+		{
+			name:     "disabled",
+			opts:     []client.Option{client.SetReportInterval(-1)},
+			expected: websockethandler.DriftStatusUnreported,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServer(t, `{"name":"coma","port":8080}`)
+			c := newClient(t, s.url(), append(tc.opts, client.SetCacheDir(t.TempDir()))...)
+
+			received := make(chan appConfig, 4)
+			unwatch := client.WatchAs(c, func(value appConfig, err error) {
+				assert.NoError(t, err)
+				received <- value
+			})
+			defer unwatch()
+			c.Start()
+			waitFor(t, received)
+
+			s.publish(`{"name":"coma","port":9090}`)
+			waitFor(t, received)
+
+			var drift websockethandler.ResponseConnectionDrift
+			assert.Eventually(t, func() bool {
+				resp, err := http.Get("http://" + s.addr + "/v1/connections/drift?clientKey=" + clientKey)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				var drifts struct {
+					Data websockethandler.ResponseConnectionDrifts `json:"data"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&drifts))
+				if len(drifts.Data) != 1 || len(drifts.Data[0].Instances) != 1 {
+					return false
+				}
+				drift = drifts.Data[0]
+				return drift.Instances[0].Status == tc.expected
+			}, 5*time.Second, 10*time.Millisecond)
+
+			if tc.expected == websockethandler.DriftStatusInSync {
+				assert.Equal(t, c.Revision(), drift.Instances[0].Applied)
+			}
+		})
+	}
+}
+
+func TestNewUnsupportedEncoding(t *testing.T) {
+	_, err := client.New("ws://127.0.0.1:5898/websocket", "key", client.SetEncoding("xml"))
+	assert.ErrorIs(t, err, codec.ErrEncodingNotSupported)
+}
+
+func waitFor[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case value := <-ch:
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the configuration")
+	}
+	var value T
+	return value
+}
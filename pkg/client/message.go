@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound = errors.New("err: field is not found")
+)
+
+// MessageTypeConfiguration is the type of the configuration message, the other
+// types are the answers of the subscription requests
+const MessageTypeConfiguration = "configuration"
+
+// MessageTypeReport reports the applied revision to the server
+const MessageTypeReport = "report"
+
+// DefaultSubscription is the subscription of the key of the client
+const DefaultSubscription = "default"
+
+// report is the applied revision of the subscription
+type report struct {
+	Type         string `json:"type"`
+	Subscription string `json:"subscription"`
+	Revision     string `json:"revision"`
+}
+
+// Message is the configuration that is distributed by the server, it's tagged
+// by the subscription, the key of the handshake is the "default" subscription
+type Message struct {
+	Type         string          `json:"type,omitempty"`
+	Subscription string          `json:"subscription,omitempty"`
+	ClientKey    string          `json:"clientKey"`
+	Revision     string          `json:"revision"`
+	Data         json.RawMessage `json:"data"`
+	// Signature is the detached signature of the configuration, see pkg/signature
+	Signature string `json:"signature"`
+	KeyId     string `json:"keyId"`
+	// IssuedAt is the signed unix time in milliseconds of the signing
+	IssuedAt int64 `json:"issuedAt"`
+}
+
+// binaryMessage is the Message of msgpack and cbor, the data is a native map
+type binaryMessage struct {
+	Type         string `json:"type,omitempty"`
+	Subscription string `json:"subscription,omitempty"`
+	ClientKey    string `json:"clientKey"`
+	Revision     string `json:"revision"`
+	Data         any    `json:"data"`
+	Signature    string `json:"signature"`
+	KeyId        string `json:"keyId"`
+	IssuedAt     int64  `json:"issuedAt"`
+}
+
+// Snapshot is the configuration of the application key on a revision
+type Snapshot struct {
+	Revision string
+	Data     json.RawMessage
+}
+
+// Unmarshal decodes the configuration into v, v is usually a pointer of struct
+func (s Snapshot) Unmarshal(v any) error {
+	return json.Unmarshal(s.Data, v)
+}
+
+// Lookup decodes a single top level field into v
+func (s Snapshot) Lookup(field string, v any) error {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(s.Data, &fields); err != nil {
+		return err
+	}
+
+	value, exists := fields[field]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, field)
+	}
+
+	return json.Unmarshal(value, v)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/pkg/signature"
+)
+
+type Option func(c *Client)
+
+// SetOriginUrl sets the origin of the websocket handshake
+func SetOriginUrl(originUrl string) Option {
+	return func(c *Client) {
+		c.originUrl = originUrl
+	}
+}
+
+// SetInstanceId identifies the process on the server, the default is the hostname
+func SetInstanceId(instanceId string) Option {
+	return func(c *Client) {
+		c.instanceId = instanceId
+	}
+}
+
+// SetLabels are reported to the server on connect
+func SetLabels(labels map[string]string) Option {
+	return func(c *Client) {
+		for key, value := range labels {
+			c.labels = append(c.labels, fmt.Sprintf("%s:%s", key, value))
+		}
+	}
+}
+
+// SetSdk overrides the reported sdk name and version,
+// it's used by the programs that are built on top of the client
+func SetSdk(name, version string) Option {
+	return func(c *Client) {
+		c.sdkName = name
+		c.sdkVersion = version
+	}
+}
+
+// SetCacheDir enables the on-disk cache of the last received configuration,
+// so Get is served from the cache while the server is unreachable
+func SetCacheDir(cacheDir string) Option {
+	return func(c *Client) {
+		c.cacheDir = cacheDir
+	}
+}
+
+// SetRetryMaxWait is the maximum wait time between the reconnections
+func SetRetryMaxWait(wait time.Duration) Option {
+	return func(c *Client) {
+		c.retryMaxWait = wait
+	}
+}
+
+// SetEncoding negotiates the encoding of the messages, it's json (default), msgpack or cbor
+func SetEncoding(encoding codec.Encoding) Option {
+	return func(c *Client) {
+		c.codec.Encoding = encoding
+	}
+}
+
+// SetCompression negotiates the compression of every message, it's none (default), gzip or deflate
+func SetCompression(compression codec.Compression) Option {
+	return func(c *Client) {
+		c.codec.Compression = compression
+	}
+}
+
+// SetReportInterval is the interval of the applied revision report that is used by the
+// drift detection of the server, the revision is also reported after every update.
+// A negative interval disables the report
+func SetReportInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.reportInterval = interval
+	}
+}
+
+// SetVerificationKeys pins the keys that verify the signature of the configuration,
+// the keys aren't fetched from the server
+func SetVerificationKeys(keys ...signature.VerificationKey) Option {
+	return func(c *Client) {
+		c.verification.pinnedKeys = append(c.verification.pinnedKeys, keys...)
+	}
+}
+
+// SetSigningKeysUrl overrides the url of the published verification keys,
+// the default is /v1/signing-keys of the server
+func SetSigningKeysUrl(keysUrl string) Option {
+	return func(c *Client) {
+		c.verification.keysUrl = keysUrl
+	}
+}
+
+// SetHTTPClient sets the http client that fetches the verification keys,
+// e.g. with the certificate authority of the server
+func SetHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.verification.httpClient = httpClient
+	}
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/rs/zerolog/log"
+)
+
+// keysRefreshInterval limits the fetching of the verification keys,
+// the keys are fetched again when the configuration is signed by an unknown key
+const keysRefreshInterval = 30 * time.Second
+
+var (
+	ErrVerificationKeys      = errors.New("err: verification keys are not available")
+	ErrInsecureKeysUrl       = errors.New("err: verification keys are only fetched over https, pin the keys of GET /v1/signing-keys with SetVerificationKeys (-verification-key of the agent)")
+	ErrOutdatedConfiguration = errors.New("err: configuration is issued before the applied one")
+)
+
+// verification is the state of the signature verification,
+// it's only used by the run goroutine
+type verification struct {
+	keysUrl    string
+	pinnedKeys []signature.VerificationKey
+	verifier   *signature.Verifier
+	fetchedAt  time.Time
+	httpClient *http.Client
+	// issuedAt is the issued at of the applied configuration, an older configuration
+	// is a replay of the previous revision and it's never applied
+	issuedAt int64
+}
+
+func defaultSigningKeysUrl(serverUrl string) (string, error) {
+	u, err := url.Parse(serverUrl)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "http"
+	if u.Scheme == "wss" || u.Scheme == "https" {
+		scheme = "https"
+	}
+	path := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/websocket")
+
+	return fmt.Sprintf("%s://%s%s/v1/signing-keys", scheme, u.Host, path), nil
+}
+
+// verify checks the signature of the configuration before it's applied, the message
+// must be signed for the key of the client and not before the applied configuration
+func (c *Client) verify(message Message) error {
+	if err := c.verifySignature(message); err != nil {
+		return err
+	}
+	if message.IssuedAt < c.verification.issuedAt {
+		return ErrOutdatedConfiguration
+	}
+	return nil
+}
+
+func (c *Client) verifySignature(message Message) error {
+	sig := signature.Signature{
+		KeyId:    message.KeyId,
+		Value:    message.Signature,
+		IssuedAt: message.IssuedAt,
+	}
+
+	if c.verification.verifier != nil {
+		err := c.verification.verifier.Verify(c.key, message.Revision, message.Data, sig)
+		if !errors.Is(err, signature.ErrUnknownKey) {
+			return err
+		}
+	}
+
+	// the pinned keys are never refreshed
+	if len(c.verification.pinnedKeys) > 0 {
+		return signature.ErrUnknownKey
+	}
+	if err := c.fetchVerificationKeys(); err != nil {
+		return err
+	}
+
+	return c.verification.verifier.Verify(c.key, message.Revision, message.Data, sig)
+}
+
+// fetchVerificationKeys gets the published keys of the server
+func (c *Client) fetchVerificationKeys() error {
+	if time.Since(c.verification.fetchedAt) < keysRefreshInterval {
+		if c.verification.verifier == nil {
+			return ErrVerificationKeys
+		}
+		return signature.ErrUnknownKey
+	}
+	c.verification.fetchedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.verification.keysUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.verification.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrVerificationKeys, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrVerificationKeys, resp.StatusCode)
+	}
+
+	var body struct {
+		Data []signature.VerificationKey `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	verifier, err := signature.NewVerifier(body.Data...)
+	if err != nil {
+		return err
+	}
+	c.verification.verifier = verifier
+
+	log.Info().
+		Int("keys", len(body.Data)).
+		Msg("[Client.fetchVerificationKeys] verification keys are fetched")
+	return nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Watch calls fn with the current configuration, if there is one, and then
+// on every new revision. The watchers are called one by one in the revision
+// order, fn must not call Watch. The returned func stops the watcher.
+func (c *Client) Watch(fn func(Snapshot)) (unwatch func()) {
+	w := &watcher{fn: fn}
+
+	c.notifyMtx.Lock()
+	defer c.notifyMtx.Unlock()
+
+	select {
+	case <-c.ready:
+		w.call(c.current())
+	default:
+	}
+
+	c.watcherMtx.Lock()
+	c.watcherId++
+	id := c.watcherId
+	c.watchers[id] = w
+	c.watcherMtx.Unlock()
+
+	return func() {
+		w.stopped.Store(true)
+
+		c.watcherMtx.Lock()
+		delete(c.watchers, id)
+		c.watcherMtx.Unlock()
+	}
+}
+
+func (c *Client) current() Snapshot {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.snapshot
+}
+
+func (c *Client) notify(snapshot Snapshot) {
+	c.notifyMtx.Lock()
+	defer c.notifyMtx.Unlock()
+
+	c.watcherMtx.Lock()
+	watchers := make([]*watcher, 0, len(c.watchers))
+	for _, w := range c.watchers {
+		watchers = append(watchers, w)
+	}
+	c.watcherMtx.Unlock()
+
+	for _, w := range watchers {
+		w.call(snapshot)
+	}
+}
+
+// WatchAs is the typed Watch, the configuration is decoded into T
+// before fn is called and the decode error is given to fn
+func WatchAs[T any](c *Client, fn func(T, error)) (unwatch func()) {
+	return c.Watch(func(snapshot Snapshot) {
+		var value T
+		err := snapshot.Unmarshal(&value)
+		fn(value, err)
+	})
+}
+
+// Subscribe returns a channel of the configuration decoded into T, only the
+// latest value is kept so a slow reader skips the intermediate revisions.
+// The channel is closed by the returned func.
+func Subscribe[T any](c *Client) (<-chan T, func()) {
+	var (
+		mtx    sync.Mutex
+		closed bool
+		ch     = make(chan T, 1)
+	)
+
+	unwatch := WatchAs(c, func(value T, err error) {
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("[Subscribe] err: configuration can't be decoded")
+			return
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if closed {
+			return
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- value
+	})
+
+	return ch, func() {
+		unwatch()
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
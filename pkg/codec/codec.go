@@ -0,0 +1,195 @@
+// Package codec encodes the messages of the websocket connection. The encoding and
+// the compression are negotiated on the handshake by the "encoding" and "compression"
+// query, the default is uncompressed JSON.
+//
+// The msgpack and cbor encodings use the json names of the fields, and the
+// configuration data is encoded as a native map instead of a JSON document
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Encoding string
+
+const (
+	EncodingJSON    Encoding = "json"
+	EncodingMsgpack Encoding = "msgpack"
+	EncodingCBOR    Encoding = "cbor"
+)
+
+type Compression string
+
+const (
+	CompressionNone    Compression = "none"
+	CompressionGzip    Compression = "gzip"
+	CompressionDeflate Compression = "deflate"
+)
+
+var (
+	ErrEncodingNotSupported    = errors.New("err: encoding is not supported")
+	ErrCompressionNotSupported = errors.New("err: compression is not supported")
+)
+
+// the maps of the configuration are decoded with the string keys
+var mapType = reflect.TypeOf(map[string]any{})
+
+var (
+	cborEncMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+	cborDecMode, _ = cbor.DecOptions{DefaultMapType: mapType}.DecMode()
+)
+
+// Codec is the negotiated encoding and compression of a connection
+type Codec struct {
+	Encoding    Encoding
+	Compression Compression
+}
+
+// Default is uncompressed JSON, it's used when nothing is negotiated
+var Default = Codec{
+	Encoding:    EncodingJSON,
+	Compression: CompressionNone,
+}
+
+// Parse reads the handshake values, the empty value is the default
+func Parse(encoding, compression string) (Codec, error) {
+	c := Default
+	if encoding != "" {
+		c.Encoding = Encoding(encoding)
+	}
+	if compression != "" {
+		c.Compression = Compression(compression)
+	}
+
+	switch c.Encoding {
+	case EncodingJSON, EncodingMsgpack, EncodingCBOR:
+	default:
+		return c, fmt.Errorf("%w: %s", ErrEncodingNotSupported, encoding)
+	}
+
+	switch c.Compression {
+	case CompressionNone, CompressionGzip, CompressionDeflate:
+	default:
+		return c, fmt.Errorf("%w: %s", ErrCompressionNotSupported, compression)
+	}
+
+	return c, nil
+}
+
+func (c Codec) String() string {
+	if c.Compression == CompressionNone {
+		return string(c.Encoding)
+	}
+	return string(c.Encoding) + "+" + string(c.Compression)
+}
+
+// IsJSON tells the data can be sent as the JSON document
+func (c Codec) IsJSON() bool {
+	return c.Encoding == EncodingJSON
+}
+
+// Marshal encodes then compresses the value
+func (c Codec) Marshal(v any) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch c.Encoding {
+	case EncodingMsgpack:
+		var buf bytes.Buffer
+		encoder := msgpack.NewEncoder(&buf)
+		encoder.SetCustomStructTag("json")
+		encoder.SetSortMapKeys(true)
+		err = encoder.Encode(v)
+		data = buf.Bytes()
+	case EncodingCBOR:
+		data, err = cborEncMode.Marshal(v)
+	default:
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.compress(data)
+}
+
+// Unmarshal decompresses then decodes the data into v
+func (c Codec) Unmarshal(data []byte, v any) error {
+	data, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+
+	switch c.Encoding {
+	case EncodingMsgpack:
+		decoder := msgpack.NewDecoder(bytes.NewReader(data))
+		decoder.SetCustomStructTag("json")
+		return decoder.Decode(v)
+	case EncodingCBOR:
+		return cborDecMode.Unmarshal(data, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
+
+func (c Codec) compress(data []byte) ([]byte, error) {
+	var (
+		buf    bytes.Buffer
+		writer io.WriteCloser
+		err    error
+	)
+
+	switch c.Compression {
+	case CompressionGzip:
+		writer = gzip.NewWriter(&buf)
+	case CompressionDeflate:
+		writer, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c Codec) decompress(data []byte) ([]byte, error) {
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+
+	switch c.Compression {
+	case CompressionGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+	case CompressionDeflate:
+		reader = flate.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,98 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		encoding    string
+		compression string
+		expected    codec.Codec
+		err         error
+	}{
+		{
+			name:     "default",
+			expected: codec.Default,
+		},
+		{
+			name:        "msgpack with gzip",
+			encoding:    "msgpack",
+			compression: "gzip",
+			expected:    codec.Codec{Encoding: codec.EncodingMsgpack, Compression: codec.CompressionGzip},
+		},
+		{
+			name:     "cbor",
+			encoding: "cbor",
+			expected: codec.Codec{Encoding: codec.EncodingCBOR, Compression: codec.CompressionNone},
+		},
+		{
+			name:     "unknown encoding",
+			encoding: "xml",
+			err:      codec.ErrEncodingNotSupported,
+		},
+		{
+			name:        "unknown compression",
+			compression: "brotli",
+			err:         codec.ErrCompressionNotSupported,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			act, err := codec.Parse(tc.encoding, tc.compression)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, act)
+		})
+	}
+}
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	type message struct {
+		Type string `json:"type"`
+		Data any    `json:"data"`
+	}
+
+	expected := message{
+		Type: "configuration",
+		Data: map[string]any{
+			"url":  "http://host?a=1&b=<2>",
+			"tags": []any{"a", "b"},
+			"database": map[string]any{
+				"name": "coma",
+			},
+		},
+	}
+
+	for _, encoding := range []codec.Encoding{codec.EncodingJSON, codec.EncodingMsgpack, codec.EncodingCBOR} {
+		for _, compression := range []codec.Compression{codec.CompressionNone, codec.CompressionGzip, codec.CompressionDeflate} {
+			c := codec.Codec{Encoding: encoding, Compression: compression}
+			t.Run(c.String(), func(t *testing.T) {
+				data, err := c.Marshal(expected)
+				require.NoError(t, err)
+
+				var act message
+				require.NoError(t, c.Unmarshal(data, &act))
+				assert.Equal(t, expected, act)
+			})
+		}
+	}
+}
+
+func TestUnmarshalCompressed(t *testing.T) {
+	data, err := codec.Default.Marshal(map[string]any{"a": "b"})
+	require.NoError(t, err)
+
+	var act map[string]any
+	gzip := codec.Codec{Encoding: codec.EncodingJSON, Compression: codec.CompressionGzip}
+	assert.Error(t, gzip.Unmarshal(data, &act))
+}
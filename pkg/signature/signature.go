@@ -0,0 +1,265 @@
+// Package signature signs the configuration that is distributed by the coma
+// server, so the clients can verify the configuration came from the server.
+//
+// The signature is a detached Ed25519 signature over the canonical JSON of
+//
+//	{"clientKey":"<client key>","data":<canonical data>,"issuedAt":<issued at>,"revision":"<revision>"}
+//
+// The issued at is the unix time in milliseconds of the signing, the revision is a content
+// hash so the clients order the configuration of a client key by its issued at.
+// The canonical JSON has sorted object keys, no insignificant spaces, the integers
+// that fit in 64 bits as integers and the other numbers as float64, and the strings
+// escaped by encoding/json without the HTML escaping. The numbers are normalized,
+// so the configuration that is decoded from msgpack or cbor has the same signature.
+// The verification keys are published on GET /v1/signing-keys
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Algorithm is the only supported algorithm
+const Algorithm = "ed25519"
+
+var (
+	ErrInvalidSignature  = errors.New("err: signature is invalid")
+	ErrUnknownKey        = errors.New("err: signing key is unknown")
+	ErrInvalidPrivateKey = errors.New("err: signing key is invalid")
+	ErrInvalidPublicKey  = errors.New("err: verification key is invalid")
+)
+
+// Signature is the detached signature of a payload
+type Signature struct {
+	KeyId string
+	// Value is the base64 encoded signature
+	Value string
+	// IssuedAt is the unix time in milliseconds of the signing, it's signed with the payload
+	IssuedAt int64
+}
+
+// VerificationKey is the public key that is published by the server
+type VerificationKey struct {
+	KeyId     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the base64 encoded Ed25519 public key
+	PublicKey string `json:"publicKey"`
+}
+
+// NewVerificationKey parses the base64 encoded Ed25519 public key,
+// the key id is derived from the key
+func NewVerificationKey(publicKey string) (VerificationKey, error) {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return VerificationKey{}, ErrInvalidPublicKey
+	}
+
+	return VerificationKey{
+		KeyId:     KeyId(key),
+		Algorithm: Algorithm,
+		PublicKey: publicKey,
+	}, nil
+}
+
+// KeyId is the first 8 bytes of the SHA-256 of the public key
+func KeyId(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Canonical encodes the JSON value to its canonical form
+func Canonical(data []byte) ([]byte, error) {
+	value, err := Value(data)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(value)
+}
+
+// CanonicalValue encodes the decoded value to its canonical form,
+// the value can be decoded from any encoding
+func CanonicalValue(value any) ([]byte, error) {
+	normalized, err := normalize(value)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(normalized)
+}
+
+// Value decodes the JSON value with the normalized numbers,
+// it's the value that is encoded by msgpack and cbor
+func Value(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("err: invalid data after the JSON value")
+	}
+
+	return normalize(value)
+}
+
+func normalize(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, string, int64, uint64, float64:
+		return v, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return v.Float64()
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case float32:
+		return float64(v), nil
+	case []any:
+		values := make([]any, len(v))
+		for i := range v {
+			normalized, err := normalize(v[i])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = normalized
+		}
+		return values, nil
+	case map[string]any:
+		values := make(map[string]any, len(v))
+		for key, val := range v {
+			normalized, err := normalize(val)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = normalized
+		}
+		return values, nil
+	case map[any]any:
+		values := make(map[string]any, len(v))
+		for key, val := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("err: map key %v is not a string", key)
+			}
+			normalized, err := normalize(val)
+			if err != nil {
+				return nil, err
+			}
+			values[k] = normalized
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("err: %T is not a JSON value", value)
+	}
+}
+
+// Payload is the signed bytes of the configuration of the client key on the revision
+func Payload(clientKey, revision string, issuedAt int64, data []byte) ([]byte, error) {
+	canonical, err := Canonical(data)
+	if err != nil {
+		return nil, err
+	}
+	return payload(clientKey, revision, issuedAt, canonical)
+}
+
+// PayloadValue is the Payload of the decoded configuration
+func PayloadValue(clientKey, revision string, issuedAt int64, value any) ([]byte, error) {
+	canonical, err := CanonicalValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return payload(clientKey, revision, issuedAt, canonical)
+}
+
+func payload(clientKey, revision string, issuedAt int64, canonical []byte) ([]byte, error) {
+	// the fields are sorted
+	return marshal(struct {
+		ClientKey string          `json:"clientKey"`
+		Data      json.RawMessage `json:"data"`
+		IssuedAt  int64           `json:"issuedAt"`
+		Revision  string          `json:"revision"`
+	}{
+		ClientKey: clientKey,
+		Data:      canonical,
+		IssuedAt:  issuedAt,
+		Revision:  revision,
+	})
+}
+
+// Change is the change of a field of a delta, the value is empty when the field is deleted
+type Change struct {
+	Field   string
+	Value   json.RawMessage
+	Deleted bool
+}
+
+// DeltaData is the signed data of a delta, the delta is signed on its own revision
+func DeltaData(previousRevision string, changes []Change) ([]byte, error) {
+	type change struct {
+		Deleted bool            `json:"deleted"`
+		Field   string          `json:"field"`
+		Value   json.RawMessage `json:"value"`
+	}
+
+	delta := struct {
+		Changes          []change `json:"changes"`
+		PreviousRevision string   `json:"previousRevision"`
+	}{
+		Changes:          make([]change, 0, len(changes)),
+		PreviousRevision: previousRevision,
+	}
+	for _, c := range changes {
+		value, err := Canonical(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		delta.Changes = append(delta.Changes, change{
+			Deleted: c.Deleted,
+			Field:   c.Field,
+			Value:   value,
+		})
+	}
+
+	return marshal(delta)
+}
+
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
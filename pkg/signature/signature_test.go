@@ -0,0 +1,208 @@
+package signature_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+		isErr    bool
+	}{
+		{
+			name:     "keys are sorted and spaces are removed",
+			data:     `{ "b": 1, "a": { "d": [1, 2], "c": true } }`,
+			expected: `{"a":{"c":true,"d":[1,2]},"b":1}`,
+		},
+		{
+			name:     "numbers are normalized",
+			data:     `{"int":-42,"uint":18446744073709551615,"big":123456789012345678901,"float":1.50,"exp":1e2}`,
+			expected: `{"big":123456789012345680000,"exp":100,"float":1.5,"int":-42,"uint":18446744073709551615}`,
+		},
+		{
+			name:     "html is not escaped",
+			data:     `{"url":"http://host?a=1&b=<2>"}`,
+			expected: `{"url":"http://host?a=1&b=<2>"}`,
+		},
+		{
+			name:     "empty data is null",
+			data:     ``,
+			expected: `null`,
+		},
+		{
+			name:  "trailing data",
+			data:  `{"a":1} {"b":2}`,
+			isErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			act, err := signature.Canonical([]byte(tc.data))
+			if tc.isErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(act))
+		})
+	}
+}
+
+func TestCanonicalValue(t *testing.T) {
+	// the value that is decoded from msgpack or cbor
+	value := map[any]any{
+		"port":  int8(80),
+		"ratio": float32(0.5),
+		"url":   "http://host?a=1&b=<2>",
+		"tags":  []any{uint16(1), "a"},
+		"database": map[string]any{
+			"pool": uint64(10),
+		},
+	}
+
+	act, err := signature.CanonicalValue(value)
+	require.NoError(t, err)
+
+	expected, err := signature.Canonical([]byte(`{"database":{"pool":10},"port":80,"ratio":0.5,"tags":[1,"a"],"url":"http://host?a=1&b=<2>"}`))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(act))
+
+	_, err = signature.CanonicalValue(map[any]any{1: "a"})
+	assert.Error(t, err)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signer, err := signature.NewSigner(privateKey)
+	require.NoError(t, err)
+
+	_, otherPrivateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherSigner, err := signature.NewSigner(otherPrivateKey)
+	require.NoError(t, err)
+
+	verifier, err := signature.NewVerifier(signer.VerificationKey())
+	require.NoError(t, err)
+
+	data := json.RawMessage(`{"b":1,"a":"<x>"}`)
+	sig, err := signer.Sign("client-key", "revision", data)
+	require.NoError(t, err)
+	assert.Equal(t, signer.VerificationKey().KeyId, sig.KeyId)
+
+	otherSig, err := otherSigner.Sign("client-key", "revision", data)
+	require.NoError(t, err)
+
+	reissuedSig := sig
+	reissuedSig.IssuedAt++
+
+	testCases := []struct {
+		name      string
+		clientKey string
+		revision  string
+		data      string
+		signature signature.Signature
+		expected  error
+	}{
+		{
+			name:      "same configuration in another form",
+			clientKey: "client-key",
+			revision:  "revision",
+			data:      "{\n  \"a\": \"\\u003cx\\u003e\",\n  \"b\": 1\n}",
+			signature: sig,
+		},
+		{
+			name:      "another client key",
+			clientKey: "other-key",
+			revision:  "revision",
+			data:      string(data),
+			signature: sig,
+			expected:  signature.ErrInvalidSignature,
+		},
+		{
+			name:      "another revision",
+			clientKey: "client-key",
+			revision:  "other",
+			data:      string(data),
+			signature: sig,
+			expected:  signature.ErrInvalidSignature,
+		},
+		{
+			name:      "another issued at",
+			clientKey: "client-key",
+			revision:  "revision",
+			data:      string(data),
+			signature: reissuedSig,
+			expected:  signature.ErrInvalidSignature,
+		},
+		{
+			name:      "modified data",
+			clientKey: "client-key",
+			revision:  "revision",
+			data:      `{"b":2,"a":"<x>"}`,
+			signature: sig,
+			expected:  signature.ErrInvalidSignature,
+		},
+		{
+			name:      "unknown key",
+			clientKey: "client-key",
+			revision:  "revision",
+			data:      string(data),
+			signature: otherSig,
+			expected:  signature.ErrUnknownKey,
+		},
+		{
+			name:      "forged key id",
+			clientKey: "client-key",
+			revision:  "revision",
+			data:      string(data),
+			signature: signature.Signature{KeyId: sig.KeyId, Value: otherSig.Value},
+			expected:  signature.ErrInvalidSignature,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifier.Verify(tc.clientKey, tc.revision, []byte(tc.data), tc.signature)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestNewVerificationKey(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signer, err := signature.NewSigner(privateKey)
+	require.NoError(t, err)
+
+	key, err := signature.NewVerificationKey(signer.VerificationKey().PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, signer.VerificationKey(), key)
+
+	_, err = signature.NewVerificationKey("bm90IGEga2V5")
+	assert.ErrorIs(t, err, signature.ErrInvalidPublicKey)
+}
+
+func TestDeltaData(t *testing.T) {
+	act, err := signature.DeltaData("previous", []signature.Change{
+		{Field: "b", Value: json.RawMessage(`{ "y": 1, "x": 2 }`)},
+		{Field: "a", Deleted: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"changes":[{"deleted":false,"field":"b","value":{"x":2,"y":1}},{"deleted":true,"field":"a","value":null}],"previousRevision":"previous"}`,
+		string(act))
+}
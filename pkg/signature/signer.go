@@ -0,0 +1,106 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"time"
+)
+
+// Signer signs the configuration with the signing key of the server
+type Signer struct {
+	keyId      string
+	privateKey ed25519.PrivateKey
+}
+
+func NewSigner(privateKey ed25519.PrivateKey) (*Signer, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	return &Signer{
+		keyId:      KeyId(privateKey.Public().(ed25519.PublicKey)),
+		privateKey: privateKey,
+	}, nil
+}
+
+// Sign signs the configuration of the client key on the revision, it's issued now
+func (s *Signer) Sign(clientKey, revision string, data []byte) (Signature, error) {
+	issuedAt := time.Now().UnixMilli()
+	payload, err := Payload(clientKey, revision, issuedAt, data)
+	if err != nil {
+		return Signature{}, err
+	}
+
+	return Signature{
+		KeyId:    s.keyId,
+		Value:    base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, payload)),
+		IssuedAt: issuedAt,
+	}, nil
+}
+
+// VerificationKey is the public key of the signer
+func (s *Signer) VerificationKey() VerificationKey {
+	return VerificationKey{
+		KeyId:     s.keyId,
+		Algorithm: Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(s.privateKey.Public().(ed25519.PublicKey)),
+	}
+}
+
+// Verifier verifies the signature with the published keys of the server
+type Verifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+func NewVerifier(keys ...VerificationKey) (*Verifier, error) {
+	v := &Verifier{
+		keys: make(map[string]ed25519.PublicKey, len(keys)),
+	}
+	for _, key := range keys {
+		if key.Algorithm != "" && key.Algorithm != Algorithm {
+			return nil, ErrInvalidPublicKey
+		}
+		publicKey, err := base64.StdEncoding.DecodeString(key.PublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPublicKey
+		}
+		v.keys[KeyId(publicKey)] = publicKey
+	}
+
+	return v, nil
+}
+
+// Verify checks the signature of the configuration of the client key on the revision
+func (v *Verifier) Verify(clientKey, revision string, data []byte, signature Signature) error {
+	payload, err := Payload(clientKey, revision, signature.IssuedAt, data)
+	if err != nil {
+		return err
+	}
+	return v.verify(payload, signature)
+}
+
+// VerifyValue checks the signature of the decoded configuration
+func (v *Verifier) VerifyValue(clientKey, revision string, value any, signature Signature) error {
+	payload, err := PayloadValue(clientKey, revision, signature.IssuedAt, value)
+	if err != nil {
+		return err
+	}
+	return v.verify(payload, signature)
+}
+
+func (v *Verifier) verify(payload []byte, signature Signature) error {
+	publicKey, exists := v.keys[signature.KeyId]
+	if !exists {
+		return ErrUnknownKey
+	}
+
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if !ed25519.Verify(publicKey, payload, value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
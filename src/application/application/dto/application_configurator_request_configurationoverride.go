@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/google/uuid"
+	internalerror "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+var configurationFieldRegex = regexp.MustCompile("^[a-zA-Z_]+$")
+
+type RequestCreateConfigurationOverride struct {
+	XClientKey string            `json:"-"`
+	Name       string            `json:"name"`
+	InstanceId string            `json:"instanceId"`
+	Labels     map[string]string `json:"labels"`
+	Values     map[string]any    `json:"values"`
+}
+
+func validateConfigurationOverrideValues(value any) error {
+	values, _ := value.(map[string]any)
+	for field := range values {
+		if !configurationFieldRegex.MatchString(field) {
+			return errors.New("err: field must be letters or underscore")
+		}
+	}
+	return nil
+}
+
+// validateSelector requires the instance id or the labels, the override without selector matches nothing
+func (r RequestCreateConfigurationOverride) validateSelector(value any) error {
+	if r.InstanceId == "" && len(r.Labels) == 0 {
+		return errors.New("err: instanceId or labels is required")
+	}
+	return nil
+}
+
+func (r RequestCreateConfigurationOverride) Validate() error {
+	err := validation.ValidateStruct(&r,
+		validation.Field(&r.XClientKey, validation.Required),
+		validation.Field(&r.InstanceId, validation.By(r.validateSelector)),
+		validation.Field(&r.Values, validation.Required, validation.By(validateConfigurationOverrideValues)),
+	)
+	if err == nil {
+		return nil
+	}
+
+	return internalerror.New(err,
+		internalerror.SetErrorCode(http.StatusBadRequest),
+		internalerror.SetErrorSource(internalerror.OZZO_VALIDATION_ERR))
+}
+
+func (r RequestCreateConfigurationOverride) ConfigurationOverride() entity.ConfigurationOverride {
+	return entity.ConfigurationOverride{
+		Id:         uuid.New().String(),
+		ClientKey:  r.XClientKey,
+		Name:       r.Name,
+		InstanceId: r.InstanceId,
+		Labels:     r.Labels,
+		Values:     r.Values,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+type RequestFindConfigurationOverrides struct {
+	XClientKey string
+}
+
+func (r RequestFindConfigurationOverrides) FilterConfigurationOverride() entity.FilterConfigurationOverride {
+	return entity.FilterConfigurationOverride{
+		ClientKey: r.XClientKey,
+	}
+}
+
+type RequestDeleteConfigurationOverride struct {
+	XClientKey string
+	Id         string
+}
+
+func (r RequestDeleteConfigurationOverride) FilterConfigurationOverride() entity.FilterConfigurationOverride {
+	return entity.FilterConfigurationOverride{
+		Id:        r.Id,
+		ClientKey: r.XClientKey,
+	}
+}
@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type RequestWatchConfiguration struct {
+	XClientKey string
+	Revision   string
+	Timeout    time.Duration
+}
+
+// SetRevisionFromETag reads the revision from If-None-Match header value
+// both strong and weak validator are accepted
+func (r *RequestWatchConfiguration) SetRevisionFromETag(etag string) {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	r.Revision = strings.Trim(etag, `"`)
+}
+
+// NormalizeTimeout keeps the timeout between zero and the max timeout
+// when it's not set it will use the default timeout
+func (r *RequestWatchConfiguration) NormalizeTimeout(defaultTimeout, maxTimeout time.Duration) {
+	if r.Timeout <= 0 {
+		r.Timeout = defaultTimeout
+	}
+
+	if maxTimeout > 0 && r.Timeout > maxTimeout {
+		r.Timeout = maxTimeout
+	}
+}
+
+type ResponseWatchConfiguration struct {
+	ClientKey string          `json:"clientKey"`
+	Revision  string          `json:"revision"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	Signature string          `json:"signature"`
+	KeyId     string          `json:"keyId"`
+	IssuedAt  int64           `json:"issuedAt"`
+	Modified  bool            `json:"-"`
+}
+
+func NewResponseWatchConfiguration(configuration ResponseGetConfigurationViewTypeJSON, revision string) ResponseWatchConfiguration {
+	return ResponseWatchConfiguration{
+		ClientKey: configuration.ClientKey,
+		Revision:  configuration.Revision(),
+		Data:      configuration.Data,
+		Modified:  configuration.Revision() != revision,
+	}
+}
+
+// ETag returns the revision as a strong validator
+func (r ResponseWatchConfiguration) ETag() string {
+	return `"` + r.Revision + `"`
+}
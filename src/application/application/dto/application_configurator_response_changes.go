@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+type ConfigurationChange struct {
+	Field   string          `json:"field"`
+	Value   json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+	Deleted bool            `json:"deleted"`
+}
+
+type ConfigurationChanges []ConfigurationChange
+
+// NewConfigurationChanges compares two configuration JSON objects
+// and returns the changed fields sorted by its name
+func NewConfigurationChanges(previous, current json.RawMessage) (ConfigurationChanges, error) {
+	var (
+		previousFields = make(map[string]json.RawMessage)
+		currentFields  = make(map[string]json.RawMessage)
+		changes        = make(ConfigurationChanges, 0)
+	)
+
+	if len(previous) > 0 {
+		if err := json.Unmarshal(previous, &previousFields); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &currentFields); err != nil {
+			return nil, err
+		}
+	}
+
+	for field, value := range currentFields {
+		previousValue, exists := previousFields[field]
+		if exists && bytes.Equal(previousValue, value) {
+			continue
+		}
+
+		changes = append(changes, ConfigurationChange{
+			Field: field,
+			Value: value,
+		})
+	}
+
+	for field := range previousFields {
+		if _, exists := currentFields[field]; exists {
+			continue
+		}
+
+		changes = append(changes, ConfigurationChange{
+			Field:   field,
+			Deleted: true,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
@@ -0,0 +1,53 @@
+package dto_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConfigurationChanges(t *testing.T) {
+	testCases := []struct {
+		name     string
+		previous json.RawMessage
+		current  json.RawMessage
+		expected dto.ConfigurationChanges
+	}{
+		{
+			name:     "from empty configuration",
+			previous: nil,
+			current:  json.RawMessage(`{"name":"test","port":80}`),
+			expected: dto.ConfigurationChanges{
+				{Field: "name", Value: json.RawMessage(`"test"`)},
+				{Field: "port", Value: json.RawMessage(`80`)},
+			},
+		},
+		{
+			name:     "changed, added and deleted field",
+			previous: json.RawMessage(`{"host":"localhost","name":"test","port":80}`),
+			current:  json.RawMessage(`{"name":"test","port":8080,"timeout":"1s"}`),
+			expected: dto.ConfigurationChanges{
+				{Field: "host", Deleted: true},
+				{Field: "port", Value: json.RawMessage(`8080`)},
+				{Field: "timeout", Value: json.RawMessage(`"1s"`)},
+			},
+		},
+		{
+			name:     "nothing changed",
+			previous: json.RawMessage(`{"name":"test"}`),
+			current:  json.RawMessage(`{"name":"test"}`),
+			expected: dto.ConfigurationChanges{},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := dto.NewConfigurationChanges(test.previous, test.current)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
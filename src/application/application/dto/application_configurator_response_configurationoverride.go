@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+type ResponseConfigurationOverride struct {
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	InstanceId string            `json:"instanceId"`
+	Labels     map[string]string `json:"labels"`
+	Values     map[string]any    `json:"values"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+func NewResponseConfigurationOverride(data entity.ConfigurationOverride) ResponseConfigurationOverride {
+	return ResponseConfigurationOverride{
+		Id:         data.Id,
+		Name:       data.Name,
+		InstanceId: data.InstanceId,
+		Labels:     data.Labels,
+		Values:     data.Values,
+		CreatedAt:  data.CreatedAt,
+	}
+}
+
+type ResponseConfigurationOverrides []ResponseConfigurationOverride
+
+func NewResponseConfigurationOverrides(data entity.ConfigurationOverrides) ResponseConfigurationOverrides {
+	responses := make(ResponseConfigurationOverrides, 0, len(data))
+	for _, d := range data {
+		responses = append(responses, NewResponseConfigurationOverride(d))
+	}
+	return responses
+}
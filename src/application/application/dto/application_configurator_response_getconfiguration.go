@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 
 	"github.com/nurcahyaari/coma/src/domain/entity"
@@ -34,6 +36,13 @@ func (r *ResponseGetConfigurationViewTypeJSON) SetData(data entity.Configuration
 	return nil
 }
 
+// Revision returns the content hash of the configuration data,
+// the data is marshaled from a map so the keys are always sorted
+func (r ResponseGetConfigurationViewTypeJSON) Revision() string {
+	sum := sha256.Sum256(r.Data)
+	return hex.EncodeToString(sum[:])
+}
+
 func NewResponseGetConfigurationViewTypeJSON(clientKey string) ResponseGetConfigurationViewTypeJSON {
 	return ResponseGetConfigurationViewTypeJSON{
 		ClientKey: clientKey,
@@ -47,6 +47,14 @@ type RequestInternalFindApplicationKey struct {
 	SkipValidation bool
 }
 
+func (r RequestInternalFindApplicationKey) FilterApplicationKey() entity.FilterApplicationKey {
+	return entity.FilterApplicationKey{
+		SkipValidation: r.SkipValidation,
+		ApplicationId:  r.ApplicationId,
+		Key:            r.Key,
+	}
+}
+
 type RequestFindApplicationKey struct {
 	ApplicationId   string `json:"applicationId"`
 	ApplicationName string `json:"applicationName"`
@@ -43,3 +43,11 @@ func (r Repository) NewRepositoryApplicationConfigurationReader() repository.Rep
 func (r Repository) NewRepositoryApplicationConfigurationWriter() repository.RepositoryApplicationConfigurationWriter {
 	return NewApplicationConfigurationRepositoryWriter(r.db, fmt.Sprintf("%s_configuration", r.dbName))
 }
+
+func (r Repository) NewRepositoryApplicationConfigurationOverrideReader() repository.RepositoryApplicationConfigurationOverrideReader {
+	return NewApplicationConfigurationOverrideRepositoryReader(r.db, fmt.Sprintf("%s_configuration_override", r.dbName))
+}
+
+func (r Repository) NewRepositoryApplicationConfigurationOverrideWriter() repository.RepositoryApplicationConfigurationOverrideWriter {
+	return NewApplicationConfigurationOverrideRepositoryWriter(r.db, fmt.Sprintf("%s_configuration_override", r.dbName))
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/infrastructure/database"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/repository"
+)
+
+type RepositoryApplicationConfigurationOverrideRead struct {
+	dbName string
+	db     *database.Clover
+}
+
+func NewApplicationConfigurationOverrideRepositoryReader(db *database.Clover, name string) repository.RepositoryApplicationConfigurationOverrideReader {
+	db.DB.CreateCollection(name)
+	return &RepositoryApplicationConfigurationOverrideRead{
+		db:     db,
+		dbName: name,
+	}
+}
+
+func (r *RepositoryApplicationConfigurationOverrideRead) FindConfigurationOverride(ctx context.Context, filter entity.FilterConfigurationOverride) (entity.ConfigurationOverride, bool, error) {
+	overrides, err := r.FindConfigurationOverrides(ctx, filter)
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return entity.ConfigurationOverride{}, false, err
+	}
+	if len(overrides) == 0 {
+		return entity.ConfigurationOverride{}, false, nil
+	}
+
+	return overrides[0], true, nil
+}
+
+func (r *RepositoryApplicationConfigurationOverrideRead) FindConfigurationOverrides(ctx context.Context, filter entity.FilterConfigurationOverride) (entity.ConfigurationOverrides, error) {
+	var overrides entity.ConfigurationOverrides
+
+	docs, err := r.db.DB.
+		Query(r.dbName).
+		Where(filter.Filter()).
+		FindAll()
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		override := entity.ConfigurationOverride{}
+		err := doc.Unmarshal(&override)
+		if err != nil {
+			internalerrors.StackTrace(err)
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+
+	return overrides, nil
+}
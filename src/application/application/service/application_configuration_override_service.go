@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/rs/zerolog/log"
+)
+
+func (s *ApplicationConfigurationService) CreateConfigurationOverride(ctx context.Context, req dto.RequestCreateConfigurationOverride) (dto.ResponseConfigurationOverride, error) {
+	var response dto.ResponseConfigurationOverride
+
+	if err := req.Validate(); err != nil {
+		return response, err
+	}
+
+	override := req.ConfigurationOverride()
+	err := s.overrideWriter.CreateConfigurationOverride(ctx, override)
+	if err != nil {
+		log.Error().Err(err).Msg("[CreateConfigurationOverride] error create configuration override")
+		return response, internalerrors.New(err)
+	}
+
+	// the targeted clients receive the overridden configuration
+	s.pubSub.PublishMessage(s.config.Pubsub.ConfigDistributor.Publisher.Topic, pubsub.Message{
+		Key:  req.XClientKey,
+		Body: []byte(req.XClientKey),
+	})
+
+	return dto.NewResponseConfigurationOverride(override), nil
+}
+
+func (s *ApplicationConfigurationService) FindConfigurationOverrides(ctx context.Context, req dto.RequestFindConfigurationOverrides) (dto.ResponseConfigurationOverrides, error) {
+	overrides, err := s.overrideReader.FindConfigurationOverrides(ctx, req.FilterConfigurationOverride())
+	if err != nil {
+		log.Error().Err(err).Msg("[FindConfigurationOverrides] error find configuration overrides")
+		return nil, internalerrors.New(err)
+	}
+
+	return dto.NewResponseConfigurationOverrides(overrides), nil
+}
+
+func (s *ApplicationConfigurationService) DeleteConfigurationOverride(ctx context.Context, req dto.RequestDeleteConfigurationOverride) error {
+	_, exist, err := s.overrideReader.FindConfigurationOverride(ctx, req.FilterConfigurationOverride())
+	if err != nil {
+		log.Error().Err(err).Msg("[DeleteConfigurationOverride] error find configuration override")
+		return internalerrors.New(err)
+	}
+	if !exist {
+		return internalerrors.New(errors.New("err: configuration override not found"),
+			internalerrors.SetErrorCode(http.StatusNotFound))
+	}
+
+	err = s.overrideWriter.DeleteConfigurationOverride(ctx, req.FilterConfigurationOverride())
+	if err != nil {
+		log.Error().Err(err).Msg("[DeleteConfigurationOverride] error delete configuration override")
+		return internalerrors.New(err)
+	}
+
+	// the targeted clients get back the configuration without the override
+	s.pubSub.PublishMessage(s.config.Pubsub.ConfigDistributor.Publisher.Topic, pubsub.Message{
+		Key:  req.XClientKey,
+		Body: []byte(req.XClientKey),
+	})
+
+	return nil
+}
+
+func (s *ApplicationConfigurationService) InternalFindConfigurationOverrides(ctx context.Context, clientKey string) (entity.ConfigurationOverrides, error) {
+	overrides, err := s.overrideReader.FindConfigurationOverrides(ctx, entity.FilterConfigurationOverride{
+		ClientKey: clientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[InternalFindConfigurationOverrides] error find configuration overrides")
+		return nil, internalerrors.New(err)
+	}
+
+	return overrides, nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/nurcahyaari/coma/container"
 	"github.com/nurcahyaari/coma/infrastructure/integration/coma"
 	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/internal/x/notifier"
 	"github.com/nurcahyaari/coma/internal/x/pubsub"
 	"github.com/nurcahyaari/coma/src/application/application/dto"
 	"github.com/nurcahyaari/coma/src/domain/entity"
@@ -21,9 +23,12 @@ type ApplicationConfigurationService struct {
 	config            *config.Config
 	pubSub            *pubsub.Pubsub
 	comaClient        *coma.WebsocketClient
+	notifier          *notifier.Notifier
 	applicationKeySvc service.ApplicationKeyServicer
 	readerRepo        domainrepository.RepositoryApplicationConfigurationReader
 	writerRepo        domainrepository.RepositoryApplicationConfigurationWriter
+	overrideReader    domainrepository.RepositoryApplicationConfigurationOverrideReader
+	overrideWriter    domainrepository.RepositoryApplicationConfigurationOverrideWriter
 }
 
 func NewApplicationConfiguration(
@@ -32,8 +37,11 @@ func NewApplicationConfiguration(
 		config:            cfg,
 		pubSub:            c.LocalPubsub,
 		comaClient:        c.Integration.Coma,
+		notifier:          notifier.New(),
 		readerRepo:        c.Repository.RepositoryApplicationConfigurationReader,
 		writerRepo:        c.Repository.RepositoryApplicationConfigurationWriter,
+		overrideReader:    c.Repository.RepositoryApplicationConfigurationOverrideReader,
+		overrideWriter:    c.Repository.RepositoryApplicationConfigurationOverrideWriter,
 		applicationKeySvc: c.Service.ApplicationKeyServicer,
 	}
 	return svc
@@ -114,6 +122,14 @@ func (s *ApplicationConfigurationService) SetConfiguration(ctx context.Context,
 			internalerrors.SetErrorCode(http.StatusConflict))
 	}
 
+	previous, err := s.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: req.XClientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[SetConfiguration] error get the previous configuration")
+		return dto.ResponseSetConfiguration{}, internalerrors.New(err)
+	}
+
 	insertedId, err := s.writerRepo.SetConfiguration(ctx, configuration)
 	if err != nil {
 		log.Error().Err(err).Msg("[SetConfiguration] error SetConfiguration")
@@ -121,8 +137,7 @@ func (s *ApplicationConfigurationService) SetConfiguration(ctx context.Context,
 	}
 
 	// after success writing to the db distribute to the client
-	s.pubSub.Publish(s.config.Pubsub.ConfigDistributor.Publisher.Topic,
-		pubsub.SendString(req.XClientKey))
+	s.publishConfigurationChanged(ctx, req.XClientKey, previous)
 
 	return dto.ResponseSetConfiguration{
 		Id: insertedId,
@@ -158,6 +173,14 @@ func (s *ApplicationConfigurationService) UpdateConfiguration(ctx context.Contex
 
 	clientConfigurations.Update(mapConfigurationById)
 
+	previous, err := s.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: req.XClientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[UpdateConfiguration] error get the previous configuration")
+		return internalerrors.New(err)
+	}
+
 	for _, configuration := range clientConfigurations {
 		err = s.writerRepo.UpdateConfiguration(ctx, configuration)
 		if err != nil {
@@ -170,8 +193,7 @@ func (s *ApplicationConfigurationService) UpdateConfiguration(ctx context.Contex
 	}
 
 	// after success writing to the db distribute to the client
-	s.pubSub.Publish(s.config.Pubsub.ConfigDistributor.Publisher.Topic,
-		pubsub.SendString(req.XClientKey))
+	s.publishConfigurationChanged(ctx, req.XClientKey, previous)
 
 	return nil
 }
@@ -223,19 +245,127 @@ func (s *ApplicationConfigurationService) UpsertConfiguration(ctx context.Contex
 }
 
 func (s *ApplicationConfigurationService) DeleteConfiguration(ctx context.Context, req dto.RequestDeleteConfiguration) error {
-	err := s.writerRepo.DeleteConfiguration(ctx, req.FilterConfiguration())
+	previous, err := s.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: req.XClientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[DeleteConfiguration] error get the previous configuration")
+		return internalerrors.New(err)
+	}
+
+	err = s.writerRepo.DeleteConfiguration(ctx, req.FilterConfiguration())
 	if err != nil {
 		log.Error().Err(err).Msg("[DeleteConfiguration] error when deleting configuration")
 		return internalerrors.New(err)
 	}
 
 	// after success writing to the db distribute to the client
-	s.pubSub.Publish(s.config.Pubsub.ConfigDistributor.Publisher.Topic,
-		pubsub.SendString(req.XClientKey))
+	s.publishConfigurationChanged(ctx, req.XClientKey, previous)
 
 	return nil
 }
 
+// publishConfigurationChanged distributes the configuration to the clients
+// and publishes the changed fields to the webhooks of the application
+func (s *ApplicationConfigurationService) publishConfigurationChanged(ctx context.Context, clientKey string, previous dto.ResponseGetConfigurationViewTypeJSON) {
+	// the distributions of the client key are processed in order
+	s.pubSub.PublishMessage(s.config.Pubsub.ConfigDistributor.Publisher.Topic, pubsub.Message{
+		Key:  clientKey,
+		Body: []byte(clientKey),
+	})
+
+	current, err := s.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: clientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[publishConfigurationChanged] error get the current configuration")
+		return
+	}
+
+	changes, err := dto.NewConfigurationChanges(previous.Data, current.Data)
+	if err != nil {
+		log.Error().Err(err).Msg("[publishConfigurationChanged] error compare the configuration")
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	applicationKey, err := s.applicationKeySvc.InternalFindApplicationKey(ctx, dto.RequestInternalFindApplicationKey{
+		Key:            clientKey,
+		SkipValidation: true,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[publishConfigurationChanged] error find application key")
+		return
+	}
+
+	event := entity.NewWebhookEvent(entity.WebhookEventConfigurationChanged, applicationKey.ApplicationId)
+	event.Revision = current.Revision()
+	if previous.Data != nil {
+		event.PreviousRevision = previous.Revision()
+	}
+	event.Changes, err = json.Marshal(changes)
+	if err != nil {
+		log.Error().Err(err).Msg("[publishConfigurationChanged] error marshal the changes")
+		return
+	}
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Msg("[publishConfigurationChanged] error marshal webhook event")
+		return
+	}
+
+	s.pubSub.PublishMessage(s.config.Pubsub.WebhookDispatcher.Publisher.Topic, pubsub.Message{
+		Key:  event.ApplicationId,
+		Body: message,
+	})
+}
+
+// WatchConfiguration returns immediately when the revision is stale,
+// otherwise it blocks until the configuration of the client key is distributed
+// or the timeout is reached
+func (s *ApplicationConfigurationService) WatchConfiguration(ctx context.Context, req dto.RequestWatchConfiguration) (dto.ResponseWatchConfiguration, error) {
+	var response dto.ResponseWatchConfiguration
+
+	req.NormalizeTimeout(s.config.Watch.DefaultTimeout, s.config.Watch.MaxTimeout)
+
+	ctx, cancel := context.WithTimeout(ctx, req.Timeout)
+	defer cancel()
+
+	for {
+		// register the waiter before reading the configuration
+		// so the distribution between reading and waiting is not missed
+		changed, cancelWait := s.notifier.Wait(req.XClientKey)
+
+		clientConfiguration, err := s.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+			XClientKey: req.XClientKey,
+		})
+		if err != nil {
+			cancelWait()
+			log.Error().Err(err).
+				Msg("[WatchConfiguration.GetConfigurationViewTypeJSON] error when get the configuration")
+			return response, internalerrors.New(err)
+		}
+
+		response = dto.NewResponseWatchConfiguration(clientConfiguration, req.Revision)
+		if response.Modified {
+			cancelWait()
+			return response, nil
+		}
+
+		select {
+		case <-changed:
+			// the configuration was distributed, read it again
+			// it may still have the same revision
+		case <-ctx.Done():
+			cancelWait()
+			return response, nil
+		}
+	}
+}
+
 func (s *ApplicationConfigurationService) DistributeConfiguration(ctx context.Context, clientKey string) error {
 	clientConfiguration, err := s.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
 		XClientKey: clientKey,
@@ -246,6 +376,9 @@ func (s *ApplicationConfigurationService) DistributeConfiguration(ctx context.Co
 		return internalerrors.New(err)
 	}
 
+	// wake up the watchers even when the configuration is empty
+	s.notifier.Notify(clientKey)
+
 	if clientConfiguration.Data == nil {
 		err = errors.New("err: data is empty")
 		log.Error().Err(err).
@@ -255,6 +388,7 @@ func (s *ApplicationConfigurationService) DistributeConfiguration(ctx context.Co
 
 	err = s.comaClient.Send(coma.RequestSendMessage{
 		ClientKey: clientKey,
+		Revision:  clientConfiguration.Revision(),
 		Data:      clientConfiguration.Data,
 	})
 	if err != nil {
@@ -2,12 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 
 	"github.com/nurcahyaari/coma/config"
 	"github.com/nurcahyaari/coma/container"
 	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
 	"github.com/nurcahyaari/coma/internal/x/routine"
 	"github.com/nurcahyaari/coma/src/application/application/dto"
 	"github.com/nurcahyaari/coma/src/domain/entity"
@@ -18,6 +20,7 @@ import (
 
 type ApplicationKeyService struct {
 	config            *config.Config
+	pubSub            *pubsub.Pubsub
 	reader            domainrepository.RepositoryApplicationKeyReader
 	writer            domainrepository.RepositoryApplicationKeyWriter
 	applicationReader domainrepository.RepositoryApplicationReader
@@ -27,6 +30,7 @@ type ApplicationKeyService struct {
 func NewApplicationKey(config *config.Config, c container.Container) service.ApplicationKeyServicer {
 	svc := &ApplicationKeyService{
 		config:            config,
+		pubSub:            c.LocalPubsub,
 		reader:            c.Repository.RepositoryApplicationKeyReader,
 		writer:            c.Repository.RepositoryApplicationKeyWriter,
 		applicationReader: c.Repository.RepositoryApplicationReader,
@@ -35,6 +39,18 @@ func NewApplicationKey(config *config.Config, c container.Container) service.App
 	return svc
 }
 
+func (s *ApplicationKeyService) InternalFindApplicationKey(ctx context.Context, request dto.RequestInternalFindApplicationKey) (entity.ApplicationKey, error) {
+	applicationKey, err := s.reader.FindApplicationKey(ctx, request.FilterApplicationKey())
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[InternalFindApplicationKey.FindApplicationKey] error find application key")
+		return applicationKey, internalerrors.New(err)
+	}
+
+	return applicationKey, nil
+}
+
 func (s *ApplicationKeyService) IsExistsApplicationKey(ctx context.Context, request dto.RequestFindApplicationKey) (bool, error) {
 	var (
 		response       bool
@@ -186,6 +202,19 @@ func (s *ApplicationKeyService) GenerateOrUpdateApplicationKey(ctx context.Conte
 		return response, internalerrors.New(err)
 	}
 
+	// the new key is not sent, the webhook only knows the key is rotated
+	message, err := json.Marshal(entity.NewWebhookEvent(entity.WebhookEventKeyRotated, applicationKey.ApplicationId))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[GenerateOrUpdateApplicationKey] error marshal webhook event")
+	} else {
+		s.pubSub.PublishMessage(s.config.Pubsub.WebhookDispatcher.Publisher.Topic, pubsub.Message{
+			Key:  applicationKey.ApplicationId,
+			Body: message,
+		})
+	}
+
 	response = dto.ResponseCreateApplicationKey{
 		ApplicationName: application.Name,
 		Key:             applicationKey.Key,
@@ -2,12 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 
 	"github.com/nurcahyaari/coma/config"
 	"github.com/nurcahyaari/coma/container"
 	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
 	"github.com/nurcahyaari/coma/src/application/application/dto"
 	"github.com/nurcahyaari/coma/src/domain/entity"
 	domainrepository "github.com/nurcahyaari/coma/src/domain/repository"
@@ -18,6 +20,7 @@ import (
 
 type ApplicationService struct {
 	config            *config.Config
+	pubSub            *pubsub.Pubsub
 	reader            domainrepository.RepositoryApplicationReader
 	writer            domainrepository.RepositoryApplicationWriter
 	applicationKeySvc domainservice.ApplicationKeyServicer
@@ -26,6 +29,7 @@ type ApplicationService struct {
 func NewApplication(config *config.Config, c container.Container) service.ApplicationServicer {
 	svc := &ApplicationService{
 		config:            config,
+		pubSub:            c.LocalPubsub,
 		reader:            c.Repository.RepositoryApplicationReader,
 		writer:            c.Repository.RepositoryApplicationWriter,
 		applicationKeySvc: c.ApplicationKeyServicer,
@@ -110,15 +114,45 @@ func (s *ApplicationService) CreateApplication(ctx context.Context, request dto.
 }
 
 func (s *ApplicationService) DeleteApplication(ctx context.Context, request dto.RequestFindApplication) error {
-	err := s.writer.DeleteApplication(ctx, entity.FilterApplication{
+	filter := entity.FilterApplication{
 		Id:   request.Id,
 		Name: request.Name,
-	})
+	}
+
+	application, exist, err := s.reader.FindApplication(ctx, filter)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[DeleteApplication] error find application")
+		return internalerrors.New(err)
+	}
+
+	err = s.writer.DeleteApplication(ctx, filter)
 	if err != nil {
 		log.Error().
 			Err(err).
 			Msg("[DeleteApplication] error deleting application")
 		return internalerrors.New(err)
 	}
+
+	if !exist {
+		return nil
+	}
+
+	message, err := json.Marshal(entity.NewWebhookEvent(entity.WebhookEventApplicationDeleted, application.Id))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[DeleteApplication] error marshal webhook event")
+		return nil
+	}
+
+	// the events of the application are dispatched in order, the webhooks
+	// of the application are deleted by the dispatcher of this event
+	s.pubSub.PublishMessage(s.config.Pubsub.WebhookDispatcher.Publisher.Topic, pubsub.Message{
+		Key:  application.Id,
+		Body: message,
+	})
+
 	return nil
 }
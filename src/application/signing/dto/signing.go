@@ -0,0 +1,23 @@
+package dto
+
+import "github.com/nurcahyaari/coma/pkg/signature"
+
+type ResponseVerificationKey struct {
+	KeyId     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+}
+
+type ResponseVerificationKeys []ResponseVerificationKey
+
+func NewResponseVerificationKeys(keys ...signature.VerificationKey) ResponseVerificationKeys {
+	resp := make(ResponseVerificationKeys, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, ResponseVerificationKey{
+			KeyId:     key.KeyId,
+			Algorithm: key.Algorithm,
+			PublicKey: key.PublicKey,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/container"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/application/signing/dto"
+	"github.com/nurcahyaari/coma/src/domain/service"
+)
+
+// SigningService signs the distributed configuration with the signing key,
+// the key is separated from the rsa key of the user token
+type SigningService struct {
+	signer *signature.Signer
+}
+
+func NewSigningService(config *config.Config, c container.Container) service.SigningServicer {
+	signer, err := signature.NewSigner(config.Signing.PrivateKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return &SigningService{
+		signer: signer,
+	}
+}
+
+func (s *SigningService) SignConfiguration(clientKey, revision string, data []byte) (signature.Signature, error) {
+	return s.signer.Sign(clientKey, revision, data)
+}
+
+func (s *SigningService) SignDelta(clientKey, revision, previousRevision string, changes []signature.Change) (signature.Signature, error) {
+	data, err := signature.DeltaData(previousRevision, changes)
+	if err != nil {
+		return signature.Signature{}, err
+	}
+	return s.signer.Sign(clientKey, revision, data)
+}
+
+func (s *SigningService) FindVerificationKeys(ctx context.Context) (dto.ResponseVerificationKeys, error) {
+	return dto.NewResponseVerificationKeys(s.signer.VerificationKey()), nil
+}
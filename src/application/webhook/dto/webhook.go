@@ -0,0 +1,116 @@
+package dto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/google/uuid"
+	internalerror "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+type RequestCreateWebhook struct {
+	ApplicationId string                    `json:"applicationId"`
+	Url           string                    `json:"url"`
+	Secret        string                    `json:"secret"`
+	Events        []entity.WebhookEventType `json:"events"`
+}
+
+func validateWebhookEvents(value any) error {
+	events, _ := value.([]entity.WebhookEventType)
+	for _, event := range events {
+		if _, ok := entity.MapWebhookEventType[event]; !ok {
+			return errors.New("err: webhook event is not found")
+		}
+	}
+	return nil
+}
+
+func (r RequestCreateWebhook) Validate() error {
+	err := validation.ValidateStruct(&r,
+		validation.Field(&r.ApplicationId, validation.Required),
+		validation.Field(&r.Url, validation.Required, is.URL),
+		validation.Field(&r.Events, validation.Required, validation.By(validateWebhookEvents)),
+	)
+	if err == nil {
+		return nil
+	}
+
+	return internalerror.New(err,
+		internalerror.SetErrorCode(http.StatusBadRequest),
+		internalerror.SetErrorSource(internalerror.OZZO_VALIDATION_ERR))
+}
+
+// NewWebhook generates the secret when it isn't given
+func (r RequestCreateWebhook) NewWebhook() (entity.Webhook, error) {
+	secret := r.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return entity.Webhook{}, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	return entity.Webhook{
+		Id:            uuid.New().String(),
+		ApplicationId: r.ApplicationId,
+		Url:           r.Url,
+		Secret:        secret,
+		Events:        r.Events,
+		CreatedAt:     time.Now().UTC(),
+	}, nil
+}
+
+type RequestFindWebhooks struct {
+	ApplicationId string
+}
+
+func (r RequestFindWebhooks) FilterWebhook() entity.FilterWebhook {
+	return entity.FilterWebhook{
+		ApplicationId: r.ApplicationId,
+	}
+}
+
+type RequestDeleteWebhook struct {
+	Id string
+}
+
+type ResponseWebhook struct {
+	Id            string                    `json:"id"`
+	ApplicationId string                    `json:"applicationId"`
+	Url           string                    `json:"url"`
+	Secret        string                    `json:"secret,omitempty"`
+	Events        []entity.WebhookEventType `json:"events"`
+	CreatedAt     time.Time                 `json:"createdAt"`
+}
+
+// NewResponseWebhook hides the secret, it's only shown once when the webhook is created
+func NewResponseWebhook(data entity.Webhook) ResponseWebhook {
+	return ResponseWebhook{
+		Id:            data.Id,
+		ApplicationId: data.ApplicationId,
+		Url:           data.Url,
+		Events:        data.Events,
+		CreatedAt:     data.CreatedAt,
+	}
+}
+
+func (r *ResponseWebhook) AttachSecret(secret string) {
+	r.Secret = secret
+}
+
+type ResponseWebhooks []ResponseWebhook
+
+func NewResponseWebhooks(data entity.Webhooks) ResponseWebhooks {
+	response := make(ResponseWebhooks, 0, len(data))
+	for _, webhook := range data {
+		response = append(response, NewResponseWebhook(webhook))
+	}
+	return response
+}
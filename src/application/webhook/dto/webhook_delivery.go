@@ -0,0 +1,87 @@
+package dto
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	internalerror "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+var MapWebhookDeliveryStatus = map[entity.WebhookDeliveryStatus]string{
+	entity.WebhookDeliveryPending:    "pending",
+	entity.WebhookDeliverySucceeded:  "succeeded",
+	entity.WebhookDeliveryDeadLetter: "dead_letter",
+}
+
+type RequestFindWebhookDeliveries struct {
+	ApplicationId string
+	WebhookId     string
+	Status        entity.WebhookDeliveryStatus
+}
+
+func (r RequestFindWebhookDeliveries) Validate() error {
+	if r.Status == "" {
+		return nil
+	}
+
+	if _, ok := MapWebhookDeliveryStatus[r.Status]; !ok {
+		return internalerror.New(errors.New("err: delivery status is not found"),
+			internalerror.SetErrorCode(http.StatusBadRequest))
+	}
+
+	return nil
+}
+
+func (r RequestFindWebhookDeliveries) FilterWebhookDelivery() entity.FilterWebhookDelivery {
+	return entity.FilterWebhookDelivery{
+		ApplicationId: r.ApplicationId,
+		WebhookId:     r.WebhookId,
+		Status:        r.Status,
+	}
+}
+
+type RequestRedeliverWebhookDelivery struct {
+	Id string
+}
+
+type ResponseWebhookDelivery struct {
+	Id            string                       `json:"id"`
+	WebhookId     string                       `json:"webhookId"`
+	ApplicationId string                       `json:"applicationId"`
+	Url           string                       `json:"url"`
+	Event         entity.WebhookEvent          `json:"event"`
+	Status        entity.WebhookDeliveryStatus `json:"status"`
+	Attempts      int                          `json:"attempts"`
+	ResponseCode  int                          `json:"responseCode"`
+	Error         string                       `json:"error"`
+	CreatedAt     time.Time                    `json:"createdAt"`
+	UpdatedAt     time.Time                    `json:"updatedAt"`
+}
+
+func NewResponseWebhookDelivery(data entity.WebhookDelivery) ResponseWebhookDelivery {
+	return ResponseWebhookDelivery{
+		Id:            data.Id,
+		WebhookId:     data.WebhookId,
+		ApplicationId: data.ApplicationId,
+		Url:           data.Url,
+		Event:         data.Event,
+		Status:        data.Status,
+		Attempts:      data.Attempts,
+		ResponseCode:  data.ResponseCode,
+		Error:         data.Error,
+		CreatedAt:     data.CreatedAt,
+		UpdatedAt:     data.UpdatedAt,
+	}
+}
+
+type ResponseWebhookDeliveries []ResponseWebhookDelivery
+
+func NewResponseWebhookDeliveries(data entity.WebhookDeliveries) ResponseWebhookDeliveries {
+	response := make(ResponseWebhookDeliveries, 0, len(data))
+	for _, delivery := range data {
+		response = append(response, NewResponseWebhookDelivery(delivery))
+	}
+	return response
+}
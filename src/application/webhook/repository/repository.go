@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nurcahyaari/coma/infrastructure/database"
+	"github.com/nurcahyaari/coma/src/domain/repository"
+)
+
+type Repository struct {
+	dbName string
+	db     *database.Clover
+}
+
+func New(db *database.Clover) *Repository {
+	dbName := "webhook"
+	return &Repository{
+		db:     db,
+		dbName: dbName,
+	}
+}
+
+func (r Repository) NewRepositoryWebhookReader() repository.RepositoryWebhookReader {
+	return NewRepositoryWebhookReader(r.db, r.dbName)
+}
+
+func (r Repository) NewRepositoryWebhookWriter() repository.RepositoryWebhookWriter {
+	return NewRepositoryWebhookWriter(r.db, r.dbName)
+}
+
+func (r Repository) NewRepositoryWebhookDeliveryReader() repository.RepositoryWebhookDeliveryReader {
+	return NewRepositoryWebhookDeliveryReader(r.db, fmt.Sprintf("%s_delivery", r.dbName))
+}
+
+func (r Repository) NewRepositoryWebhookDeliveryWriter() repository.RepositoryWebhookDeliveryWriter {
+	return NewRepositoryWebhookDeliveryWriter(r.db, fmt.Sprintf("%s_delivery", r.dbName))
+}
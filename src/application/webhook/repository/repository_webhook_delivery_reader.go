@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/infrastructure/database"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/repository"
+	"github.com/ostafen/clover"
+)
+
+type RepositoryWebhookDeliveryRead struct {
+	dbName string
+	db     *database.Clover
+}
+
+func NewRepositoryWebhookDeliveryReader(db *database.Clover, name string) repository.RepositoryWebhookDeliveryReader {
+	db.DB.CreateCollection(name)
+	return &RepositoryWebhookDeliveryRead{
+		db:     db,
+		dbName: name,
+	}
+}
+
+func (r *RepositoryWebhookDeliveryRead) FindWebhookDelivery(ctx context.Context, filter entity.FilterWebhookDelivery) (entity.WebhookDelivery, bool, error) {
+	deliveries, err := r.FindWebhookDeliveries(ctx, filter)
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return entity.WebhookDelivery{}, false, err
+	}
+	if len(deliveries) == 0 {
+		return entity.WebhookDelivery{}, false, nil
+	}
+
+	return deliveries[0], true, nil
+}
+
+// FindWebhookDeliveries returns the deliveries from the latest one
+func (r *RepositoryWebhookDeliveryRead) FindWebhookDeliveries(ctx context.Context, filter entity.FilterWebhookDelivery) (entity.WebhookDeliveries, error) {
+	var deliveries entity.WebhookDeliveries
+
+	docs, err := r.db.DB.
+		Query(r.dbName).
+		Where(filter.Filter()).
+		Sort(clover.SortOption{Field: "createdAt", Direction: -1}).
+		FindAll()
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		delivery := entity.WebhookDelivery{}
+		err := doc.Unmarshal(&delivery)
+		if err != nil {
+			internalerrors.StackTrace(err)
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/infrastructure/database"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/repository"
+	"github.com/ostafen/clover"
+)
+
+type RepositoryWebhookDeliveryWrite struct {
+	dbName string
+	db     *database.Clover
+}
+
+func NewRepositoryWebhookDeliveryWriter(db *database.Clover, name string) repository.RepositoryWebhookDeliveryWriter {
+	db.DB.CreateCollection(name)
+	return &RepositoryWebhookDeliveryWrite{
+		db:     db,
+		dbName: name,
+	}
+}
+
+func (r *RepositoryWebhookDeliveryWrite) CreateWebhookDelivery(ctx context.Context, data entity.WebhookDelivery) error {
+	dataMap, err := data.MapStringInterface()
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return err
+	}
+
+	doc := clover.NewDocument()
+	doc.SetAll(dataMap)
+
+	_, err = r.db.DB.InsertOne(r.dbName, doc)
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *RepositoryWebhookDeliveryWrite) UpdateWebhookDelivery(ctx context.Context, data entity.WebhookDelivery) error {
+	dataMap, err := data.MapStringInterface()
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return err
+	}
+
+	// update by id only touches the delivery, so the concurrent deliveries don't conflict
+	err = r.db.DB.
+		Query(r.dbName).
+		UpdateById(data.Id, dataMap)
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/infrastructure/database"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/repository"
+)
+
+type RepositoryWebhookRead struct {
+	dbName string
+	db     *database.Clover
+}
+
+func NewRepositoryWebhookReader(db *database.Clover, name string) repository.RepositoryWebhookReader {
+	db.DB.CreateCollection(name)
+	return &RepositoryWebhookRead{
+		db:     db,
+		dbName: name,
+	}
+}
+
+func (r *RepositoryWebhookRead) FindWebhook(ctx context.Context, filter entity.FilterWebhook) (entity.Webhook, bool, error) {
+	webhooks, err := r.FindWebhooks(ctx, filter)
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return entity.Webhook{}, false, err
+	}
+	if len(webhooks) == 0 {
+		return entity.Webhook{}, false, nil
+	}
+
+	return webhooks[0], true, nil
+}
+
+func (r *RepositoryWebhookRead) FindWebhooks(ctx context.Context, filter entity.FilterWebhook) (entity.Webhooks, error) {
+	var webhooks entity.Webhooks
+
+	docs, err := r.db.DB.
+		Query(r.dbName).
+		Where(filter.Filter()).
+		FindAll()
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		webhook := entity.Webhook{}
+		err := doc.Unmarshal(&webhook)
+		if err != nil {
+			internalerrors.StackTrace(err)
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/infrastructure/database"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/repository"
+	"github.com/ostafen/clover"
+)
+
+type RepositoryWebhookWrite struct {
+	dbName string
+	db     *database.Clover
+}
+
+func NewRepositoryWebhookWriter(db *database.Clover, name string) repository.RepositoryWebhookWriter {
+	db.DB.CreateCollection(name)
+	return &RepositoryWebhookWrite{
+		db:     db,
+		dbName: name,
+	}
+}
+
+func (r *RepositoryWebhookWrite) CreateWebhook(ctx context.Context, data entity.Webhook) error {
+	dataMap, err := data.MapStringInterface()
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return err
+	}
+
+	doc := clover.NewDocument()
+	doc.SetAll(dataMap)
+
+	_, err = r.db.DB.InsertOne(r.dbName, doc)
+	if err != nil {
+		internalerrors.StackTrace(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *RepositoryWebhookWrite) DeleteWebhook(ctx context.Context, filter entity.FilterWebhook) error {
+	err := r.db.DB.
+		Query(r.dbName).
+		Where(filter.Filter()).
+		Delete()
+	if err != nil {
+		internalerrors.StackTrace(err)
+	}
+
+	return err
+}
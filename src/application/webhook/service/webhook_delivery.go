@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HeaderWebhookEvent     = "X-Coma-Event"
+	HeaderWebhookDelivery  = "X-Coma-Delivery"
+	HeaderWebhookSignature = "X-Coma-Signature"
+)
+
+// deliveryPollInterval is the interval of looking up the due deliveries
+const deliveryPollInterval = time.Second
+
+// RunWebhookDeliveries attempts the pending deliveries when they're due until the context is done.
+// The deliveries are kept in the database between the attempts, so the pending deliveries
+// are resumed after a restart and the attempt that is cut off by the shutdown is made again
+func (s *WebhookService) RunWebhookDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(deliveryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.attemptDueDeliveries(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// notify wakes up the deliveries to attempt the new pending delivery right away
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *WebhookService) attemptDueDeliveries(ctx context.Context) {
+	deliveries, err := s.deliveryReader.FindWebhookDeliveries(ctx, entity.FilterWebhookDelivery{
+		Status: entity.WebhookDeliveryPending,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[attemptDueDeliveries.FindWebhookDeliveries] error find pending webhook deliveries")
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, delivery := range deliveries {
+		if !delivery.Due(now) || !s.startAttempt(delivery.Id) {
+			continue
+		}
+
+		go func(delivery entity.WebhookDelivery) {
+			defer s.finishAttempt(delivery.Id)
+			s.attempt(ctx, delivery)
+		}(delivery)
+	}
+}
+
+// startAttempt returns false when the delivery is already attempted
+func (s *WebhookService) startAttempt(id string) bool {
+	s.attemptingMtx.Lock()
+	defer s.attemptingMtx.Unlock()
+
+	if _, attempting := s.attempting[id]; attempting {
+		return false
+	}
+	s.attempting[id] = struct{}{}
+	return true
+}
+
+func (s *WebhookService) finishAttempt(id string) {
+	s.attemptingMtx.Lock()
+	defer s.attemptingMtx.Unlock()
+
+	delete(s.attempting, id)
+}
+
+// attempt posts the payload to the webhook once, the failing delivery is scheduled for the next
+// attempt with exponential backoff and dead-lettered when it's still failing after its retries.
+// The delivery that is cut off by the shutdown stays pending and it's attempted again on the next start
+func (s *WebhookService) attempt(ctx context.Context, delivery entity.WebhookDelivery) {
+	webhook, exist, err := s.reader.FindWebhook(ctx, entity.FilterWebhook{
+		Id: delivery.WebhookId,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("deliveryId", delivery.Id).
+			Msg("[attempt.FindWebhook] error find webhook")
+		return
+	}
+
+	var payload []byte
+	if exist {
+		payload, err = delivery.Payload()
+	}
+	switch {
+	case !exist:
+		err = backoff.Permanent(errors.New("err: webhook not found"))
+	case err != nil:
+		err = backoff.Permanent(err)
+	default:
+		delivery.Attempts++
+		delivery.ResponseCode, err = s.post(ctx, webhook, delivery, payload)
+	}
+
+	if ctx.Err() != nil {
+		log.Warn().
+			Str("deliveryId", delivery.Id).
+			Msg("[attempt] the delivery is cut off, it stays pending")
+		return
+	}
+
+	now := time.Now().UTC()
+	var permanent *backoff.PermanentError
+	switch {
+	case err == nil:
+		delivery.Status = entity.WebhookDeliverySucceeded
+		delivery.Error = ""
+	case errors.As(err, &permanent):
+		delivery.Status = entity.WebhookDeliveryDeadLetter
+		delivery.Error = permanent.Err.Error()
+	default:
+		log.Warn().
+			Err(err).
+			Str("deliveryId", delivery.Id).
+			Int("attempts", delivery.Attempts).
+			Msg("[attempt] error deliver webhook")
+
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = now.Add(s.retryInterval(delivery.Attempts))
+		if delivery.NextAttemptAt.After(delivery.RetryUntil) {
+			delivery.Status = entity.WebhookDeliveryDeadLetter
+		}
+	}
+	delivery.UpdatedAt = now
+
+	// the delivery log must be saved even when the context is done
+	err = s.deliveryWriter.UpdateWebhookDelivery(context.Background(), delivery)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("deliveryId", delivery.Id).
+			Msg("[attempt.UpdateWebhookDelivery] error update webhook delivery")
+	}
+
+	// the webhook of the deleted application was kept to sign this delivery
+	if exist && delivery.Status != entity.WebhookDeliveryPending &&
+		delivery.Event.Type == entity.WebhookEventApplicationDeleted {
+		s.deleteApplicationWebhook(context.Background(), webhook.Id)
+	}
+}
+
+// retryInterval is the exponential backoff after the attempts, it's capped by the max interval
+func (s *WebhookService) retryInterval(attempts int) time.Duration {
+	backoffExponential := backoff.NewExponentialBackOff()
+	backoffExponential.MaxInterval = s.config.Webhook.MaxInterval
+	backoffExponential.MaxElapsedTime = 0
+
+	interval := backoffExponential.InitialInterval
+	for i := 0; i < attempts; i++ {
+		interval = backoffExponential.NextBackOff()
+	}
+	return interval
+}
+
+// post sends a single attempt, the client error except timeout and rate limit is not retried
+func (s *WebhookService) post(ctx context.Context, webhook entity.Webhook, delivery entity.WebhookDelivery, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, backoff.Permanent(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderWebhookDelivery, delivery.Id)
+	req.Header.Set(HeaderWebhookSignature, fmt.Sprintf("sha256=%s", webhook.Sign(payload)))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.StatusCode, nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return resp.StatusCode, fmt.Errorf("err: webhook responded with status %d", resp.StatusCode)
+	default:
+		return resp.StatusCode, backoff.Permanent(
+			fmt.Errorf("err: webhook responded with status %d", resp.StatusCode))
+	}
+}
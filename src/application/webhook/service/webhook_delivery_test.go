@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/src/application/webhook/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepository keeps the webhooks and the deliveries of the tests
+type memoryRepository struct {
+	mtx        sync.Mutex
+	webhooks   entity.Webhooks
+	deliveries map[string]entity.WebhookDelivery
+}
+
+func (r *memoryRepository) FindWebhook(ctx context.Context, filter entity.FilterWebhook) (entity.Webhook, bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, webhook := range r.webhooks {
+		if webhook.Id == filter.Id {
+			return webhook, true, nil
+		}
+	}
+	return entity.Webhook{}, false, nil
+}
+
+func (r *memoryRepository) FindWebhooks(ctx context.Context, filter entity.FilterWebhook) (entity.Webhooks, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append(entity.Webhooks{}, r.webhooks...), nil
+}
+
+func (r *memoryRepository) CreateWebhook(ctx context.Context, data entity.Webhook) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.webhooks = append(r.webhooks, data)
+	return nil
+}
+
+func (r *memoryRepository) DeleteWebhook(ctx context.Context, filter entity.FilterWebhook) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	webhooks := entity.Webhooks{}
+	for _, webhook := range r.webhooks {
+		if webhook.Id != filter.Id {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	r.webhooks = webhooks
+	return nil
+}
+
+// webhookIds returns the ids of the webhooks that aren't deleted
+func (r *memoryRepository) webhookIds() []string {
+	webhooks, _ := r.FindWebhooks(context.Background(), entity.FilterWebhook{})
+	ids := []string{}
+	for _, webhook := range webhooks {
+		ids = append(ids, webhook.Id)
+	}
+	return ids
+}
+
+func (r *memoryRepository) CreateWebhookDelivery(ctx context.Context, data entity.WebhookDelivery) error {
+	return r.UpdateWebhookDelivery(ctx, data)
+}
+
+func (r *memoryRepository) UpdateWebhookDelivery(ctx context.Context, data entity.WebhookDelivery) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.deliveries[data.Id] = data
+	return nil
+}
+
+func (r *memoryRepository) FindWebhookDelivery(ctx context.Context, filter entity.FilterWebhookDelivery) (entity.WebhookDelivery, bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delivery, exist := r.deliveries[filter.Id]
+	return delivery, exist, nil
+}
+
+func (r *memoryRepository) FindWebhookDeliveries(ctx context.Context, filter entity.FilterWebhookDelivery) (entity.WebhookDeliveries, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	deliveries := entity.WebhookDeliveries{}
+	for _, delivery := range r.deliveries {
+		if filter.Status == "" || delivery.Status == filter.Status {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+// only returns the only delivery of the repository
+func (r *memoryRepository) only(t *testing.T) entity.WebhookDelivery {
+	t.Helper()
+	deliveries, _ := r.FindWebhookDeliveries(context.Background(), entity.FilterWebhookDelivery{})
+	require.Len(t, deliveries, 1)
+	return deliveries[0]
+}
+
+func newTestWebhookService(t *testing.T, handler http.HandlerFunc) (*WebhookService, *memoryRepository) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	repository := &memoryRepository{
+		webhooks: entity.Webhooks{{
+			Id:     "webhook-1",
+			Url:    server.URL,
+			Events: []entity.WebhookEventType{entity.WebhookEventConfigurationChanged},
+		}},
+		deliveries: map[string]entity.WebhookDelivery{},
+	}
+	svc := &WebhookService{
+		config: &config.Config{
+			Webhook: config.WebhookConfig{
+				Timeout:        5 * time.Second,
+				MaxInterval:    10 * time.Millisecond,
+				MaxElapsedTime: time.Minute,
+			},
+		},
+		httpClient:     server.Client(),
+		reader:         repository,
+		writer:         repository,
+		deliveryReader: repository,
+		deliveryWriter: repository,
+		wake:           make(chan struct{}, 1),
+		attempting:     make(map[string]struct{}),
+	}
+	return svc, repository
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	testCases := []struct {
+		name     string
+		statuses []int
+		status   entity.WebhookDeliveryStatus
+		attempts int
+	}{
+		{
+			name:     "delivered",
+			statuses: []int{http.StatusOK},
+			status:   entity.WebhookDeliverySucceeded,
+			attempts: 1,
+		},
+		{
+			name:     "retried",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			status:   entity.WebhookDeliverySucceeded,
+			attempts: 3,
+		},
+		{
+			name:     "rejected",
+			statuses: []int{http.StatusBadRequest},
+			status:   entity.WebhookDeliveryDeadLetter,
+			attempts: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			svc, repository := newTestWebhookService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statuses[requests.Add(1)-1])
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			go svc.RunWebhookDeliveries(ctx)
+
+			// the event is recorded without waiting for the delivery
+			require.NoError(t, svc.DispatchWebhookEvent(ctx, entity.NewWebhookEvent(entity.WebhookEventConfigurationChanged, "app-1")))
+
+			require.Eventually(t, func() bool {
+				return repository.only(t).Status == test.status
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, test.attempts, repository.only(t).Attempts)
+		})
+	}
+}
+
+func TestWebhookDeliveryShutdown(t *testing.T) {
+	// the first attempt hangs until it's cut off by the shutdown
+	var requests atomic.Int32
+	release := make(chan struct{})
+	svc, repository := newTestWebhookService(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.RunWebhookDeliveries(ctx)
+		close(done)
+	}()
+	require.NoError(t, svc.DispatchWebhookEvent(ctx, entity.NewWebhookEvent(entity.WebhookEventConfigurationChanged, "app-1")))
+	require.Eventually(t, func() bool {
+		return requests.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	require.Eventually(t, func() bool {
+		svc.attemptingMtx.Lock()
+		defer svc.attemptingMtx.Unlock()
+		return len(svc.attempting) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the cut off delivery isn't dead-lettered, it's resumed on the next start
+	delivery := repository.only(t)
+	assert.Equal(t, entity.WebhookDeliveryPending, delivery.Status)
+	assert.Zero(t, delivery.Attempts)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go svc.RunWebhookDeliveries(ctx)
+	require.Eventually(t, func() bool {
+		return repository.only(t).Status == entity.WebhookDeliverySucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, repository.only(t).Attempts)
+}
+
+func TestRedeliverStaleWebhookDelivery(t *testing.T) {
+	svc, repository := newTestWebhookService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// the pending delivery is in progress until its retries are over
+	delivery := entity.NewWebhookDelivery(repository.webhooks[0], entity.WebhookEvent{}, time.Minute)
+	delivery.NextAttemptAt = time.Now().Add(time.Hour)
+	require.NoError(t, repository.CreateWebhookDelivery(context.Background(), delivery))
+	assert.Error(t, svc.RedeliverWebhookDelivery(context.Background(), dto.RequestRedeliverWebhookDelivery{Id: delivery.Id}))
+
+	delivery.RetryUntil = time.Now().Add(-time.Second)
+	require.NoError(t, repository.UpdateWebhookDelivery(context.Background(), delivery))
+	require.NoError(t, svc.RedeliverWebhookDelivery(context.Background(), dto.RequestRedeliverWebhookDelivery{Id: delivery.Id}))
+	assert.True(t, repository.only(t).Due(time.Now()))
+}
+
+func TestDispatchApplicationDeleted(t *testing.T) {
+	release := make(chan struct{})
+	svc, repository := newTestWebhookService(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	require.NoError(t, repository.CreateWebhook(context.Background(), entity.Webhook{
+		Id:     "webhook-2",
+		Url:    repository.webhooks[0].Url,
+		Events: []entity.WebhookEventType{entity.WebhookEventApplicationDeleted},
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go svc.RunWebhookDeliveries(ctx)
+
+	// the webhook without the delivery is deleted right away
+	require.NoError(t, svc.DispatchWebhookEvent(ctx, entity.NewWebhookEvent(entity.WebhookEventApplicationDeleted, "app-1")))
+	assert.Equal(t, []string{"webhook-2"}, repository.webhookIds())
+
+	// the webhook is kept until its delivery is settled, the delivery is signed by its secret
+	close(release)
+	require.Eventually(t, func() bool {
+		return repository.only(t).Status == entity.WebhookDeliverySucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return len(repository.webhookIds()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
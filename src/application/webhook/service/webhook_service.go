@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/container"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/src/application/webhook/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	domainrepository "github.com/nurcahyaari/coma/src/domain/repository"
+	"github.com/nurcahyaari/coma/src/domain/service"
+	"github.com/rs/zerolog/log"
+)
+
+type WebhookService struct {
+	config            *config.Config
+	httpClient        *http.Client
+	reader            domainrepository.RepositoryWebhookReader
+	writer            domainrepository.RepositoryWebhookWriter
+	deliveryReader    domainrepository.RepositoryWebhookDeliveryReader
+	deliveryWriter    domainrepository.RepositoryWebhookDeliveryWriter
+	applicationReader domainrepository.RepositoryApplicationReader
+
+	// wake signals the deliveries that a delivery is pending
+	wake chan struct{}
+	// attempting are the ids of the deliveries that are attempted
+	attemptingMtx sync.Mutex
+	attempting    map[string]struct{}
+}
+
+func NewWebhookService(config *config.Config, c container.Container) service.WebhookServicer {
+	svc := &WebhookService{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Webhook.Timeout,
+		},
+		reader:            c.Repository.RepositoryWebhookReader,
+		writer:            c.Repository.RepositoryWebhookWriter,
+		deliveryReader:    c.Repository.RepositoryWebhookDeliveryReader,
+		deliveryWriter:    c.Repository.RepositoryWebhookDeliveryWriter,
+		applicationReader: c.Repository.RepositoryApplicationReader,
+		wake:              make(chan struct{}, 1),
+		attempting:        make(map[string]struct{}),
+	}
+	return svc
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, request dto.RequestCreateWebhook) (dto.ResponseWebhook, error) {
+	var response dto.ResponseWebhook
+
+	if err := request.Validate(); err != nil {
+		return response, err
+	}
+
+	_, exist, err := s.applicationReader.FindApplication(ctx, entity.FilterApplication{
+		Id: request.ApplicationId,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[CreateWebhook.FindApplication] error find application")
+		return response, internalerrors.New(err)
+	}
+	if !exist {
+		err = errors.New("err: application not found")
+		log.Error().
+			Err(err).
+			Msg("[CreateWebhook.FindApplication] error: application not found")
+		return response, internalerrors.New(err, internalerrors.SetErrorCode(http.StatusNotFound))
+	}
+
+	webhook, err := request.NewWebhook()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[CreateWebhook.NewWebhook] error generate webhook secret")
+		return response, internalerrors.New(err)
+	}
+
+	err = s.writer.CreateWebhook(ctx, webhook)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[CreateWebhook.CreateWebhook] error create webhook")
+		return response, internalerrors.New(err)
+	}
+
+	response = dto.NewResponseWebhook(webhook)
+	response.AttachSecret(webhook.Secret)
+
+	return response, nil
+}
+
+func (s *WebhookService) FindWebhooks(ctx context.Context, request dto.RequestFindWebhooks) (dto.ResponseWebhooks, error) {
+	webhooks, err := s.reader.FindWebhooks(ctx, request.FilterWebhook())
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[FindWebhooks.FindWebhooks] error find webhooks")
+		return nil, internalerrors.New(err)
+	}
+
+	return dto.NewResponseWebhooks(webhooks), nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, request dto.RequestDeleteWebhook) error {
+	_, exist, err := s.reader.FindWebhook(ctx, entity.FilterWebhook{
+		Id: request.Id,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[DeleteWebhook.FindWebhook] error find webhook")
+		return internalerrors.New(err)
+	}
+	if !exist {
+		return internalerrors.New(errors.New("err: webhook not found"),
+			internalerrors.SetErrorCode(http.StatusNotFound))
+	}
+
+	err = s.writer.DeleteWebhook(ctx, entity.FilterWebhook{
+		Id: request.Id,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[DeleteWebhook.DeleteWebhook] error delete webhook")
+		return internalerrors.New(err)
+	}
+
+	return nil
+}
+
+func (s *WebhookService) FindWebhookDeliveries(ctx context.Context, request dto.RequestFindWebhookDeliveries) (dto.ResponseWebhookDeliveries, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.deliveryReader.FindWebhookDeliveries(ctx, request.FilterWebhookDelivery())
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[FindWebhookDeliveries.FindWebhookDeliveries] error find webhook deliveries")
+		return nil, internalerrors.New(err)
+	}
+
+	return dto.NewResponseWebhookDeliveries(deliveries), nil
+}
+
+// RedeliverWebhookDelivery sends the delivery again in the background with the same payload
+// and the current secret of the webhook. The pending delivery is only sent again when it's stale
+func (s *WebhookService) RedeliverWebhookDelivery(ctx context.Context, request dto.RequestRedeliverWebhookDelivery) error {
+	delivery, exist, err := s.deliveryReader.FindWebhookDelivery(ctx, entity.FilterWebhookDelivery{
+		Id: request.Id,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[RedeliverWebhookDelivery.FindWebhookDelivery] error find webhook delivery")
+		return internalerrors.New(err)
+	}
+	if !exist {
+		return internalerrors.New(errors.New("err: webhook delivery not found"),
+			internalerrors.SetErrorCode(http.StatusNotFound))
+	}
+	if delivery.Status == entity.WebhookDeliveryPending && !delivery.Stale(time.Now().UTC()) {
+		return internalerrors.New(errors.New("err: webhook delivery is in progress"),
+			internalerrors.SetErrorCode(http.StatusConflict))
+	}
+
+	_, exist, err = s.reader.FindWebhook(ctx, entity.FilterWebhook{
+		Id: delivery.WebhookId,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[RedeliverWebhookDelivery.FindWebhook] error find webhook")
+		return internalerrors.New(err)
+	}
+	if !exist {
+		return internalerrors.New(errors.New("err: webhook not found"),
+			internalerrors.SetErrorCode(http.StatusNotFound))
+	}
+
+	delivery.Retry(s.config.Webhook.MaxElapsedTime)
+	err = s.deliveryWriter.UpdateWebhookDelivery(ctx, delivery)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[RedeliverWebhookDelivery.UpdateWebhookDelivery] error update webhook delivery")
+		return internalerrors.New(err)
+	}
+
+	s.notify()
+
+	return nil
+}
+
+// DispatchWebhookEvent records the pending deliveries of the event, they're attempted
+// in the background by RunWebhookDeliveries, so a failing webhook doesn't hold the event.
+// The webhooks of the deleted application are deleted as well, the webhook that has
+// an application.deleted delivery is kept until the delivery is settled
+func (s *WebhookService) DispatchWebhookEvent(ctx context.Context, event entity.WebhookEvent) error {
+	webhooks, err := s.reader.FindWebhooks(ctx, entity.FilterWebhook{
+		ApplicationId: event.ApplicationId,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[DispatchWebhookEvent.FindWebhooks] error find webhooks")
+		return internalerrors.New(err)
+	}
+
+	for _, webhook := range webhooks {
+		delivered := false
+		if webhook.Subscribed(event.Type) {
+			delivery := entity.NewWebhookDelivery(webhook, event, s.config.Webhook.MaxElapsedTime)
+			err = s.deliveryWriter.CreateWebhookDelivery(ctx, delivery)
+			if err != nil {
+				log.Error().
+					Err(err).
+					Str("webhookId", webhook.Id).
+					Msg("[DispatchWebhookEvent.CreateWebhookDelivery] error create webhook delivery")
+			}
+			delivered = err == nil
+		}
+
+		if event.Type == entity.WebhookEventApplicationDeleted && !delivered {
+			s.deleteApplicationWebhook(ctx, webhook.Id)
+		}
+	}
+	s.notify()
+
+	return nil
+}
+
+// deleteApplicationWebhook deletes the webhook of the deleted application,
+// the failure is logged only because the application is already deleted
+func (s *WebhookService) deleteApplicationWebhook(ctx context.Context, webhookId string) {
+	err := s.writer.DeleteWebhook(ctx, entity.FilterWebhook{
+		Id: webhookId,
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("webhookId", webhookId).
+			Msg("[deleteApplicationWebhook.DeleteWebhook] error delete webhook")
+	}
+}
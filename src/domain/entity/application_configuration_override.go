@@ -0,0 +1,151 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ostafen/clover"
+)
+
+// ConfigurationOverride replaces the fields of the configuration for the clients that
+// match the selector, the instance id targets a single instance and the labels target
+// a group of instances, e.g. a canary. The selector is "and"
+type ConfigurationOverride struct {
+	Id         string            `json:"id"`
+	ClientKey  string            `json:"clientKey"`
+	Name       string            `json:"name"`
+	InstanceId string            `json:"instanceId"`
+	Labels     map[string]string `json:"labels"`
+	Values     map[string]any    `json:"values"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// Match tells whether the client is selected by the override,
+// the override without selector never matches
+func (o ConfigurationOverride) Match(instanceId string, labels map[string]string) bool {
+	if o.InstanceId == "" && len(o.Labels) == 0 {
+		return false
+	}
+
+	if o.InstanceId != "" && o.InstanceId != instanceId {
+		return false
+	}
+
+	for key, value := range o.Labels {
+		if labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (o ConfigurationOverride) MapStringInterface() (map[string]interface{}, error) {
+	mapStringIntf := make(map[string]interface{})
+	j, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(j, &mapStringIntf)
+	if err != nil {
+		return nil, err
+	}
+	return mapStringIntf, nil
+}
+
+type ConfigurationOverrides []ConfigurationOverride
+
+// Matching returns the overrides of the client in the applied order, the label overrides
+// are applied first then the instance overrides, so the instance override always wins
+func (os ConfigurationOverrides) Matching(instanceId string, labels map[string]string) ConfigurationOverrides {
+	matching := make(ConfigurationOverrides, 0)
+	for _, o := range os {
+		if o.Match(instanceId, labels) {
+			matching = append(matching, o)
+		}
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		if (matching[i].InstanceId == "") != (matching[j].InstanceId == "") {
+			return matching[i].InstanceId == ""
+		}
+		return matching[i].CreatedAt.Before(matching[j].CreatedAt)
+	})
+
+	return matching
+}
+
+// Key identifies the set of the overrides, the clients with the same key
+// receive the same configuration
+func (os ConfigurationOverrides) Key() string {
+	ids := make([]string, 0, len(os))
+	for _, o := range os {
+		ids = append(ids, o.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+// Apply replaces the fields of the JSON configuration with the values of the overrides,
+// the data is returned as is when there is no override
+func (os ConfigurationOverrides) Apply(data json.RawMessage) (json.RawMessage, error) {
+	if len(os) == 0 {
+		return data, nil
+	}
+
+	fields := make(map[string]any)
+	if len(data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, o := range os {
+		for field, value := range o.Values {
+			fields[field] = value
+		}
+	}
+
+	// the map is marshaled with the sorted keys, the same as the configuration
+	return json.Marshal(fields)
+}
+
+// FilterConfigurationOverride lets you filter its data, the argument is "and"
+type FilterConfigurationOverride struct {
+	Id        string
+	ClientKey string
+}
+
+func (f FilterConfigurationOverride) Filter() *clover.Criteria {
+	criterias := make([]*clover.Criteria, 0)
+
+	if f.Id != "" {
+		criterias = append(criterias, clover.Field("id").Eq(f.Id))
+	}
+
+	if f.ClientKey != "" {
+		criterias = append(criterias, clover.Field("clientKey").Eq(f.ClientKey))
+	}
+
+	filter := &clover.Criteria{}
+
+	if len(criterias) == 0 {
+		return nil
+	}
+
+	for idx, criteria := range criterias {
+		if idx == 0 {
+			filter = criteria
+			continue
+		}
+
+		filter = filter.And(criteria)
+	}
+
+	return filter
+}
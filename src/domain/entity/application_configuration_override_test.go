@@ -0,0 +1,74 @@
+package entity_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigurationOverridesMatching(t *testing.T) {
+	now := time.Now()
+	overrides := entity.ConfigurationOverrides{
+		{Id: "instance", InstanceId: "pod-1", CreatedAt: now},
+		{Id: "canary", Labels: map[string]string{"track": "canary"}, CreatedAt: now.Add(time.Second)},
+		{Id: "canary-eu", Labels: map[string]string{"track": "canary", "region": "eu"}, CreatedAt: now.Add(-time.Second)},
+		{Id: "no-selector"},
+	}
+
+	testCases := []struct {
+		name       string
+		instanceId string
+		labels     map[string]string
+		expected   string
+	}{
+		{
+			name:       "nothing matches",
+			instanceId: "pod-2",
+			labels:     map[string]string{"track": "stable"},
+			expected:   "",
+		},
+		{
+			name:       "the labels must all match",
+			instanceId: "pod-2",
+			labels:     map[string]string{"track": "canary"},
+			expected:   "canary",
+		},
+		{
+			name:       "the label overrides are ordered by the creation",
+			instanceId: "pod-2",
+			labels:     map[string]string{"track": "canary", "region": "eu"},
+			expected:   "canary-eu,canary",
+		},
+		{
+			name:       "the instance override is applied last",
+			instanceId: "pod-1",
+			labels:     map[string]string{"track": "canary"},
+			expected:   "canary,instance",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, overrides.Matching(test.instanceId, test.labels).Key())
+		})
+	}
+}
+
+func TestConfigurationOverridesApply(t *testing.T) {
+	data := json.RawMessage(`{"url":"http://host","big":123456789012345678901,"feature":false}`)
+
+	act, err := entity.ConfigurationOverrides{}.Apply(data)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(act))
+
+	act, err = entity.ConfigurationOverrides{
+		{Values: map[string]any{"feature": true, "replicas": 2}},
+		{Values: map[string]any{"replicas": 3}},
+	}.Apply(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"big":123456789012345678901,"feature":true,"replicas":3,"url":"http://host"}`, string(act))
+}
@@ -0,0 +1,121 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/ostafen/clover"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventConfigurationChanged WebhookEventType = "configuration.changed"
+	WebhookEventKeyRotated           WebhookEventType = "key.rotated"
+	WebhookEventApplicationDeleted   WebhookEventType = "application.deleted"
+)
+
+var MapWebhookEventType = map[WebhookEventType]string{
+	WebhookEventConfigurationChanged: "configuration.changed",
+	WebhookEventKeyRotated:           "key.rotated",
+	WebhookEventApplicationDeleted:   "application.deleted",
+}
+
+type Webhook struct {
+	Id            string             `json:"_id"`
+	ApplicationId string             `json:"applicationId"`
+	Url           string             `json:"url"`
+	Secret        string             `json:"secret"`
+	Events        []WebhookEventType `json:"events"`
+	CreatedAt     time.Time          `json:"createdAt"`
+}
+
+// Subscribed returns true when the webhook listens to the event
+func (w Webhook) Subscribed(eventType WebhookEventType) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the hex HMAC-SHA256 of the payload using the webhook secret,
+// it's sent as "sha256=<signature>" on the X-Coma-Signature header
+func (w Webhook) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w Webhook) MapStringInterface() (map[string]interface{}, error) {
+	mapStringIntf := make(map[string]interface{})
+	j, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(j, &mapStringIntf)
+	if err != nil {
+		return nil, err
+	}
+	return mapStringIntf, nil
+}
+
+type Webhooks []Webhook
+
+type FilterWebhook struct {
+	Id            string
+	ApplicationId string
+}
+
+func (f FilterWebhook) Filter() *clover.Criteria {
+	criterias := make([]*clover.Criteria, 0)
+
+	if f.Id != "" {
+		criterias = append(criterias, clover.Field("_id").Eq(f.Id))
+	}
+
+	if f.ApplicationId != "" {
+		criterias = append(criterias, clover.Field("applicationId").Eq(f.ApplicationId))
+	}
+
+	filter := &clover.Criteria{}
+
+	if len(criterias) == 0 {
+		return nil
+	}
+
+	for idx, criteria := range criterias {
+		if idx == 0 {
+			filter = criteria
+			continue
+		}
+
+		filter = filter.And(criteria)
+	}
+
+	return filter
+}
+
+// WebhookEvent is published by the services and delivered to the subscribed webhooks,
+// the client key is never sent since it's the credential of the clients
+type WebhookEvent struct {
+	Type             WebhookEventType `json:"event"`
+	ApplicationId    string           `json:"applicationId"`
+	Revision         string           `json:"revision,omitempty"`
+	PreviousRevision string           `json:"previousRevision,omitempty"`
+	Changes          json.RawMessage  `json:"changes,omitempty"`
+	OccurredAt       time.Time        `json:"occurredAt"`
+}
+
+func NewWebhookEvent(eventType WebhookEventType, applicationId string) WebhookEvent {
+	return WebhookEvent{
+		Type:          eventType,
+		ApplicationId: applicationId,
+		OccurredAt:    time.Now().UTC(),
+	}
+}
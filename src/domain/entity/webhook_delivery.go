@@ -0,0 +1,146 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ostafen/clover"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending    WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded  WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryDeadLetter WebhookDeliveryStatus = "dead_letter"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook, the pending delivery is
+// attempted at NextAttemptAt and dead-lettered when it's still failing after RetryUntil
+type WebhookDelivery struct {
+	Id            string                `json:"_id"`
+	WebhookId     string                `json:"webhookId"`
+	ApplicationId string                `json:"applicationId"`
+	Url           string                `json:"url"`
+	Event         WebhookEvent          `json:"event"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	ResponseCode  int                   `json:"responseCode"`
+	Error         string                `json:"error"`
+	NextAttemptAt time.Time             `json:"nextAttemptAt"`
+	RetryUntil    time.Time             `json:"retryUntil"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	UpdatedAt     time.Time             `json:"updatedAt"`
+}
+
+// NewWebhookDelivery creates the pending delivery, it's attempted right away
+// and retried until the max elapsed time
+func NewWebhookDelivery(webhook Webhook, event WebhookEvent, maxElapsedTime time.Duration) WebhookDelivery {
+	now := time.Now().UTC()
+	return WebhookDelivery{
+		Id:            uuid.New().String(),
+		WebhookId:     webhook.Id,
+		ApplicationId: webhook.ApplicationId,
+		Url:           webhook.Url,
+		Event:         event,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: now,
+		RetryUntil:    now.Add(maxElapsedTime),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// Retry makes the delivery pending again, it's attempted right away
+// and retried until the max elapsed time
+func (d *WebhookDelivery) Retry(maxElapsedTime time.Duration) {
+	now := time.Now().UTC()
+	d.Status = WebhookDeliveryPending
+	d.NextAttemptAt = now
+	d.RetryUntil = now.Add(maxElapsedTime)
+	d.UpdatedAt = now
+}
+
+// Due returns true when the pending delivery is attempted at the time
+func (d WebhookDelivery) Due(at time.Time) bool {
+	return d.Status == WebhookDeliveryPending && !d.NextAttemptAt.After(at)
+}
+
+// Stale returns true when the pending delivery isn't settled after its retries,
+// e.g. the process stopped while it was attempted
+func (d WebhookDelivery) Stale(at time.Time) bool {
+	return d.Status == WebhookDeliveryPending && !d.RetryUntil.IsZero() && at.After(d.RetryUntil)
+}
+
+// Payload is the request body of the delivery, the delivery id is sent
+// so the receiver can drop the duplicated delivery
+func (d WebhookDelivery) Payload() ([]byte, error) {
+	return json.Marshal(struct {
+		Id string `json:"id"`
+		WebhookEvent
+	}{
+		Id:           d.Id,
+		WebhookEvent: d.Event,
+	})
+}
+
+func (d WebhookDelivery) MapStringInterface() (map[string]interface{}, error) {
+	mapStringIntf := make(map[string]interface{})
+	j, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(j, &mapStringIntf)
+	if err != nil {
+		return nil, err
+	}
+	return mapStringIntf, nil
+}
+
+type WebhookDeliveries []WebhookDelivery
+
+type FilterWebhookDelivery struct {
+	Id            string
+	WebhookId     string
+	ApplicationId string
+	Status        WebhookDeliveryStatus
+}
+
+func (f FilterWebhookDelivery) Filter() *clover.Criteria {
+	criterias := make([]*clover.Criteria, 0)
+
+	if f.Id != "" {
+		criterias = append(criterias, clover.Field("_id").Eq(f.Id))
+	}
+
+	if f.WebhookId != "" {
+		criterias = append(criterias, clover.Field("webhookId").Eq(f.WebhookId))
+	}
+
+	if f.ApplicationId != "" {
+		criterias = append(criterias, clover.Field("applicationId").Eq(f.ApplicationId))
+	}
+
+	if f.Status != "" {
+		criterias = append(criterias, clover.Field("status").Eq(string(f.Status)))
+	}
+
+	filter := &clover.Criteria{}
+
+	if len(criterias) == 0 {
+		return nil
+	}
+
+	for idx, criteria := range criterias {
+		if idx == 0 {
+			filter = criteria
+			continue
+		}
+
+		filter = filter.And(criteria)
+	}
+
+	return filter
+}
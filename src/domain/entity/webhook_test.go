@@ -0,0 +1,122 @@
+package entity_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSubscribed(t *testing.T) {
+	webhook := entity.Webhook{
+		Events: []entity.WebhookEventType{
+			entity.WebhookEventConfigurationChanged,
+			entity.WebhookEventKeyRotated,
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		eventType entity.WebhookEventType
+		expected  bool
+	}{
+		{
+			name:      "subscribed event",
+			eventType: entity.WebhookEventConfigurationChanged,
+			expected:  true,
+		},
+		{
+			name:      "unsubscribed event",
+			eventType: entity.WebhookEventApplicationDeleted,
+			expected:  false,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, webhook.Subscribed(test.eventType))
+		})
+	}
+}
+
+func TestWebhookSign(t *testing.T) {
+	webhook := entity.Webhook{
+		Secret: "secret",
+	}
+	payload := []byte(`{"event":"key.rotated"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), webhook.Sign(payload))
+	assert.NotEqual(t, webhook.Sign(payload), entity.Webhook{Secret: "other"}.Sign(payload))
+}
+
+func TestWebhookDeliveryPayload(t *testing.T) {
+	event := entity.NewWebhookEvent(entity.WebhookEventConfigurationChanged, "app-1")
+	event.Revision = "rev-2"
+	event.PreviousRevision = "rev-1"
+	event.Changes = json.RawMessage(`[{"field":"a","value":1,"deleted":false}]`)
+
+	delivery := entity.NewWebhookDelivery(entity.Webhook{
+		Id:            "webhook-1",
+		ApplicationId: "app-1",
+	}, event, time.Minute)
+
+	payload, err := delivery.Payload()
+	assert.NoError(t, err)
+
+	var actual map[string]any
+	assert.NoError(t, json.Unmarshal(payload, &actual))
+	assert.Equal(t, delivery.Id, actual["id"])
+	assert.Equal(t, "configuration.changed", actual["event"])
+	assert.Equal(t, "app-1", actual["applicationId"])
+	assert.Equal(t, "rev-2", actual["revision"])
+	assert.Equal(t, "rev-1", actual["previousRevision"])
+	assert.Len(t, actual["changes"], 1)
+	assert.Equal(t, entity.WebhookDeliveryPending, delivery.Status)
+}
+
+func TestWebhookDeliveryDue(t *testing.T) {
+	delivery := entity.NewWebhookDelivery(entity.Webhook{Id: "webhook-1"}, entity.WebhookEvent{}, time.Minute)
+	delivery.NextAttemptAt = delivery.CreatedAt.Add(10 * time.Second)
+
+	testCases := []struct {
+		name  string
+		at    time.Time
+		due   bool
+		stale bool
+	}{
+		{
+			name: "before the next attempt",
+			at:   delivery.CreatedAt,
+		},
+		{
+			name: "at the next attempt",
+			at:   delivery.NextAttemptAt,
+			due:  true,
+		},
+		{
+			name:  "after the retries",
+			at:    delivery.CreatedAt.Add(2 * time.Minute),
+			due:   true,
+			stale: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.due, delivery.Due(test.at))
+			assert.Equal(t, test.stale, delivery.Stale(test.at))
+		})
+	}
+
+	delivery.Status = entity.WebhookDeliverySucceeded
+	assert.False(t, delivery.Due(delivery.NextAttemptAt))
+	assert.False(t, delivery.Stale(delivery.CreatedAt.Add(2*time.Minute)))
+}
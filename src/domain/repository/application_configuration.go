@@ -17,3 +17,15 @@ type RepositoryApplicationConfigurationWriter interface {
 type RepositoryApplicationConfigurationReader interface {
 	FindClientConfiguration(ctx context.Context, filter entity.FilterConfiguration) (entity.Configurations, error)
 }
+
+//counterfeiter:generate . RepositoryApplicationConfigurationOverrideWriter
+type RepositoryApplicationConfigurationOverrideWriter interface {
+	CreateConfigurationOverride(ctx context.Context, data entity.ConfigurationOverride) error
+	DeleteConfigurationOverride(ctx context.Context, filter entity.FilterConfigurationOverride) error
+}
+
+//counterfeiter:generate . RepositoryApplicationConfigurationOverrideReader
+type RepositoryApplicationConfigurationOverrideReader interface {
+	FindConfigurationOverride(ctx context.Context, filter entity.FilterConfigurationOverride) (entity.ConfigurationOverride, bool, error)
+	FindConfigurationOverrides(ctx context.Context, filter entity.FilterConfigurationOverride) (entity.ConfigurationOverrides, error)
+}
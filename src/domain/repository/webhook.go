@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+//counterfeiter:generate . RepositoryWebhookWriter
+type RepositoryWebhookWriter interface {
+	CreateWebhook(ctx context.Context, data entity.Webhook) error
+	DeleteWebhook(ctx context.Context, filter entity.FilterWebhook) error
+}
+
+//counterfeiter:generate . RepositoryWebhookReader
+type RepositoryWebhookReader interface {
+	FindWebhook(ctx context.Context, filter entity.FilterWebhook) (entity.Webhook, bool, error)
+	FindWebhooks(ctx context.Context, filter entity.FilterWebhook) (entity.Webhooks, error)
+}
+
+//counterfeiter:generate . RepositoryWebhookDeliveryWriter
+type RepositoryWebhookDeliveryWriter interface {
+	CreateWebhookDelivery(ctx context.Context, data entity.WebhookDelivery) error
+	UpdateWebhookDelivery(ctx context.Context, data entity.WebhookDelivery) error
+}
+
+//counterfeiter:generate . RepositoryWebhookDeliveryReader
+type RepositoryWebhookDeliveryReader interface {
+	FindWebhookDelivery(ctx context.Context, filter entity.FilterWebhookDelivery) (entity.WebhookDelivery, bool, error)
+	FindWebhookDeliveries(ctx context.Context, filter entity.FilterWebhookDelivery) (entity.WebhookDeliveries, error)
+}
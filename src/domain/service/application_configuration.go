@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
 )
 
 type ApplicationConfigurationServicer interface {
@@ -13,5 +14,11 @@ type ApplicationConfigurationServicer interface {
 	UpdateConfiguration(ctx context.Context, req dto.RequestUpdateConfiguration) error
 	UpsertConfiguration(ctx context.Context, req dto.RequestSetConfiguration) error
 	DeleteConfiguration(ctx context.Context, req dto.RequestDeleteConfiguration) error
+	WatchConfiguration(ctx context.Context, req dto.RequestWatchConfiguration) (dto.ResponseWatchConfiguration, error)
 	DistributeConfiguration(ctx context.Context, clientKey string) error
+	CreateConfigurationOverride(ctx context.Context, req dto.RequestCreateConfigurationOverride) (dto.ResponseConfigurationOverride, error)
+	FindConfigurationOverrides(ctx context.Context, req dto.RequestFindConfigurationOverrides) (dto.ResponseConfigurationOverrides, error)
+	DeleteConfigurationOverride(ctx context.Context, req dto.RequestDeleteConfigurationOverride) error
+	// InternalFindConfigurationOverrides is used by the distribution to target the clients
+	InternalFindConfigurationOverrides(ctx context.Context, clientKey string) (entity.ConfigurationOverrides, error)
 }
@@ -4,9 +4,15 @@ import (
 	"context"
 
 	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
 )
 
+type InternalApplicationKeyServicer interface {
+	InternalFindApplicationKey(ctx context.Context, request dto.RequestInternalFindApplicationKey) (entity.ApplicationKey, error)
+}
+
 type ApplicationKeyServicer interface {
+	InternalApplicationKeyServicer
 	IsExistsApplicationKey(ctx context.Context, request dto.RequestFindApplicationKey) (bool, error)
 	FindApplicationKey(ctx context.Context, request dto.RequestFindApplicationKey) (dto.ResponseFindApplicationKey, error)
 	GenerateOrUpdateApplicationKey(ctx context.Context, request dto.RequestCreateApplicationKey) (dto.ResponseCreateApplicationKey, error)
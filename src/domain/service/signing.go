@@ -0,0 +1,16 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/application/signing/dto"
+)
+
+type SigningServicer interface {
+	// SignConfiguration signs the snapshot of the configuration of the client key
+	SignConfiguration(clientKey, revision string, data []byte) (signature.Signature, error)
+	// SignDelta signs the changes from the previous revision to the revision
+	SignDelta(clientKey, revision, previousRevision string, changes []signature.Change) (signature.Signature, error)
+	FindVerificationKeys(ctx context.Context) (dto.ResponseVerificationKeys, error)
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/src/application/webhook/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+type InternalWebhookServicer interface {
+	// DispatchWebhookEvent records the deliveries of the event to the webhooks of the application
+	DispatchWebhookEvent(ctx context.Context, event entity.WebhookEvent) error
+	// RunWebhookDeliveries attempts the pending deliveries until the context is done
+	RunWebhookDeliveries(ctx context.Context)
+}
+
+type WebhookServicer interface {
+	InternalWebhookServicer
+	CreateWebhook(ctx context.Context, request dto.RequestCreateWebhook) (dto.ResponseWebhook, error)
+	FindWebhooks(ctx context.Context, request dto.RequestFindWebhooks) (dto.ResponseWebhooks, error)
+	DeleteWebhook(ctx context.Context, request dto.RequestDeleteWebhook) error
+	FindWebhookDeliveries(ctx context.Context, request dto.RequestFindWebhookDeliveries) (dto.ResponseWebhookDeliveries, error)
+	RedeliverWebhookDelivery(ctx context.Context, request dto.RequestRedeliverWebhookDelivery) error
+}
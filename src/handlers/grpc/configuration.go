@@ -0,0 +1,183 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+
+	comav1 "github.com/nurcahyaari/coma/pkg/proto/coma/v1"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *GrpcHandler) GetConfiguration(ctx context.Context, req *comav1.GetConfigurationRequest) (*comav1.GetConfigurationResponse, error) {
+	clientKey := clientKeyFromContext(ctx)
+
+	resp, err := h.configurationSvc.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: clientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[Grpc.GetConfiguration] error get configuration")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	snapshot, err := h.snapshot(resp.ClientKey, resp.Revision(), resp.Data)
+	if err != nil {
+		log.Error().Err(err).Msg("[Grpc.GetConfiguration] error sign configuration")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &comav1.GetConfigurationResponse{
+		Snapshot: snapshot,
+	}, nil
+}
+
+// snapshot signs the configuration of the client key on the revision
+func (h *GrpcHandler) snapshot(clientKey, revision string, data []byte) (*comav1.Snapshot, error) {
+	signature, err := h.signingSvc.SignConfiguration(clientKey, revision, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comav1.Snapshot{
+		ClientKey: clientKey,
+		Revision:  revision,
+		Data:      data,
+		Signature: &comav1.Signature{
+			KeyId:    signature.KeyId,
+			Value:    signature.Value,
+			IssuedAt: signature.IssuedAt,
+		},
+	}, nil
+}
+
+// Watch sends the snapshot of the configuration when the request revision is stale,
+// then it sends the delta on every distribution of the configuration
+func (h *GrpcHandler) Watch(req *comav1.WatchRequest, stream comav1.ConfigurationService_WatchServer) error {
+	var (
+		clientKey   = clientKeyFromContext(stream.Context())
+		revision    = req.GetRevision()
+		previous    json.RawMessage
+		hasSnapshot bool
+	)
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-h.close:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		resp, err := h.configurationSvc.WatchConfiguration(ctx, dto.RequestWatchConfiguration{
+			XClientKey: clientKey,
+			Revision:   revision,
+			Timeout:    h.config.Watch.MaxTimeout,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("[Grpc.Watch] error watch configuration")
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if !resp.Modified {
+			continue
+		}
+
+		event, err := h.watchEvent(resp, revision, previous, hasSnapshot)
+		if err != nil {
+			log.Error().Err(err).Msg("[Grpc.Watch] error build the event")
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err := stream.Send(event); err != nil {
+			log.Warn().Err(err).Msg("[Grpc.Watch] error send the event")
+			return err
+		}
+
+		hasSnapshot = true
+		previous = resp.Data
+		revision = resp.Revision
+	}
+}
+
+func (h *GrpcHandler) watchEvent(resp dto.ResponseWatchConfiguration, previousRevision string, previous json.RawMessage, hasSnapshot bool) (*comav1.WatchResponse, error) {
+	if !hasSnapshot {
+		snapshot, err := h.snapshot(resp.ClientKey, resp.Revision, resp.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		return &comav1.WatchResponse{
+			Event: &comav1.WatchResponse_Snapshot{
+				Snapshot: snapshot,
+			},
+		}, nil
+	}
+
+	changes, err := dto.NewConfigurationChanges(previous, resp.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &comav1.Delta{
+		ClientKey:        resp.ClientKey,
+		Revision:         resp.Revision,
+		PreviousRevision: previousRevision,
+	}
+	signedChanges := make([]signature.Change, 0, len(changes))
+	for _, change := range changes {
+		delta.Changes = append(delta.Changes, &comav1.FieldChange{
+			Field:   change.Field,
+			Value:   change.Value,
+			Deleted: change.Deleted,
+		})
+		signedChanges = append(signedChanges, signature.Change{
+			Field:   change.Field,
+			Value:   change.Value,
+			Deleted: change.Deleted,
+		})
+	}
+
+	deltaSignature, err := h.signingSvc.SignDelta(resp.ClientKey, resp.Revision, previousRevision, signedChanges)
+	if err != nil {
+		return nil, err
+	}
+	delta.Signature = &comav1.Signature{
+		KeyId:    deltaSignature.KeyId,
+		Value:    deltaSignature.Value,
+		IssuedAt: deltaSignature.IssuedAt,
+	}
+
+	return &comav1.WatchResponse{
+		Event: &comav1.WatchResponse_Delta{
+			Delta: delta,
+		},
+	}, nil
+}
+
+func (h *GrpcHandler) Ack(ctx context.Context, req *comav1.AckRequest) (*comav1.AckResponse, error) {
+	clientKey := clientKeyFromContext(ctx)
+
+	resp, err := h.configurationSvc.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: clientKey,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[Grpc.Ack] error get configuration")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &comav1.AckResponse{
+		CurrentRevision: resp.Revision(),
+		InSync:          resp.Revision() == req.GetRevision(),
+	}, nil
+}
@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"sync"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/container"
+	comav1 "github.com/nurcahyaari/coma/pkg/proto/coma/v1"
+	"github.com/nurcahyaari/coma/src/domain/service"
+)
+
+type GrpcHandler struct {
+	comav1.UnimplementedConfigurationServiceServer
+	config            *config.Config
+	configurationSvc  service.ApplicationConfigurationServicer
+	applicationKeySvc service.ApplicationKeyServicer
+	signingSvc        service.SigningServicer
+	close             chan bool
+	closeOnce         sync.Once
+}
+
+func NewGrpcHandler(config *config.Config, c container.Service) *GrpcHandler {
+	grpcHandler := &GrpcHandler{
+		config:            config,
+		configurationSvc:  c.ApplicationConfigurationServicer,
+		applicationKeySvc: c.ApplicationKeyServicer,
+		signingSvc:        c.SigningServicer,
+		close:             make(chan bool),
+	}
+	return grpcHandler
+}
+
+// Close ends all the running watch streams
+func (h *GrpcHandler) Close() {
+	h.closeOnce.Do(func() {
+		close(h.close)
+	})
+}
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type clientKeyContext struct{}
+
+func clientKeyFromContext(ctx context.Context) string {
+	clientKey, _ := ctx.Value(clientKeyContext{}).(string)
+	return clientKey
+}
+
+func (h *GrpcHandler) authorizeApplicationKey(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 || authorization[0] == "" {
+		return ctx, status.Error(codes.Unauthenticated, "err: unauthorized")
+	}
+
+	exist, _ := h.applicationKeySvc.IsExistsApplicationKey(ctx, dto.RequestFindApplicationKey{
+		Key: authorization[0],
+	})
+	if !exist {
+		return ctx, status.Error(codes.Unauthenticated, "err: unauthorized")
+	}
+
+	return context.WithValue(ctx, clientKeyContext{}, authorization[0]), nil
+}
+
+func (h *GrpcHandler) UnaryInterceptorApplicationKey(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := h.authorizeApplicationKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+type authorizedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authorizedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (h *GrpcHandler) StreamInterceptorApplicationKey(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := h.authorizeApplicationKey(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, authorizedServerStream{
+		ServerStream: ss,
+		ctx:          ctx,
+	})
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/nurcahyaari/coma/internal/protocols/http/response"
@@ -54,6 +55,63 @@ func (h *HttpHandle) GetConfiguration(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// WatchConfiguration wait until the config is changed
+// @Summary long polling the config
+// @Description Returns the config immediately when the If-None-Match revision is stale,
+// @Description otherwise it blocks until the config is distributed or the wait time is over and returns 304
+// @Param x-clientkey header string true "<Client Key>"
+// @Param If-None-Match header string false "<Revision>"
+// @Param wait query string false "<Wait Duration>" example(30s)
+// @Tags Config
+// @Produce json
+// @Success 200 {object} applicationdto.ResponseWatchConfiguration
+// @Success 304
+// @Router /v1/configuration/watch [GET]
+func (h *HttpHandle) WatchConfiguration(w http.ResponseWriter, r *http.Request) {
+	request := applicationdto.RequestWatchConfiguration{
+		XClientKey: r.Header.Get("x-clientkey"),
+	}
+	request.SetRevisionFromETag(r.Header.Get("If-None-Match"))
+
+	if wait := r.FormValue("wait"); wait != "" {
+		timeout, err := time.ParseDuration(wait)
+		if err != nil {
+			response.Err[string](w,
+				response.SetErr[string]("err: wait must be a valid duration"),
+				response.SetHttpCode[string](http.StatusBadRequest))
+			return
+		}
+		request.Timeout = timeout
+	}
+
+	resp, err := h.configurationSvc.WatchConfiguration(r.Context(), request)
+	if err != nil {
+		response.Err[string](w,
+			response.SetErr[string](err.Error()))
+		return
+	}
+
+	w.Header().Set("ETag", resp.ETag())
+	if !resp.Modified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	signature, err := h.signingSvc.SignConfiguration(resp.ClientKey, resp.Revision, resp.Data)
+	if err != nil {
+		response.Err[string](w,
+			response.SetErr[string](err.Error()))
+		return
+	}
+	resp.Signature = signature.Value
+	resp.KeyId = signature.KeyId
+	resp.IssuedAt = signature.IssuedAt
+
+	response.Json[applicationdto.ResponseWatchConfiguration](w,
+		response.SetData[applicationdto.ResponseWatchConfiguration](resp),
+		response.SetMessage[applicationdto.ResponseWatchConfiguration]("success"))
+}
+
 // SetConfiguration set new config
 // @Summary set new config
 // @Security comaStandardAuth
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/protocols/http/response"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	applicationdto "github.com/nurcahyaari/coma/src/application/application/dto"
+)
+
+// FindConfigurationOverrides get the overrides of the config
+// @Summary get config overrides
+// @Security comaStandardAuth
+// @Description get the overrides that target the instances or the labels of the client key
+// @Param x-clientkey header string true "<Client Key>"
+// @Tags Config
+// @Produce json
+// @Router /v1/configuration/overrides [GET]
+func (h *HttpHandle) FindConfigurationOverrides(w http.ResponseWriter, r *http.Request) {
+	request := applicationdto.RequestFindConfigurationOverrides{
+		XClientKey: r.Header.Get("x-clientkey"),
+	}
+
+	resp, err := h.configurationSvc.FindConfigurationOverrides(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[applicationdto.ResponseConfigurationOverrides](w,
+		response.SetMessage[applicationdto.ResponseConfigurationOverrides]("success"),
+		response.SetData[applicationdto.ResponseConfigurationOverrides](resp))
+}
+
+// CreateConfigurationOverride create new override of the config
+// @Summary create config override
+// @Security comaStandardAuth
+// @Description override the fields of the config for an instance id or the clients with the labels, e.g. a canary. The instance override wins over the label override
+// @Param x-clientkey header string true "<Client Key>"
+// @Param RequestCreateConfigurationOverride body applicationdto.RequestCreateConfigurationOverride true "create new override of config"
+// @Tags Config
+// @Produce json
+// @Router /v1/configuration/overrides [POST]
+func (h *HttpHandle) CreateConfigurationOverride(w http.ResponseWriter, r *http.Request) {
+	request := applicationdto.RequestCreateConfigurationOverride{}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.Err[any](w,
+			response.SetMessage[any](err.Error()))
+		return
+	}
+	request.XClientKey = r.Header.Get("x-clientkey")
+
+	resp, err := h.configurationSvc.CreateConfigurationOverride(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[applicationdto.ResponseConfigurationOverride](w,
+		response.SetMessage[applicationdto.ResponseConfigurationOverride]("success"),
+		response.SetData[applicationdto.ResponseConfigurationOverride](resp))
+}
+
+// DeleteConfigurationOverride delete override of the config
+// @Summary delete config override
+// @Security comaStandardAuth
+// @Description delete the override, the targeted clients get back the config without the override
+// @Param x-clientkey header string true "<Client Key>"
+// @Param id path string true "override id"
+// @Tags Config
+// @Produce json
+// @Router /v1/configuration/overrides/{id} [DELETE]
+func (h *HttpHandle) DeleteConfigurationOverride(w http.ResponseWriter, r *http.Request) {
+	request := applicationdto.RequestDeleteConfigurationOverride{
+		XClientKey: r.Header.Get("x-clientkey"),
+		Id:         chi.URLParam(r, "id"),
+	}
+
+	err := h.configurationSvc.DeleteConfigurationOverride(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[string](w,
+		response.SetMessage[string]("success"))
+}
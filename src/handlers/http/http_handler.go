@@ -13,10 +13,14 @@ type HttpHandle struct {
 	applicationKeySvc       service.ApplicationKeyServicer
 	userSvc                 service.UserServicer
 	userApplicationScopeSvc service.UserApplicationScopeServicer
+	webhookSvc              service.WebhookServicer
+	signingSvc              service.SigningServicer
 }
 
 func (h HttpHandle) Router(r *chi.Mux) {
 	r.Route("/v1", func(r chi.Router) {
+		r.Get("/signing-keys", h.FindSigningKeys)
+
 		r.Route("/applications", func(r chi.Router) {
 			r.Use(
 				h.MiddlewareLocalAuthAccessTokenValidate,
@@ -36,17 +40,37 @@ func (h HttpHandle) Router(r *chi.Mux) {
 			r.Post("/", h.CreateOrUpdateApplicationKey)
 		})
 
-		r.Route("/configuration", func(r chi.Router) {
+		r.Route("/webhooks", func(r chi.Router) {
 			r.Use(
 				h.MiddlewareLocalAuthAccessTokenValidate,
-				h.MiddlewareCheckIsClientKeyExists,
-				// h.MiddlewareLocalAuthUserApplicationScope, TODO: uncomment later
 				h.MiddlewareLocalAuthUserScope)
-			r.Get("/", h.GetConfiguration)
-			r.Post("/", h.SetConfiguration)
-			r.Put("/", h.UpdateConfiguration)
-			r.Post("/upsert", h.UpsertConfiguration)
-			r.Delete("/{id}", h.DeleteConfiguration)
+			r.Get("/", h.FindWebhooks)
+			r.Post("/", h.CreateWebhook)
+			r.Delete("/{id}", h.DeleteWebhook)
+			r.Get("/deliveries", h.FindWebhookDeliveries)
+			r.Post("/deliveries/{id}/redeliver", h.RedeliverWebhookDelivery)
+		})
+
+		r.Route("/configuration", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(h.MiddlewareCheckIsClientKeyExists)
+				r.Get("/watch", h.WatchConfiguration)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(
+					h.MiddlewareLocalAuthAccessTokenValidate,
+					h.MiddlewareCheckIsClientKeyExists,
+					// h.MiddlewareLocalAuthUserApplicationScope, TODO: uncomment later
+					h.MiddlewareLocalAuthUserScope)
+				r.Get("/", h.GetConfiguration)
+				r.Post("/", h.SetConfiguration)
+				r.Put("/", h.UpdateConfiguration)
+				r.Post("/upsert", h.UpsertConfiguration)
+				r.Delete("/{id}", h.DeleteConfiguration)
+				r.Get("/overrides", h.FindConfigurationOverrides)
+				r.Post("/overrides", h.CreateConfigurationOverride)
+				r.Delete("/overrides/{id}", h.DeleteConfigurationOverride)
+			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
@@ -87,6 +111,8 @@ func NewHttpHandler(c container.Service) *HttpHandle {
 		applicationKeySvc:       c.ApplicationKeyServicer,
 		userSvc:                 c.UserServicer,
 		userApplicationScopeSvc: c.UserApplicationScopeServicer,
+		webhookSvc:              c.WebhookServicer,
+		signingSvc:              c.SigningServicer,
 	}
 	return httpHandle
 }
@@ -0,0 +1,28 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/nurcahyaari/coma/internal/protocols/http/response"
+	signingdto "github.com/nurcahyaari/coma/src/application/signing/dto"
+)
+
+// FindSigningKeys get the verification keys
+// @Summary get the verification keys
+// @Description get the public keys that verify the signature of the distributed configuration
+// @Tags Signing
+// @Produce json
+// @Success 200 {object} signingdto.ResponseVerificationKeys
+// @Router /v1/signing-keys [GET]
+func (h *HttpHandle) FindSigningKeys(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.signingSvc.FindVerificationKeys(r.Context())
+	if err != nil {
+		response.Err[string](w,
+			response.SetErr[string](err.Error()))
+		return
+	}
+
+	response.Json[signingdto.ResponseVerificationKeys](w,
+		response.SetMessage[signingdto.ResponseVerificationKeys]("success"),
+		response.SetData[signingdto.ResponseVerificationKeys](resp))
+}
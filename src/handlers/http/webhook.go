@@ -0,0 +1,154 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/protocols/http/response"
+	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	webhookdto "github.com/nurcahyaari/coma/src/application/webhook/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+)
+
+// FindWebhooks get webhooks
+// @Summary get webhooks
+// @Security comaStandardAuth
+// @Description get the registered webhooks
+// @Param applicationId query string false "<Application Id>"
+// @Tags Webhooks
+// @Produce json
+// @Router /v1/webhooks [GET]
+func (h *HttpHandle) FindWebhooks(w http.ResponseWriter, r *http.Request) {
+	request := webhookdto.RequestFindWebhooks{
+		ApplicationId: r.FormValue("applicationId"),
+	}
+
+	resp, err := h.webhookSvc.FindWebhooks(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[webhookdto.ResponseWebhooks](w,
+		response.SetMessage[webhookdto.ResponseWebhooks]("success"),
+		response.SetData[webhookdto.ResponseWebhooks](resp))
+}
+
+// CreateWebhook register new webhook
+// @Summary register new webhook
+// @Security comaStandardAuth
+// @Description register new webhook of an application, the secret is generated when it's empty and only shown once
+// @Param RequestCreateWebhook body webhookdto.RequestCreateWebhook true "register new webhook"
+// @Tags Webhooks
+// @Produce json
+// @Router /v1/webhooks [POST]
+func (h *HttpHandle) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	request := webhookdto.RequestCreateWebhook{}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.Err[any](w,
+			response.SetMessage[any](err.Error()))
+		return
+	}
+
+	resp, err := h.webhookSvc.CreateWebhook(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[webhookdto.ResponseWebhook](w,
+		response.SetMessage[webhookdto.ResponseWebhook]("success"),
+		response.SetData[webhookdto.ResponseWebhook](resp))
+}
+
+// DeleteWebhook delete webhook
+// @Summary delete webhook
+// @Security comaStandardAuth
+// @Description delete webhook
+// @Param id path string true "webhook id"
+// @Tags Webhooks
+// @Produce json
+// @Router /v1/webhooks/{id} [DELETE]
+func (h *HttpHandle) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	request := webhookdto.RequestDeleteWebhook{
+		Id: chi.URLParam(r, "id"),
+	}
+
+	err := h.webhookSvc.DeleteWebhook(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[string](w,
+		response.SetMessage[string]("success"))
+}
+
+// FindWebhookDeliveries get webhook delivery log
+// @Summary get webhook deliveries
+// @Security comaStandardAuth
+// @Description get the webhook delivery log from the latest one, use status dead_letter to get the dead-letter list
+// @Param applicationId query string false "<Application Id>"
+// @Param webhookId query string false "<Webhook Id>"
+// @Param status query string false "<pending|succeeded|dead_letter>"
+// @Tags Webhooks
+// @Produce json
+// @Router /v1/webhooks/deliveries [GET]
+func (h *HttpHandle) FindWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	request := webhookdto.RequestFindWebhookDeliveries{
+		ApplicationId: r.FormValue("applicationId"),
+		WebhookId:     r.FormValue("webhookId"),
+		Status:        entity.WebhookDeliveryStatus(r.FormValue("status")),
+	}
+
+	resp, err := h.webhookSvc.FindWebhookDeliveries(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[webhookdto.ResponseWebhookDeliveries](w,
+		response.SetMessage[webhookdto.ResponseWebhookDeliveries]("success"),
+		response.SetData[webhookdto.ResponseWebhookDeliveries](resp))
+}
+
+// RedeliverWebhookDelivery redeliver webhook delivery
+// @Summary redeliver webhook delivery
+// @Security comaStandardAuth
+// @Description send the delivery again in the background, it's commonly used for the dead-lettered delivery
+// @Param id path string true "delivery id"
+// @Tags Webhooks
+// @Produce json
+// @Router /v1/webhooks/deliveries/{id}/redeliver [POST]
+func (h *HttpHandle) RedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	request := webhookdto.RequestRedeliverWebhookDelivery{
+		Id: chi.URLParam(r, "id"),
+	}
+
+	err := h.webhookSvc.RedeliverWebhookDelivery(r.Context(), request)
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		response.Err[any](w,
+			response.SetErr[any](errCustom.ErrorAsObject()),
+			response.SetHttpCode[any](errCustom.ErrCode))
+		return
+	}
+
+	response.Json[string](w,
+		response.SetMessage[string]("success"))
+}
@@ -5,32 +5,54 @@ import (
 	"io"
 	"strings"
 
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
 	"github.com/rs/zerolog/log"
 )
 
-func (h LocalPubsub) ConfigDistributor(id string, r io.Reader) {
+func (h LocalPubsub) ConfigDistributor(ctx context.Context, id string, r io.Reader) error {
 	log.Info().
 		Str("id", id).
 		Msg("[ConfigDistributor] send configuration toward client")
 
 	if r == nil {
-		return
+		return nil
 	}
 	var clientKey string
 	buf := new(strings.Builder)
 	_, err := io.Copy(buf, r)
 	if err != nil {
-		return
+		return err
 	}
 	clientKey = buf.String()
 
-	err = h.configurationSvc.DistributeConfiguration(context.TODO(), clientKey)
+	// the burst of changes is collapsed into one distribution of the latest state,
+	// every message of the burst is retried when the distribution fails. The messages
+	// of the client key are handled in order, the message that is published before
+	// a successful distribution started is already distributed
+	if h.distributor != nil {
+		if message, ok := pubsub.ReadMessage(r); ok && h.distributor.covered(clientKey, message.PublishedAt) {
+			return nil
+		}
+		select {
+		case err := <-h.distributor.trigger(clientKey):
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return h.distribute(ctx, clientKey)
+}
+
+// distribute reads the latest configuration of the client key and sends it to the clients
+func (h LocalPubsub) distribute(ctx context.Context, clientKey string) error {
+	err := h.configurationSvc.DistributeConfiguration(ctx, clientKey)
 	if err != nil {
 		log.Error().Err(err).Msg("[ConfigDistributor] error distribute configuration")
-		return
+		return err
 	}
 
 	log.Info().
-		Str("id", id).
 		Msg("[ConfigDistributor] success send configuration toward client")
+	return nil
 }
@@ -0,0 +1,151 @@
+package localpubsub
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/protocols/http/response"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/internal/x/pubsub/database"
+)
+
+// ResponseDeadLetter is the message that exhausts the retries of the consumer
+type ResponseDeadLetter struct {
+	Id           string            `json:"id"`
+	Topic        string            `json:"topic"`
+	SubscriberId string            `json:"subscriberId"`
+	MessageId    string            `json:"messageId"`
+	Key          string            `json:"key,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Message      string            `json:"message"`
+	Error        string            `json:"error"`
+	Attempts     int               `json:"attempts"`
+	FailedAt     time.Time         `json:"failedAt"`
+}
+
+type ResponseDeadLetters []ResponseDeadLetter
+
+func NewResponseDeadLetters(deadLetters database.DeadLetters) ResponseDeadLetters {
+	responses := make(ResponseDeadLetters, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		responses = append(responses, ResponseDeadLetter{
+			Id:           deadLetter.Id,
+			Topic:        deadLetter.Topic,
+			SubscriberId: deadLetter.SubscriberId,
+			MessageId:    deadLetter.MessageId,
+			Key:          deadLetter.Key,
+			Headers:      deadLetter.Headers,
+			Message:      string(deadLetter.Message),
+			Error:        deadLetter.Error,
+			Attempts:     deadLetter.Attempts,
+			FailedAt:     deadLetter.FailedAt,
+		})
+	}
+	return responses
+}
+
+// ResponseDeadLetterCount is the number of the replayed or purged dead letters
+type ResponseDeadLetterCount struct {
+	Topic string `json:"topic"`
+	Count int    `json:"count"`
+}
+
+// DeadLetterRouter registers the admin routes of the dead letters,
+// the caller is responsible to protect the routes
+func (h LocalPubsub) DeadLetterRouter(r chi.Router) {
+	r.Get("/", h.FindDeadLetters)
+	r.Post("/replay", h.ReplayDeadLetters)
+	r.Delete("/", h.PurgeDeadLetters)
+}
+
+// FindDeadLetters get the dead letters
+// @Summary get dead letters
+// @Security comaStandardAuth
+// @Description get the messages that exhaust the retries of the consumer, the oldest first
+// @Param topic query string false "<Topic>, every topic when it's empty"
+// @Tags Pubsub
+// @Produce json
+// @Router /v1/pubsub/dead-letters [GET]
+func (h LocalPubsub) FindDeadLetters(w http.ResponseWriter, r *http.Request) {
+	topics := h.pubSub.Topics()
+	if topic := r.URL.Query().Get("topic"); topic != "" {
+		topics = []string{topic}
+	}
+
+	deadLetters := database.DeadLetters{}
+	for _, topic := range topics {
+		topicDeadLetters, err := h.pubSub.DeadLetters(topic)
+		if err != nil {
+			pubsubErr(w, err)
+			return
+		}
+		deadLetters = append(deadLetters, topicDeadLetters...)
+	}
+
+	response.Json[ResponseDeadLetters](w,
+		response.SetMessage[ResponseDeadLetters]("success"),
+		response.SetData[ResponseDeadLetters](NewResponseDeadLetters(deadLetters)))
+}
+
+// ReplayDeadLetters dispatch the dead letters again
+// @Summary replay dead letters
+// @Security comaStandardAuth
+// @Description dispatch the dead letters of the topic again in the failed order, the replayed dead letters are removed
+// @Param topic query string true "<Topic>"
+// @Param id query []string false "<Dead Letter Id>, every dead letter of the topic when it's empty" collectionFormat(multi)
+// @Tags Pubsub
+// @Produce json
+// @Router /v1/pubsub/dead-letters/replay [POST]
+func (h LocalPubsub) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	topic := r.URL.Query().Get("topic")
+	count, err := h.pubSub.ReplayDeadLetters(topic, r.URL.Query()["id"]...)
+	if err != nil {
+		pubsubErr(w, err)
+		return
+	}
+
+	response.Json[ResponseDeadLetterCount](w,
+		response.SetMessage[ResponseDeadLetterCount]("success"),
+		response.SetData[ResponseDeadLetterCount](ResponseDeadLetterCount{
+			Topic: topic,
+			Count: count,
+		}))
+}
+
+// PurgeDeadLetters remove the dead letters
+// @Summary purge dead letters
+// @Security comaStandardAuth
+// @Description remove the dead letters of the topic
+// @Param topic query string true "<Topic>"
+// @Param id query []string false "<Dead Letter Id>, every dead letter of the topic when it's empty" collectionFormat(multi)
+// @Tags Pubsub
+// @Produce json
+// @Router /v1/pubsub/dead-letters [DELETE]
+func (h LocalPubsub) PurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	topic := r.URL.Query().Get("topic")
+	count, err := h.pubSub.PurgeDeadLetters(topic, r.URL.Query()["id"]...)
+	if err != nil {
+		pubsubErr(w, err)
+		return
+	}
+
+	response.Json[ResponseDeadLetterCount](w,
+		response.SetMessage[ResponseDeadLetterCount]("success"),
+		response.SetData[ResponseDeadLetterCount](ResponseDeadLetterCount{
+			Topic: topic,
+			Count: count,
+		}))
+}
+
+func pubsubErr(w http.ResponseWriter, err error) {
+	httpCode := http.StatusInternalServerError
+	if errors.Is(err, pubsub.ErrTopicIsNotExists) || errors.Is(err, pubsub.ErrDeadLetterNotFound) {
+		httpCode = http.StatusNotFound
+	}
+
+	response.Err[string](w,
+		response.SetErr[string](err.Error()),
+		response.SetHttpCode[string](httpCode))
+}
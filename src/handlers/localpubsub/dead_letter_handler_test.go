@@ -0,0 +1,121 @@
+package localpubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func request[T any](t *testing.T, method, url string) (int, T) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		Data T `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body.Data
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	var healthy atomic.Bool
+	received := make(chan string, 2)
+
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("topic", pubsub.PubsubSetMaxBufferCapacity(5))
+	ps.ConsumerRegister("topic", func(ctx context.Context, id string, r io.Reader) error {
+		if !healthy.Load() {
+			return pubsub.Permanent(errors.New("err: consumer"))
+		}
+		data, _ := io.ReadAll(r)
+		received <- string(data)
+		return nil
+	})
+	require.NoError(t, ps.Listen())
+
+	router := chi.NewRouter()
+	router.Route("/v1/pubsub/dead-letters", LocalPubsub{pubSub: ps}.DeadLetterRouter)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	var deadLetters ResponseDeadLetters
+	for i, message := range []string{"first", "second"} {
+		require.NoError(t, ps.Publish("topic", pubsub.SendString(message)))
+		require.Eventually(t, func() bool {
+			_, deadLetters = request[ResponseDeadLetters](t, http.MethodGet, server.URL+"/v1/pubsub/dead-letters")
+			return len(deadLetters) == i+1
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+	assert.Equal(t, "topic", deadLetters[0].Topic)
+	assert.Equal(t, "first", deadLetters[0].Message)
+	assert.Equal(t, "err: consumer", deadLetters[0].Error)
+
+	testCases := []struct {
+		name     string
+		method   string
+		url      string
+		httpCode int
+		count    int
+	}{
+		{
+			name:     "unknown topic",
+			method:   http.MethodGet,
+			url:      "/v1/pubsub/dead-letters?topic=unknown",
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "unknown dead letter",
+			method:   http.MethodDelete,
+			url:      "/v1/pubsub/dead-letters?topic=topic&id=unknown",
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "purge a dead letter",
+			method:   http.MethodDelete,
+			url:      "/v1/pubsub/dead-letters?topic=topic&id=" + deadLetters[1].Id,
+			httpCode: http.StatusOK,
+			count:    1,
+		},
+		{
+			name:     "replay the topic",
+			method:   http.MethodPost,
+			url:      "/v1/pubsub/dead-letters/replay?topic=topic",
+			httpCode: http.StatusOK,
+			count:    1,
+		},
+	}
+
+	healthy.Store(true)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			httpCode, act := request[ResponseDeadLetterCount](t, tc.method, server.URL+tc.url)
+			assert.Equal(t, tc.httpCode, httpCode)
+			assert.Equal(t, tc.count, act.Count)
+		})
+	}
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "first", message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the replayed message")
+	}
+
+	_, deadLetters = request[ResponseDeadLetters](t, http.MethodGet, server.URL+"/v1/pubsub/dead-letters?topic=topic")
+	assert.Empty(t, deadLetters)
+}
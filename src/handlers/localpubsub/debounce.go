@@ -0,0 +1,159 @@
+package localpubsub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// debouncer collapses the triggers of the same key into one call. The call is made
+// after the key is quiet for the window, but never later than the max delay
+// after the first trigger, so a steady stream of changes is still distributed.
+// Every collapsed trigger receives the result of the call.
+type debouncer struct {
+	window   time.Duration
+	maxDelay time.Duration
+	fn       func(key string) error
+
+	mtx     sync.Mutex
+	pending map[string]*pendingKey
+	// keys are the keys with a running or a waiting call, the key is removed when
+	// it's idle, or after the max delay when its last call succeeded
+	keys map[string]*keyState
+}
+
+type pendingKey struct {
+	timer     *time.Timer
+	deadline  time.Time
+	triggered int
+	waiters   []chan error
+}
+
+type keyState struct {
+	// running serializes the calls of the key, so an older state
+	// is never distributed after a newer one
+	running sync.Mutex
+	// calls are the running and the waiting calls of the key
+	calls int
+	// succeeded is the start of the last successful call of the key
+	succeeded time.Time
+	expiry    *time.Timer
+}
+
+func newDebouncer(window, maxDelay time.Duration, fn func(key string) error) *debouncer {
+	return &debouncer{
+		window:   window,
+		maxDelay: maxDelay,
+		fn:       fn,
+		pending:  make(map[string]*pendingKey),
+		keys:     make(map[string]*keyState),
+	}
+}
+
+// trigger schedules the call of the key, the result of the call is sent to the returned channel
+func (d *debouncer) trigger(key string) <-chan error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	result := make(chan error, 1)
+	now := time.Now()
+	pending, exists := d.pending[key]
+	// the timer that is already fired can't be extended
+	if exists && pending.timer.Stop() {
+		pending.triggered++
+		pending.waiters = append(pending.waiters, result)
+		pending.timer.Reset(d.wait(now, pending.deadline))
+		return result
+	}
+
+	pending = &pendingKey{
+		deadline:  now.Add(d.maxDelay),
+		triggered: 1,
+		waiters:   []chan error{result},
+	}
+	pending.timer = time.AfterFunc(d.wait(now, pending.deadline), func() {
+		d.fire(key, pending)
+	})
+	d.pending[key] = pending
+	return result
+}
+
+func (d *debouncer) wait(now, deadline time.Time) time.Duration {
+	if remaining := deadline.Sub(now); remaining < d.window {
+		return remaining
+	}
+	return d.window
+}
+
+func (d *debouncer) fire(key string, pending *pendingKey) {
+	d.mtx.Lock()
+	if d.pending[key] == pending {
+		delete(d.pending, key)
+	}
+	state, exists := d.keys[key]
+	if !exists {
+		state = &keyState{}
+		d.keys[key] = state
+	}
+	if state.expiry != nil {
+		state.expiry.Stop()
+		state.expiry = nil
+	}
+	state.calls++
+	triggered := pending.triggered
+	waiters := pending.waiters
+	d.mtx.Unlock()
+
+	state.running.Lock()
+	log.Info().
+		Int("coalesced", triggered).
+		Msg("[debouncer] distribute the latest configuration")
+	started := time.Now()
+	err := d.fn(key)
+	state.running.Unlock()
+
+	d.mtx.Lock()
+	state.calls--
+	if err == nil {
+		state.succeeded = started
+	}
+	d.release(key, state)
+	d.mtx.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- err
+	}
+}
+
+// release removes the key when no call is running or waiting. The successful call is kept
+// for the max delay, it covers the messages of the burst that are handled after it
+func (d *debouncer) release(key string, state *keyState) {
+	if state.calls > 0 || d.pending[key] != nil {
+		return
+	}
+	if state.succeeded.IsZero() {
+		delete(d.keys, key)
+		return
+	}
+
+	var expiry *time.Timer
+	expiry = time.AfterFunc(d.maxDelay, func() {
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+		if d.keys[key] == state && state.expiry == expiry {
+			delete(d.keys, key)
+		}
+	})
+	state.expiry = expiry
+}
+
+// covered tells whether the change at the time is already in a successful call of the key,
+// the call that starts after the change reads the state with it
+func (d *debouncer) covered(key string, at time.Time) bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	state, exists := d.keys[key]
+	return exists && at.Before(state.succeeded)
+}
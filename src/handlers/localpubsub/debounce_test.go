@@ -0,0 +1,208 @@
+package localpubsub
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type calls struct {
+	mtx  sync.Mutex
+	keys []string
+	at   []time.Time
+}
+
+func (c *calls) add(key string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.keys = append(c.keys, key)
+	c.at = append(c.at, time.Now())
+	return nil
+}
+
+func (c *calls) get() ([]string, []time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]string{}, c.keys...), append([]time.Time{}, c.at...)
+}
+
+func TestDebouncer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		window   time.Duration
+		maxDelay time.Duration
+		trigger  func(d *debouncer)
+		expected []string
+	}{
+		{
+			name:     "burst is collapsed",
+			window:   50 * time.Millisecond,
+			maxDelay: time.Second,
+			trigger: func(d *debouncer) {
+				for i := 0; i < 50; i++ {
+					d.trigger("a")
+				}
+			},
+			expected: []string{"a"},
+		},
+		{
+			name:     "keys are distributed separately",
+			window:   50 * time.Millisecond,
+			maxDelay: time.Second,
+			trigger: func(d *debouncer) {
+				d.trigger("a")
+				d.trigger("b")
+				d.trigger("a")
+			},
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "quiet key is distributed again",
+			window:   20 * time.Millisecond,
+			maxDelay: time.Second,
+			trigger: func(d *debouncer) {
+				d.trigger("a")
+				time.Sleep(100 * time.Millisecond)
+				d.trigger("a")
+			},
+			expected: []string{"a", "a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &calls{}
+			d := newDebouncer(tc.window, tc.maxDelay, c.add)
+
+			tc.trigger(d)
+
+			assert.Eventually(t, func() bool {
+				keys, _ := c.get()
+				return len(keys) == len(tc.expected)
+			}, time.Second, 10*time.Millisecond)
+
+			// nothing else is called after the window
+			time.Sleep(2 * tc.window)
+			keys, _ := c.get()
+			assert.ElementsMatch(t, tc.expected, keys)
+		})
+	}
+}
+
+func TestDebouncerMaxDelay(t *testing.T) {
+	c := &calls{}
+	d := newDebouncer(50*time.Millisecond, 150*time.Millisecond, c.add)
+
+	// the key is never quiet for the window, the max delay forces the distribution
+	start := time.Now()
+	stop := time.After(400 * time.Millisecond)
+	for running := true; running; {
+		select {
+		case <-stop:
+			running = false
+		default:
+			d.trigger("a")
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	keys, at := c.get()
+	assert.GreaterOrEqual(t, len(keys), 2)
+	assert.Less(t, at[0].Sub(start), 300*time.Millisecond)
+}
+
+func TestDebouncerResult(t *testing.T) {
+	errDistribute := errors.New("err: distribute")
+	d := newDebouncer(20*time.Millisecond, time.Second, func(key string) error {
+		if key == "failing" {
+			return errDistribute
+		}
+		return nil
+	})
+
+	// every collapsed trigger receives the result of the one call
+	results := []<-chan error{d.trigger("failing"), d.trigger("failing"), d.trigger("a")}
+	expected := []error{errDistribute, errDistribute, nil}
+	for i, result := range results {
+		select {
+		case err := <-result:
+			assert.Equal(t, expected[i], err)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the result")
+		}
+	}
+}
+
+func TestDebouncerCovered(t *testing.T) {
+	errDistribute := errors.New("err: distribute")
+	d := newDebouncer(20*time.Millisecond, time.Second, func(key string) error {
+		if key == "failing" {
+			return errDistribute
+		}
+		return nil
+	})
+
+	before := time.Now()
+	for _, key := range []string{"a", "failing"} {
+		select {
+		case <-d.trigger(key):
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the result")
+		}
+	}
+	after := time.Now()
+
+	// the change before the successful call is already distributed
+	assert.True(t, d.covered("a", before))
+	assert.False(t, d.covered("a", after))
+	assert.False(t, d.covered("failing", before))
+	assert.False(t, d.covered("b", before))
+}
+
+func TestDebouncerRelease(t *testing.T) {
+	errDistribute := errors.New("err: distribute")
+	d := newDebouncer(10*time.Millisecond, 100*time.Millisecond, func(key string) error {
+		if key == "failing" {
+			return errDistribute
+		}
+		return nil
+	})
+
+	before := time.Now()
+	for _, key := range []string{"a", "failing"} {
+		select {
+		case <-d.trigger(key):
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the result")
+		}
+	}
+	keys := func() []string {
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+		keys := []string{}
+		for key := range d.keys {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	// the failed key is removed right away, the successful one after the max delay
+	assert.Equal(t, []string{"a"}, keys())
+	assert.True(t, d.covered("a", before))
+	assert.Eventually(t, func() bool {
+		return len(keys()) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, d.covered("a", before))
+
+	// the key is distributed again after it's removed
+	select {
+	case err := <-d.trigger("a"):
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the result")
+	}
+	assert.True(t, d.covered("a", before))
+}
@@ -1,6 +1,8 @@
 package localpubsub
 
 import (
+	"context"
+
 	"github.com/nurcahyaari/coma/config"
 	"github.com/nurcahyaari/coma/container"
 	"github.com/nurcahyaari/coma/internal/x/pubsub"
@@ -11,6 +13,9 @@ type LocalPubsub struct {
 	config           *config.Config
 	pubSub           *pubsub.Pubsub
 	configurationSvc service.ApplicationConfigurationServicer
+	webhookSvc       service.InternalWebhookServicer
+	// distributor is nil when the distribution window is disabled
+	distributor *debouncer
 }
 
 func NewLocalPubsub(config *config.Config, c container.Container) *LocalPubsub {
@@ -18,17 +23,55 @@ func NewLocalPubsub(config *config.Config, c container.Container) *LocalPubsub {
 		config:           config,
 		pubSub:           c.LocalPubsub,
 		configurationSvc: c.ApplicationConfigurationServicer,
+		webhookSvc:       c.InternalWebhookServicer,
+	}
+	if config.Distribution.Window > 0 {
+		// the collapsed distribution is shared by the messages of the burst,
+		// so it isn't bound to the context of one of them
+		localPubsub.distributor = newDebouncer(
+			config.Distribution.Window,
+			config.Distribution.MaxDelay,
+			func(clientKey string) error {
+				return localPubsub.distribute(context.Background(), clientKey)
+			})
 	}
 	return localPubsub
 }
 
 func (h LocalPubsub) Consumer() {
-	h.pubSub.ConsumerRegister(h.config.Pubsub.ConfigDistributor.Consumer.Topic, h.ConfigDistributor)
+	// the handler waits for the debounced distribution, so the messages are processed
+	// asynchronously to let the burst be collapsed
+	h.pubSub.ConsumerRegister(h.config.Pubsub.ConfigDistributor.Consumer.Topic, h.ConfigDistributor,
+		consumerOptions(h.config.Pubsub.ConfigDistributor.Consumer, pubsub.PubsubSetAsyncProcess(true))...)
+	// the deliveries of the event are recorded and retried in the background by the webhook service.
+	// The dispatchers are a consumer group, every event is delivered once
+	h.pubSub.ConsumerRegister(h.config.Pubsub.WebhookDispatcher.Consumer.Topic, h.WebhookDispatcher,
+		consumerOptions(h.config.Pubsub.WebhookDispatcher.Consumer, pubsub.PubsubSetAsyncProcess(true))...)
+}
+
+// consumerOptions are the retry, the timeout and the group options of the consumer, the
+// message that exhausts the retries is moved to the dead letters of the topic
+func consumerOptions(consumer config.ConsumerOptions, opts ...pubsub.SubscriberOption) []pubsub.SubscriberOption {
+	if consumer.MaxElapsedTime > 0 {
+		opts = append(opts, pubsub.PubsubSetMaxElapsedTime(consumer.MaxElapsedTime))
+	}
+	if consumer.RetryWaitTime > 0 {
+		opts = append(opts, pubsub.PubsubSetRetryWaitTime(consumer.RetryWaitTime))
+	}
+	if consumer.Timeout > 0 {
+		opts = append(opts, pubsub.PubsubSetTimeout(consumer.Timeout))
+	}
+	if consumer.Group != "" {
+		opts = append(opts, pubsub.PubsubSetGroup(consumer.Group))
+	}
+	return opts
 }
 
 func (h LocalPubsub) TopicRegistry() {
 	h.pubSub.TopicRegister(h.config.Pubsub.ConfigDistributor.Publisher.Topic,
 		pubsub.PubsubSetMaxBufferCapacity(h.config.Pubsub.ConfigDistributor.Publisher.MaxBufferCapacity))
+	h.pubSub.TopicRegister(h.config.Pubsub.WebhookDispatcher.Publisher.Topic,
+		pubsub.PubsubSetMaxBufferCapacity(h.config.Pubsub.WebhookDispatcher.Publisher.MaxBufferCapacity))
 }
 
 func (h LocalPubsub) Listen() {
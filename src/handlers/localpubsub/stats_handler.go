@@ -0,0 +1,111 @@
+package localpubsub
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/protocols/http/response"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+)
+
+// ResponseTopicStats are the counters of a topic since the start
+type ResponseTopicStats struct {
+	Topic        string `json:"topic"`
+	Published    int64  `json:"published"`
+	Delivered    int64  `json:"delivered"`
+	Failed       int64  `json:"failed"`
+	Retried      int64  `json:"retried"`
+	DeadLettered int64  `json:"deadLettered"`
+	// QueueDepth is the number of the messages that wait for a consumer,
+	// the topic backs up when it grows toward the capacity
+	QueueDepth    int `json:"queueDepth"`
+	QueueCapacity int `json:"queueCapacity"`
+	// Scheduled is the number of the messages that wait for their delivery time
+	Scheduled   int                      `json:"scheduled"`
+	InFlight    int64                    `json:"inFlight"`
+	Subscribers int                      `json:"subscribers"`
+	Latency     ResponseLatencyHistogram `json:"latency"`
+}
+
+// ResponseLatencyHistogram is the latency of the consumer calls in milliseconds,
+// the counts of the buckets are cumulative
+type ResponseLatencyHistogram struct {
+	Buckets []ResponseLatencyBucket `json:"buckets"`
+	Count   int64                   `json:"count"`
+	SumMs   float64                 `json:"sumMs"`
+}
+
+type ResponseLatencyBucket struct {
+	LeMs  float64 `json:"leMs"`
+	Count int64   `json:"count"`
+}
+
+type ResponseTopicsStats []ResponseTopicStats
+
+func NewResponseTopicsStats(stats []pubsub.Stats) ResponseTopicsStats {
+	responses := make(ResponseTopicsStats, 0, len(stats))
+	for _, topicStats := range stats {
+		latency := ResponseLatencyHistogram{
+			Buckets: make([]ResponseLatencyBucket, 0, len(topicStats.Latency.Buckets)),
+			Count:   topicStats.Latency.Count,
+			SumMs:   milliseconds(topicStats.Latency.Sum),
+		}
+		for _, bucket := range topicStats.Latency.Buckets {
+			latency.Buckets = append(latency.Buckets, ResponseLatencyBucket{
+				LeMs:  milliseconds(bucket.UpperBound),
+				Count: bucket.Count,
+			})
+		}
+
+		responses = append(responses, ResponseTopicStats{
+			Topic:         topicStats.Topic,
+			Published:     topicStats.Published,
+			Delivered:     topicStats.Delivered,
+			Failed:        topicStats.Failed,
+			Retried:       topicStats.Retried,
+			DeadLettered:  topicStats.DeadLettered,
+			QueueDepth:    topicStats.QueueDepth,
+			QueueCapacity: topicStats.QueueCapacity,
+			Scheduled:     topicStats.Scheduled,
+			InFlight:      topicStats.InFlight,
+			Subscribers:   topicStats.Subscribers,
+			Latency:       latency,
+		})
+	}
+	return responses
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// StatsRouter registers the admin routes of the pubsub stats,
+// the caller is responsible to protect the routes
+func (h LocalPubsub) StatsRouter(r chi.Router) {
+	r.Get("/", h.FindStats)
+}
+
+// FindStats get the pubsub stats
+// @Summary get pubsub stats
+// @Security comaStandardAuth
+// @Description get the counters, the queue depth and the consumer latency of the topics
+// @Param topic query string false "<Topic>, every topic when it's empty"
+// @Tags Pubsub
+// @Produce json
+// @Router /v1/pubsub/stats [GET]
+func (h LocalPubsub) FindStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.pubSub.AllStats()
+	if topic := r.URL.Query().Get("topic"); topic != "" {
+		topicStats, err := h.pubSub.Stats(topic)
+		if err != nil {
+			pubsubErr(w, err)
+			return
+		}
+		stats = []pubsub.Stats{topicStats}
+	}
+
+	response.Json[ResponseTopicsStats](w,
+		response.SetMessage[ResponseTopicsStats]("success"),
+		response.SetData[ResponseTopicsStats](NewResponseTopicsStats(stats)))
+}
@@ -0,0 +1,76 @@
+package localpubsub
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	ps := pubsub.NewPubsub()
+	ps.TopicRegister("topic", pubsub.PubsubSetMaxBufferCapacity(5))
+	ps.ConsumerRegister("topic", func(ctx context.Context, id string, r io.Reader) error {
+		return nil
+	})
+	require.NoError(t, ps.Listen())
+
+	router := chi.NewRouter()
+	router.Route("/v1/pubsub/stats", LocalPubsub{pubSub: ps}.StatsRouter)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	require.NoError(t, ps.Publish("topic", pubsub.SendString("message")))
+
+	var stats ResponseTopicsStats
+	require.Eventually(t, func() bool {
+		_, stats = request[ResponseTopicsStats](t, http.MethodGet, server.URL+"/v1/pubsub/stats?topic=topic")
+		return len(stats) == 1 && stats[0].Delivered == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "topic", stats[0].Topic)
+	assert.Equal(t, int64(1), stats[0].Published)
+	assert.Equal(t, 5, stats[0].QueueCapacity)
+	assert.Equal(t, 1, stats[0].Subscribers)
+	assert.Equal(t, int64(1), stats[0].Latency.Count)
+	require.Len(t, stats[0].Latency.Buckets, len(pubsub.LatencyBuckets))
+	assert.Equal(t, float64(1), stats[0].Latency.Buckets[0].LeMs)
+
+	testCases := []struct {
+		name     string
+		url      string
+		httpCode int
+		topics   []string
+	}{
+		{
+			name:     "every topic",
+			url:      "/v1/pubsub/stats",
+			httpCode: http.StatusOK,
+			topics:   []string{"topic"},
+		},
+		{
+			name:     "unknown topic",
+			url:      "/v1/pubsub/stats?topic=unknown",
+			httpCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			httpCode, stats := request[ResponseTopicsStats](t, http.MethodGet, server.URL+tc.url)
+			assert.Equal(t, tc.httpCode, httpCode)
+
+			topics := []string{}
+			for _, topicStats := range stats {
+				topics = append(topics, topicStats.Topic)
+			}
+			assert.Equal(t, append([]string{}, tc.topics...), topics)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package localpubsub
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/nurcahyaari/coma/internal/x/pubsub"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/rs/zerolog/log"
+)
+
+func (h LocalPubsub) WebhookDispatcher(ctx context.Context, id string, r io.Reader) error {
+	log.Info().
+		Str("id", id).
+		Msg("[WebhookDispatcher] send event toward webhooks")
+
+	if r == nil {
+		return nil
+	}
+
+	var event entity.WebhookEvent
+	err := json.NewDecoder(r).Decode(&event)
+	if err != nil {
+		log.Error().Err(err).Msg("[WebhookDispatcher] error decode webhook event")
+		// the malformed event never succeeds
+		return pubsub.Permanent(err)
+	}
+
+	err = h.webhookSvc.DispatchWebhookEvent(ctx, event)
+	if err != nil {
+		log.Error().Err(err).Msg("[WebhookDispatcher] error dispatch webhook event")
+		return err
+	}
+
+	log.Info().
+		Str("id", id).
+		Msg("[WebhookDispatcher] success send event toward webhooks")
+	return nil
+}
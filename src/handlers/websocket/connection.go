@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+// newClient reads the client metadata from the handshake request,
+// labels are sent as repeated "label" query with "key:value" format
+func newClient(c *websocket.Conn, isSelfConnection bool, trustedProxies []netip.Prefix) *Client {
+	var (
+		request = c.Request()
+		query   = request.URL.Query()
+		now     = time.Now()
+	)
+
+	return &Client{
+		Id:            uuid.New().String(),
+		Connection:    c,
+		Self:          isSelfConnection,
+		Subscriptions: make(map[string]*Subscription),
+		RemoteAddr:    remoteAddr(request, trustedProxies),
+		SdkName:       query.Get("sdk"),
+		SdkVersion:    query.Get("sdkVersion"),
+		InstanceId:    query.Get("instanceId"),
+		Hostname:      query.Get("hostname"),
+		Labels:        parseLabels(query["label"]),
+		ConnectedAt:   now,
+		LastSeen:      now,
+		Codec:         codec.Default,
+	}
+}
+
+// copy returns the client with its own subscriptions,
+// so it can be read after the lock is released
+func (c *Client) copy() Client {
+	client := *c
+	client.Subscriptions = make(map[string]*Subscription, len(c.Subscriptions))
+	for clientKey, subscription := range c.Subscriptions {
+		s := *subscription
+		client.Subscriptions[clientKey] = &s
+	}
+	return client
+}
+
+// subscription finds the subscription by its id
+func (c *Client) subscription(subscriptionId string) (*Subscription, bool) {
+	for _, subscription := range c.Subscriptions {
+		if subscription.Id == subscriptionId {
+			return subscription, true
+		}
+	}
+	return nil, false
+}
+
+// remoteAddr is the peer of the connection, the X-Forwarded-For header is only read
+// when the peer is a trusted proxy, otherwise any client could spoof its address.
+// The header is walked from the right, the first address that isn't a trusted proxy is the client
+func remoteAddr(r *http.Request, trustedProxies []netip.Prefix) string {
+	if !isTrustedProxy(hostAddr(r.RemoteAddr), trustedProxies) {
+		return r.RemoteAddr
+	}
+
+	forwardedFor := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwardedFor[i])
+		if addr == "" {
+			continue
+		}
+		if !isTrustedProxy(addr, trustedProxies) {
+			return addr
+		}
+	}
+	return r.RemoteAddr
+}
+
+// hostAddr strips the port of the address, the address is returned as is when it has no port
+func hostAddr(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func isTrustedProxy(addr string, trustedProxies []netip.Prefix) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, trustedProxy := range trustedProxies {
+		if trustedProxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies reads the proxies as the CIDRs, a single address is its own prefix.
+// The invalid proxy is skipped, so the header is never trusted by mistake
+func parseTrustedProxies(proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			log.Error().
+				Str("proxy", proxy).
+				Msg("[Websocket] trusted proxy is not an address or a CIDR, it's skipped")
+			continue
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes
+}
+
+func parseLabels(values []string) map[string]string {
+	labels := make(map[string]string)
+	for _, value := range values {
+		key, val, found := strings.Cut(value, ":")
+		if !found || key == "" {
+			continue
+		}
+		labels[key] = val
+	}
+	return labels
+}
+
+// FilterClient lets you filter the connected clients, the argument is "and"
+type FilterClient struct {
+	ClientKey     string
+	ApplicationId string
+	InstanceId    string
+	Hostname      string
+	SdkName       string
+	Labels        map[string]string
+}
+
+func (f FilterClient) Match(c Client) bool {
+	// the internal connection is never listed
+	if c.Self {
+		return false
+	}
+
+	if f.ClientKey != "" {
+		if _, subscribed := c.Subscriptions[f.ClientKey]; !subscribed {
+			return false
+		}
+	}
+
+	if f.ApplicationId != "" && !c.subscribedApplication(f.ApplicationId) {
+		return false
+	}
+
+	if f.InstanceId != "" && c.InstanceId != f.InstanceId {
+		return false
+	}
+
+	if f.Hostname != "" && c.Hostname != f.Hostname {
+		return false
+	}
+
+	if f.SdkName != "" && c.SdkName != f.SdkName {
+		return false
+	}
+
+	for key, value := range f.Labels {
+		if c.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Client) subscribedApplication(applicationId string) bool {
+	for _, subscription := range c.Subscriptions {
+		if subscription.ApplicationId == applicationId {
+			return true
+		}
+	}
+	return false
+}
+
+type ResponseConnection struct {
+	Id            string                           `json:"id"`
+	Subscriptions []ResponseConnectionSubscription `json:"subscriptions"`
+	RemoteAddr    string                           `json:"remoteAddr"`
+	SdkName       string                           `json:"sdkName"`
+	SdkVersion    string                           `json:"sdkVersion"`
+	InstanceId    string                           `json:"instanceId"`
+	Hostname      string                           `json:"hostname"`
+	Labels        map[string]string                `json:"labels"`
+	Encoding      string                           `json:"encoding"`
+	ConnectedAt   time.Time                        `json:"connectedAt"`
+	LastSeen      time.Time                        `json:"lastSeen"`
+}
+
+func NewResponseConnection(c Client) ResponseConnection {
+	return ResponseConnection{
+		Id:            c.Id,
+		Subscriptions: NewResponseConnectionSubscriptions(c.Subscriptions),
+		RemoteAddr:    c.RemoteAddr,
+		SdkName:       c.SdkName,
+		SdkVersion:    c.SdkVersion,
+		InstanceId:    c.InstanceId,
+		Hostname:      c.Hostname,
+		Labels:        c.Labels,
+		Encoding:      c.Codec.String(),
+		ConnectedAt:   c.ConnectedAt,
+		LastSeen:      c.LastSeen,
+	}
+}
+
+type ResponseConnections []ResponseConnection
+
+func NewResponseConnections(clients []Client) ResponseConnections {
+	responses := make(ResponseConnections, 0, len(clients))
+	for _, c := range clients {
+		responses = append(responses, NewResponseConnection(c))
+	}
+	return responses
+}
@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/internal/protocols/http/response"
+)
+
+// ConnectionRouter registers the admin routes of the connected clients,
+// the caller is responsible to protect the routes
+func (h WebsocketHandler) ConnectionRouter(r chi.Router) {
+	r.Get("/", h.FindConnections)
+	r.Get("/metrics", h.FindConnectionMetrics)
+	r.Get("/revisions", h.FindConnectionRevisions)
+	r.Get("/drift", h.FindConnectionDrift)
+	r.Delete("/{id}", h.DisconnectConnection)
+	r.Post("/{id}/resync", h.ResyncConnection)
+}
+
+// FindConnections get connected clients
+// @Summary get connected clients
+// @Security comaStandardAuth
+// @Description get connected websocket clients
+// @Param clientKey query string false "<Client Key>"
+// @Param applicationId query string false "<Application Id>"
+// @Param instanceId query string false "<Instance Id>"
+// @Param hostname query string false "<Hostname>"
+// @Param sdk query string false "<SDK Name>"
+// @Param label query []string false "<Label with key:value format>" collectionFormat(multi)
+// @Tags Connection
+// @Produce json
+// @Router /v1/connections [GET]
+func (h WebsocketHandler) FindConnections(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	clients := h.connection.findClients(filterClient(r))
+
+	response.Json[ResponseConnections](w,
+		response.SetMessage[ResponseConnections]("success"),
+		response.SetData[ResponseConnections](NewResponseConnections(clients)))
+}
+
+// FindConnectionRevisions get the revision of the connected clients
+// @Summary get revisions of connected clients
+// @Security comaStandardAuth
+// @Description get which instances run which revision, the instances are grouped by the client key and the revision
+// @Param clientKey query string false "<Client Key>"
+// @Param applicationId query string false "<Application Id>"
+// @Param instanceId query string false "<Instance Id>"
+// @Param hostname query string false "<Hostname>"
+// @Param sdk query string false "<SDK Name>"
+// @Param label query []string false "<Label with key:value format>" collectionFormat(multi)
+// @Tags Connection
+// @Produce json
+// @Router /v1/connections/revisions [GET]
+func (h WebsocketHandler) FindConnectionRevisions(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	filter := filterClient(r)
+	clients := h.connection.findClients(filter)
+
+	response.Json[ResponseConnectionRevisions](w,
+		response.SetMessage[ResponseConnectionRevisions]("success"),
+		response.SetData[ResponseConnectionRevisions](NewResponseConnectionRevisions(clients, filter)))
+}
+
+// FindConnectionDrift get the drift of the connected clients
+// @Summary get drift of connected clients
+// @Security comaStandardAuth
+// @Description compare the revision that is applied by the clients with the revision that is sent to them, the instances are grouped by the client key
+// @Param clientKey query string false "<Client Key>"
+// @Param applicationId query string false "<Application Id>"
+// @Param status query string false "<in_sync|pending|stuck|drifted|unreported>"
+// @Param instanceId query string false "<Instance Id>"
+// @Param hostname query string false "<Hostname>"
+// @Param sdk query string false "<SDK Name>"
+// @Param label query []string false "<Label with key:value format>" collectionFormat(multi)
+// @Tags Connection
+// @Produce json
+// @Router /v1/connections/drift [GET]
+func (h WebsocketHandler) FindConnectionDrift(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	response.Json[ResponseConnectionDrifts](w,
+		response.SetMessage[ResponseConnectionDrifts]("success"),
+		response.SetData[ResponseConnectionDrifts](h.connection.drift(filterClient(r), DriftStatus(r.FormValue("status")))))
+}
+
+// FindConnectionMetrics get the connection churn
+// @Summary get connection metrics
+// @Security comaStandardAuth
+// @Description get the number of active, connected and disconnected websocket clients
+// @Tags Connection
+// @Produce json
+// @Router /v1/connections/metrics [GET]
+func (h WebsocketHandler) FindConnectionMetrics(w http.ResponseWriter, r *http.Request) {
+	response.Json[ResponseConnectionMetrics](w,
+		response.SetMessage[ResponseConnectionMetrics]("success"),
+		response.SetData[ResponseConnectionMetrics](h.connection.connectionMetrics()))
+}
+
+// DisconnectConnection forcibly disconnect a client
+// @Summary disconnect a client
+// @Security comaStandardAuth
+// @Description forcibly close the websocket connection of a client
+// @Param id path string true "connection id"
+// @Tags Connection
+// @Produce json
+// @Router /v1/connections/{id} [DELETE]
+func (h WebsocketHandler) DisconnectConnection(w http.ResponseWriter, r *http.Request) {
+	err := h.connection.disconnect(chi.URLParam(r, "id"))
+	if err != nil {
+		connectionErr(w, err)
+		return
+	}
+
+	response.Json[string](w,
+		response.SetMessage[string]("success"))
+}
+
+// ResyncConnection send the current config to a client
+// @Summary re-sync a client
+// @Security comaStandardAuth
+// @Description send the current configuration to the connected client
+// @Param id path string true "connection id"
+// @Tags Connection
+// @Produce json
+// @Router /v1/connections/{id}/resync [POST]
+func (h WebsocketHandler) ResyncConnection(w http.ResponseWriter, r *http.Request) {
+	err := h.connection.resync(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		connectionErr(w, err)
+		return
+	}
+
+	response.Json[string](w,
+		response.SetMessage[string]("success"))
+}
+
+// filterClient reads the filter from the parsed form
+func filterClient(r *http.Request) FilterClient {
+	return FilterClient{
+		ClientKey:     r.FormValue("clientKey"),
+		ApplicationId: r.FormValue("applicationId"),
+		InstanceId:    r.FormValue("instanceId"),
+		Hostname:      r.FormValue("hostname"),
+		SdkName:       r.FormValue("sdk"),
+		Labels:        parseLabels(r.Form["label"]),
+	}
+}
+
+func connectionErr(w http.ResponseWriter, err error) {
+	httpCode := http.StatusInternalServerError
+	if errors.Is(err, ErrClientIsNotExists) {
+		httpCode = http.StatusNotFound
+	}
+
+	response.Err[string](w,
+		response.SetErr[string](err.Error()),
+		response.SetHttpCode[string](httpCode))
+}
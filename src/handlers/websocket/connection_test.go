@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteAddr(t *testing.T) {
+	trustedProxies := parseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16", "invalid"})
+
+	testCases := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedAddr string
+	}{
+		{
+			name:         "no header",
+			remoteAddr:   "203.0.113.7:40000",
+			expectedAddr: "203.0.113.7:40000",
+		},
+		{
+			name:         "header of an untrusted peer is ignored",
+			remoteAddr:   "203.0.113.7:40000",
+			forwardedFor: "198.51.100.1",
+			expectedAddr: "203.0.113.7:40000",
+		},
+		{
+			name:         "header of a trusted proxy",
+			remoteAddr:   "10.0.0.1:40000",
+			forwardedFor: "198.51.100.1",
+			expectedAddr: "198.51.100.1",
+		},
+		{
+			name:         "spoofed address on the left is skipped",
+			remoteAddr:   "10.0.0.1:40000",
+			forwardedFor: "1.1.1.1, 198.51.100.1, 192.168.1.2",
+			expectedAddr: "198.51.100.1",
+		},
+		{
+			name:         "trusted proxy without header",
+			remoteAddr:   "192.168.1.2:40000",
+			expectedAddr: "192.168.1.2:40000",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			r := &http.Request{
+				RemoteAddr: test.remoteAddr,
+				Header:     http.Header{},
+			}
+			if test.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			assert.Equal(t, test.expectedAddr, remoteAddr(r, trustedProxies))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/service"
+)
+
+type distributionKey struct {
+	subscription string
+	codec        codec.Codec
+}
+
+// distribution encodes the signed configuration once per subscription id and codec,
+// the message is shared by all the clients with the same subscription id and codec
+type distribution struct {
+	request   RequestDistribute
+	signature signature.Signature
+	// value is the data that is decoded once for the binary encodings
+	value    any
+	decoded  bool
+	messages map[distributionKey][]byte
+}
+
+func newDistribution(request RequestDistribute, signature signature.Signature) *distribution {
+	return &distribution{
+		request:   request,
+		signature: signature,
+		messages:  make(map[distributionKey][]byte),
+	}
+}
+
+func (d *distribution) message(subscription Subscription, c codec.Codec) ([]byte, error) {
+	key := distributionKey{
+		subscription: subscription.Id,
+		codec:        c,
+	}
+	if message, encoded := d.messages[key]; encoded {
+		return message, nil
+	}
+
+	response := newResponseDistribute(subscription, d.request, d.signature)
+	// the binary encodings carry the data as a native map
+	if !c.IsJSON() {
+		if !d.decoded {
+			value, err := signature.Value(d.request.Data)
+			if err != nil {
+				return nil, err
+			}
+			d.value = value
+			d.decoded = true
+		}
+		response.Data = d.value
+	}
+
+	message, err := c.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	d.messages[key] = message
+	return message, nil
+}
+
+// variants are the distributions of the configuration per set of the overrides,
+// the clients that match the same overrides share the signed configuration
+type variants struct {
+	request       RequestDistribute
+	overrides     entity.ConfigurationOverrides
+	signingSvc    service.SigningServicer
+	distributions map[string]*distribution
+}
+
+func newVariants(request RequestDistribute, overrides entity.ConfigurationOverrides, signingSvc service.SigningServicer) *variants {
+	return &variants{
+		request:       request,
+		overrides:     overrides,
+		signingSvc:    signingSvc,
+		distributions: make(map[string]*distribution),
+	}
+}
+
+// distribution returns the signed configuration that targets the client,
+// the instance id and the labels of the client never change after the handshake
+func (v *variants) distribution(client *Client) (*distribution, error) {
+	matching := v.overrides.Matching(client.InstanceId, client.Labels)
+	key := matching.Key()
+	if d, exists := v.distributions[key]; exists {
+		return d, nil
+	}
+
+	request, err := target(v.request, matching)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := v.signingSvc.SignConfiguration(request.ClientKey, request.Revision, request.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDistribution(request, signature)
+	v.distributions[key] = d
+	return d, nil
+}
+
+// target applies the overrides to the configuration,
+// the targeted configuration has its own revision
+func target(request RequestDistribute, overrides entity.ConfigurationOverrides) (RequestDistribute, error) {
+	if len(overrides) == 0 {
+		return request, nil
+	}
+
+	data, err := overrides.Apply(request.Data)
+	if err != nil {
+		return request, err
+	}
+
+	configuration := dto.ResponseGetConfigurationViewTypeJSON{
+		ClientKey: request.ClientKey,
+		Data:      data,
+	}
+	request.Data = configuration.Data
+	request.Revision = configuration.Revision()
+	return request, nil
+}
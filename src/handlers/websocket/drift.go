@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// maxSentRevisions is the number of the sent revisions that are kept per subscription
+const maxSentRevisions = 8
+
+// DriftStatus compares the revision that is applied by the client with the revision
+// that is sent to it, the sent revision already has the overrides of the client
+type DriftStatus string
+
+const (
+	DriftStatusInSync DriftStatus = "in_sync"
+	// DriftStatusPending is the client that hasn't reported the latest revision within the grace period
+	DriftStatusPending DriftStatus = "pending"
+	// DriftStatusStuck is the client that still runs an older revision after the grace period
+	DriftStatusStuck DriftStatus = "stuck"
+	// DriftStatusDrifted is the client that runs a configuration that is never sent to it
+	DriftStatusDrifted DriftStatus = "drifted"
+	// DriftStatusUnreported is the client that never reports, e.g. an older SDK
+	DriftStatusUnreported DriftStatus = "unreported"
+)
+
+var driftStatuses = []DriftStatus{
+	DriftStatusInSync,
+	DriftStatusPending,
+	DriftStatusStuck,
+	DriftStatusDrifted,
+	DriftStatusUnreported,
+}
+
+func (s Subscription) driftStatus(now time.Time, gracePeriod time.Duration) DriftStatus {
+	if s.Applied == "" {
+		return DriftStatusUnreported
+	}
+	if s.Applied == s.Revision {
+		return DriftStatusInSync
+	}
+
+	for _, revision := range s.sentRevisions {
+		if revision != s.Applied {
+			continue
+		}
+		if now.Sub(s.RevisionSentAt) < gracePeriod {
+			return DriftStatusPending
+		}
+		return DriftStatusStuck
+	}
+
+	return DriftStatusDrifted
+}
+
+// ResponseConnectionDriftInstance is the drift of a connected instance
+type ResponseConnectionDriftInstance struct {
+	Id         string            `json:"id"`
+	InstanceId string            `json:"instanceId"`
+	Hostname   string            `json:"hostname"`
+	Labels     map[string]string `json:"labels"`
+	Revision   string            `json:"revision"`
+	Applied    string            `json:"applied"`
+	AppliedAt  time.Time         `json:"appliedAt"`
+	Status     DriftStatus       `json:"status"`
+}
+
+// ResponseConnectionDrift summarizes the drift of the instances of a client key,
+// Applied counts the instances per applied revision
+type ResponseConnectionDrift struct {
+	ClientKey     string                            `json:"clientKey"`
+	ApplicationId string                            `json:"applicationId"`
+	Total         int                               `json:"total"`
+	Statuses      map[DriftStatus]int               `json:"statuses"`
+	Applied       map[string]int                    `json:"applied"`
+	Instances     []ResponseConnectionDriftInstance `json:"instances"`
+}
+
+type ResponseConnectionDrifts []ResponseConnectionDrift
+
+// NewResponseConnectionDrifts reports the drift of the subscriptions that match the filter,
+// the instances are narrowed by the status but the summary counts every instance
+func NewResponseConnectionDrifts(clients []Client, filter FilterClient, status DriftStatus, now time.Time, gracePeriod time.Duration) ResponseConnectionDrifts {
+	var (
+		clientKeys []string
+		drifts     = make(map[string]*ResponseConnectionDrift)
+	)
+
+	for _, c := range clients {
+		for _, subscription := range c.Subscriptions {
+			if filter.ClientKey != "" && subscription.ClientKey != filter.ClientKey {
+				continue
+			}
+			if filter.ApplicationId != "" && subscription.ApplicationId != filter.ApplicationId {
+				continue
+			}
+
+			drift, exists := drifts[subscription.ClientKey]
+			if !exists {
+				drift = &ResponseConnectionDrift{
+					ClientKey:     subscription.ClientKey,
+					ApplicationId: subscription.ApplicationId,
+					Statuses:      make(map[DriftStatus]int),
+					Applied:       make(map[string]int),
+					Instances:     make([]ResponseConnectionDriftInstance, 0),
+				}
+				for _, s := range driftStatuses {
+					drift.Statuses[s] = 0
+				}
+				drifts[subscription.ClientKey] = drift
+				clientKeys = append(clientKeys, subscription.ClientKey)
+			}
+
+			subscriptionStatus := subscription.driftStatus(now, gracePeriod)
+			drift.Total++
+			drift.Statuses[subscriptionStatus]++
+			if subscription.Applied != "" {
+				drift.Applied[subscription.Applied]++
+			}
+
+			if status != "" && status != subscriptionStatus {
+				continue
+			}
+			drift.Instances = append(drift.Instances, ResponseConnectionDriftInstance{
+				Id:         c.Id,
+				InstanceId: c.InstanceId,
+				Hostname:   c.Hostname,
+				Labels:     c.Labels,
+				Revision:   subscription.Revision,
+				Applied:    subscription.Applied,
+				AppliedAt:  subscription.AppliedAt,
+				Status:     subscriptionStatus,
+			})
+		}
+	}
+
+	sort.Strings(clientKeys)
+
+	responses := make(ResponseConnectionDrifts, 0, len(clientKeys))
+	for _, clientKey := range clientKeys {
+		drift := drifts[clientKey]
+		sort.Slice(drift.Instances, func(i, j int) bool {
+			return drift.Instances[i].Id < drift.Instances[j].Id
+		})
+		responses = append(responses, *drift)
+	}
+
+	return responses
+}
@@ -0,0 +1,174 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/src/handlers/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xwebsocket "golang.org/x/net/websocket"
+)
+
+func findDrift(t *testing.T, url string) websocket.ResponseConnectionDrifts {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var drift struct {
+		Data websocket.ResponseConnectionDrifts `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&drift))
+	return drift.Data
+}
+
+func TestDrift(t *testing.T) {
+	server := newWebsocketServer(t, func(cfg *config.Config) {
+		cfg.Websocket.DriftGracePeriod = 50 * time.Millisecond
+	})
+
+	testCases := []struct {
+		name       string
+		instanceId string
+		// report returns the reported revision from the initial and the latest revision
+		report   func(initial, latest string) string
+		expected websocket.DriftStatus
+	}{
+		{
+			name:       "applied the latest revision",
+			instanceId: "pod-a",
+			report:     func(initial, latest string) string { return latest },
+			expected:   websocket.DriftStatusInSync,
+		},
+		{
+			name:       "still runs the previous revision",
+			instanceId: "pod-b",
+			report:     func(initial, latest string) string { return initial },
+			expected:   websocket.DriftStatusStuck,
+		},
+		{
+			name:       "runs a revision that is never sent",
+			instanceId: "pod-c",
+			report:     func(initial, latest string) string { return "unknown" },
+			expected:   websocket.DriftStatusDrifted,
+		},
+		{
+			name:       "never reports",
+			instanceId: "pod-d",
+			expected:   websocket.DriftStatusUnreported,
+		},
+	}
+
+	conns := make([]*xwebsocket.Conn, len(testCases))
+	initial := make([]string, len(testCases))
+	for i, tc := range testCases {
+		conns[i] = dial(t, server, "authorization=service-key&instanceId="+tc.instanceId)
+		initial[i] = read(t, conns[i]).Revision
+	}
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+	write(t, self, websocket.RequestDistribute{
+		ClientKey: "service-key",
+		Revision:  "revision",
+		Data:      json.RawMessage(`{"url":"http://host"}`),
+	})
+
+	for i, tc := range testCases {
+		msg := read(t, conns[i])
+		require.Equal(t, "revision", msg.Revision, tc.name)
+		if tc.report == nil {
+			continue
+		}
+		write(t, conns[i], websocket.RequestSubscription{
+			Type:         websocket.MessageTypeReport,
+			Subscription: "default",
+			Revision:     tc.report(initial[i], msg.Revision),
+		})
+	}
+
+	var drifts websocket.ResponseConnectionDrifts
+	require.Eventually(t, func() bool {
+		drifts = findDrift(t, server.URL+"/v1/connections/drift?clientKey=service-key")
+		return len(drifts) == 1 &&
+			drifts[0].Statuses[websocket.DriftStatusInSync] == 1 &&
+			drifts[0].Statuses[websocket.DriftStatusStuck] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "service-key", drifts[0].ClientKey)
+	assert.Equal(t, len(testCases), drifts[0].Total)
+	assert.Equal(t, 1, drifts[0].Applied["revision"])
+
+	statuses := make(map[string]websocket.DriftStatus)
+	for _, instance := range drifts[0].Instances {
+		statuses[instance.InstanceId] = instance.Status
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, statuses[tc.instanceId])
+		})
+	}
+
+	// the instances are narrowed by the status, the summary still counts every instance
+	drifts = findDrift(t, server.URL+"/v1/connections/drift?clientKey=service-key&status=drifted")
+	require.Len(t, drifts, 1)
+	assert.Equal(t, len(testCases), drifts[0].Total)
+	require.Len(t, drifts[0].Instances, 1)
+	assert.Equal(t, "pod-c", drifts[0].Instances[0].InstanceId)
+	assert.Equal(t, "unknown", drifts[0].Instances[0].Applied)
+
+	resp, err := http.Get(server.URL + "/v1/connections/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var metrics struct {
+		Data websocket.ResponseConnectionMetrics `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&metrics))
+	assert.Equal(t, int64(1), metrics.Data.Drift[websocket.DriftStatusInSync])
+	assert.Equal(t, int64(1), metrics.Data.Drift[websocket.DriftStatusStuck])
+	assert.Equal(t, int64(1), metrics.Data.Drift[websocket.DriftStatusDrifted])
+	assert.Equal(t, int64(1), metrics.Data.Drift[websocket.DriftStatusUnreported])
+}
+
+func TestDriftReportInvalid(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+	read(t, conn)
+
+	testCases := []struct {
+		name    string
+		request websocket.RequestSubscription
+		err     error
+	}{
+		{
+			name: "empty revision",
+			request: websocket.RequestSubscription{
+				Type:         websocket.MessageTypeReport,
+				Subscription: "default",
+			},
+			err: websocket.ErrReportEmptyRevision,
+		},
+		{
+			name: "unknown subscription",
+			request: websocket.RequestSubscription{
+				Type:         websocket.MessageTypeReport,
+				Subscription: "platform",
+				Revision:     "revision",
+			},
+			err: websocket.ErrSubscriptionNotExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			write(t, conn, tc.request)
+			msg := read(t, conn)
+			assert.Equal(t, websocket.MessageTypeError, msg.Type)
+			assert.Contains(t, msg.Error, tc.err.Error())
+		})
+	}
+}
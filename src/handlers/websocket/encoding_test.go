@@ -0,0 +1,122 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/handlers/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xwebsocket "golang.org/x/net/websocket"
+)
+
+// encodedMessage is the message of the binary encodings, the data is a native map
+type encodedMessage struct {
+	Type         websocket.MessageType `json:"type"`
+	Subscription string                `json:"subscription"`
+	ClientKey    string                `json:"clientKey"`
+	Error        string                `json:"error"`
+	Revision     string                `json:"revision"`
+	Data         any                   `json:"data"`
+	Signature    string                `json:"signature"`
+	KeyId        string                `json:"keyId"`
+	IssuedAt     int64                 `json:"issuedAt"`
+}
+
+func readEncoded(t *testing.T, conn *xwebsocket.Conn, c codec.Codec) encodedMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var data []byte
+	require.NoError(t, xwebsocket.Message.Receive(conn, &data))
+
+	var msg encodedMessage
+	require.NoError(t, c.Unmarshal(data, &msg))
+	return msg
+}
+
+func TestSubscriptionEncoding(t *testing.T) {
+	signer, err := signature.NewSigner(signingKey)
+	require.NoError(t, err)
+	verifier, err := signature.NewVerifier(signer.VerificationKey())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		query string
+		codec codec.Codec
+	}{
+		{
+			name:  "json with deflate",
+			query: "&compression=deflate",
+			codec: codec.Codec{Encoding: codec.EncodingJSON, Compression: codec.CompressionDeflate},
+		},
+		{
+			name:  "msgpack",
+			query: "&encoding=msgpack",
+			codec: codec.Codec{Encoding: codec.EncodingMsgpack, Compression: codec.CompressionNone},
+		},
+		{
+			name:  "cbor with gzip",
+			query: "&encoding=cbor&compression=gzip",
+			codec: codec.Codec{Encoding: codec.EncodingCBOR, Compression: codec.CompressionGzip},
+		},
+	}
+
+	server := newWebsocketServer(t)
+	conns := make([]*xwebsocket.Conn, len(testCases))
+	for i, tc := range testCases {
+		conns[i] = dial(t, server, "authorization=service-key"+tc.query)
+		msg := readEncoded(t, conns[i], tc.codec)
+		assert.Equal(t, websocket.MessageTypeConfiguration, msg.Type, tc.name)
+	}
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+	write(t, self, websocket.RequestDistribute{
+		ClientKey: "service-key",
+		Revision:  "revision",
+		Data:      json.RawMessage(`{"port":8080,"ratio":0.5,"url":"http://host?a=1&b=<2>","tags":["a","b"]}`),
+	})
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := readEncoded(t, conns[i], tc.codec)
+			assert.Equal(t, websocket.MessageTypeConfiguration, msg.Type)
+			assert.Equal(t, "revision", msg.Revision)
+
+			sig := signature.Signature{KeyId: msg.KeyId, Value: msg.Signature, IssuedAt: msg.IssuedAt}
+			assert.NoError(t, verifier.VerifyValue("service-key", msg.Revision, msg.Data, sig))
+
+			data, err := signature.CanonicalValue(msg.Data)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"port":8080,"ratio":0.5,"url":"http://host?a=1&b=<2>","tags":["a","b"]}`, string(data))
+
+			// the subscription request is encoded in the negotiated codec
+			request, err := tc.codec.Marshal(websocket.RequestSubscription{
+				Type:          websocket.MessageTypeSubscribe,
+				Subscription:  "platform",
+				Authorization: "platform-key",
+			})
+			require.NoError(t, err)
+			require.NoError(t, xwebsocket.Message.Send(conns[i], request))
+
+			msg = readEncoded(t, conns[i], tc.codec)
+			assert.Equal(t, websocket.MessageTypeSubscribed, msg.Type)
+			assert.Equal(t, "platform", msg.Subscription)
+		})
+	}
+}
+
+func TestSubscriptionEncodingNotSupported(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key&encoding=xml")
+
+	// the error is sent in JSON
+	msg := read(t, conn)
+	assert.Equal(t, websocket.MessageTypeError, msg.Type)
+	assert.Contains(t, msg.Error, codec.ErrEncodingNotSupported.Error())
+}
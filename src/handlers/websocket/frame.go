@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"io"
+
+	"golang.org/x/net/websocket"
+)
+
+// receive reads the next text or binary message from the connection.
+// websocket.Message.Receive swallows the control frames, so the frames are read here
+// to let the caller knows every frame that is received including pong
+func receive(c *websocket.Conn, onFrame func(payloadType byte)) ([]byte, error) {
+	maxPayloadBytes := c.MaxPayloadBytes
+	if maxPayloadBytes == 0 {
+		maxPayloadBytes = websocket.DefaultMaxPayloadBytes
+	}
+
+	for {
+		frame, err := c.NewFrameReader()
+		if err != nil {
+			return nil, err
+		}
+
+		payloadType := frame.PayloadType()
+		onFrame(payloadType)
+
+		// ping is answered and close is turned into io.EOF by the frame handler
+		frame, err = c.HandleFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil {
+			continue
+		}
+
+		if frame.Len() > maxPayloadBytes {
+			io.Copy(io.Discard, frame)
+			return nil, websocket.ErrFrameTooLarge
+		}
+
+		return io.ReadAll(frame)
+	}
+}
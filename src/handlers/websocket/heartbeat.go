@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+type DisconnectReason string
+
+const (
+	DisconnectReasonClosed      DisconnectReason = "closed"
+	DisconnectReasonTimeout     DisconnectReason = "timeout"
+	DisconnectReasonSendFailure DisconnectReason = "send_failure"
+	DisconnectReasonForced      DisconnectReason = "forced"
+	DisconnectReasonShutdown    DisconnectReason = "shutdown"
+	// DisconnectReasonSlowConsumer is used when the send queue is full with the disconnect policy
+	DisconnectReasonSlowConsumer DisconnectReason = "slow_consumer"
+)
+
+// extendReadDeadline gives the client another ping interval and pong timeout
+// to send any frame, otherwise the read is failed and the client is removed
+func (w *WebsocketConnection) extendReadDeadline(client *Client) {
+	if client.Self {
+		return
+	}
+
+	client.Connection.SetReadDeadline(time.Now().
+		Add(w.config.PingInterval).
+		Add(w.config.PongTimeout))
+}
+
+// heartbeat sends ping to the client on every ping interval until the context is done.
+// the internal connection never reads its messages, so it won't answer the ping
+func (w *WebsocketConnection) heartbeat(ctx context.Context, client *Client) {
+	if client.Self {
+		return
+	}
+
+	// Conn.Write uses PayloadType as the frame type, messages are sent through
+	// websocket.Message.Send which doesn't use it, so Write is dedicated for ping
+	client.Connection.PayloadType = websocket.PingFrame
+	w.extendReadDeadline(client)
+
+	ticker := time.NewTicker(w.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			client.Connection.SetWriteDeadline(time.Now().Add(w.config.WriteTimeout))
+			if _, err := client.Connection.Write([]byte{}); err != nil {
+				log.Warn().
+					Err(err).
+					Str("clientId", client.Id).
+					Msg("[heartbeat] err: send ping")
+				w.removeClient(client.Id, DisconnectReasonTimeout)
+				return
+			}
+		}
+	}
+}
+
+type connectionMetrics struct {
+	connected    atomic.Int64
+	disconnected atomic.Int64
+	dropped      atomic.Int64
+	reasons      map[DisconnectReason]*atomic.Int64
+}
+
+func newConnectionMetrics() *connectionMetrics {
+	return &connectionMetrics{
+		reasons: map[DisconnectReason]*atomic.Int64{
+			DisconnectReasonClosed:       {},
+			DisconnectReasonTimeout:      {},
+			DisconnectReasonSendFailure:  {},
+			DisconnectReasonForced:       {},
+			DisconnectReasonShutdown:     {},
+			DisconnectReasonSlowConsumer: {},
+		},
+	}
+}
+
+func (m *connectionMetrics) connect() {
+	m.connected.Add(1)
+}
+
+func (m *connectionMetrics) disconnect(reason DisconnectReason) {
+	m.disconnected.Add(1)
+	if counter, exists := m.reasons[reason]; exists {
+		counter.Add(1)
+	}
+}
+
+func (m *connectionMetrics) drop(count int) {
+	m.dropped.Add(int64(count))
+}
+
+type ResponseConnectionMetrics struct {
+	Active        int64                      `json:"active"`
+	Subscriptions int64                      `json:"subscriptions"`
+	Connected     int64                      `json:"connected"`
+	Disconnected  int64                      `json:"disconnected"`
+	Dropped       int64                      `json:"dropped"`
+	Reasons       map[DisconnectReason]int64 `json:"reasons"`
+	// Drift counts the subscriptions per drift status
+	Drift map[DriftStatus]int64 `json:"drift"`
+}
+
+func (m *connectionMetrics) response(active, subscriptions int) ResponseConnectionMetrics {
+	response := ResponseConnectionMetrics{
+		Active:        int64(active),
+		Subscriptions: int64(subscriptions),
+		Connected:     m.connected.Load(),
+		Disconnected:  m.disconnected.Load(),
+		Dropped:       m.dropped.Load(),
+		Reasons:       make(map[DisconnectReason]int64),
+	}
+
+	for reason, counter := range m.reasons {
+		response.Reasons[reason] = counter.Load()
+	}
+
+	return response
+}
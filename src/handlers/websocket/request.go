@@ -7,6 +7,7 @@ import (
 
 type RequestDistribute struct {
 	ClientKey string          `json:"clientKey"`
+	Revision  string          `json:"revision,omitempty"`
 	Data      json.RawMessage `json:"data"`
 }
 
@@ -23,3 +24,7 @@ func (r RequestDistribute) Validate() []error {
 
 	return errs
 }
+
+func (r RequestDistribute) Message() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import "sort"
+
+// ResponseConnectionInstance is the connected instance that runs a revision
+type ResponseConnectionInstance struct {
+	Id         string            `json:"id"`
+	InstanceId string            `json:"instanceId"`
+	Hostname   string            `json:"hostname"`
+	SdkName    string            `json:"sdkName"`
+	SdkVersion string            `json:"sdkVersion"`
+	Labels     map[string]string `json:"labels"`
+}
+
+// ResponseConnectionRevision groups the instances by the revision they received,
+// the empty revision means nothing is received yet
+type ResponseConnectionRevision struct {
+	ClientKey     string                       `json:"clientKey"`
+	ApplicationId string                       `json:"applicationId"`
+	Revision      string                       `json:"revision"`
+	Instances     []ResponseConnectionInstance `json:"instances"`
+}
+
+type ResponseConnectionRevisions []ResponseConnectionRevision
+
+// NewResponseConnectionRevisions reports which instances run which revision,
+// only the subscriptions that match the filter are reported
+func NewResponseConnectionRevisions(clients []Client, filter FilterClient) ResponseConnectionRevisions {
+	type revisionKey struct {
+		clientKey string
+		revision  string
+	}
+
+	var (
+		keys      []revisionKey
+		revisions = make(map[revisionKey]*ResponseConnectionRevision)
+	)
+
+	for _, c := range clients {
+		for _, subscription := range c.Subscriptions {
+			if filter.ClientKey != "" && subscription.ClientKey != filter.ClientKey {
+				continue
+			}
+			if filter.ApplicationId != "" && subscription.ApplicationId != filter.ApplicationId {
+				continue
+			}
+
+			key := revisionKey{
+				clientKey: subscription.ClientKey,
+				revision:  subscription.Revision,
+			}
+			revision, exists := revisions[key]
+			if !exists {
+				revision = &ResponseConnectionRevision{
+					ClientKey:     subscription.ClientKey,
+					ApplicationId: subscription.ApplicationId,
+					Revision:      subscription.Revision,
+				}
+				revisions[key] = revision
+				keys = append(keys, key)
+			}
+
+			revision.Instances = append(revision.Instances, ResponseConnectionInstance{
+				Id:         c.Id,
+				InstanceId: c.InstanceId,
+				Hostname:   c.Hostname,
+				SdkName:    c.SdkName,
+				SdkVersion: c.SdkVersion,
+				Labels:     c.Labels,
+			})
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].clientKey != keys[j].clientKey {
+			return keys[i].clientKey < keys[j].clientKey
+		}
+		return keys[i].revision < keys[j].revision
+	})
+
+	responses := make(ResponseConnectionRevisions, 0, len(keys))
+	for _, key := range keys {
+		revision := revisions[key]
+		sort.Slice(revision.Instances, func(i, j int) bool {
+			return revision.Instances[i].Id < revision.Instances[j].Id
+		})
+		responses = append(responses, *revision)
+	}
+
+	return responses
+}
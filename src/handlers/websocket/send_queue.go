@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"sync"
+)
+
+type QueuePolicy string
+
+const (
+	// QueuePolicyDropOldest drops the oldest queued message to make room for the new one
+	QueuePolicyDropOldest QueuePolicy = "drop_oldest"
+	// QueuePolicyCoalesce drops the queued messages of the same subscription
+	// and keeps only the latest snapshot of every subscription
+	QueuePolicyCoalesce QueuePolicy = "coalesce"
+	// QueuePolicyDisconnect disconnects the client that can't keep up
+	QueuePolicyDisconnect QueuePolicy = "disconnect"
+)
+
+func NewQueuePolicy(policy string) QueuePolicy {
+	switch QueuePolicy(policy) {
+	case QueuePolicyDropOldest, QueuePolicyDisconnect:
+		return QueuePolicy(policy)
+	default:
+		return QueuePolicyCoalesce
+	}
+}
+
+type outboundMessage struct {
+	data      []byte
+	clientKey string
+	revision  string
+	// control is the answer of the subscription request, it's never coalesced
+	control bool
+}
+
+// coalesceWith tells whether the queued message is replaced by the new message
+func (m outboundMessage) coalesceWith(message outboundMessage) bool {
+	return !m.control && !message.control && m.clientKey == message.clientKey
+}
+
+// sendQueue is the bounded outbound queue of a client,
+// it's drained by the writer goroutine of the client
+type sendQueue struct {
+	mtx      sync.Mutex
+	policy   QueuePolicy
+	messages chan outboundMessage
+}
+
+func newSendQueue(size int, policy QueuePolicy) *sendQueue {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &sendQueue{
+		policy:   policy,
+		messages: make(chan outboundMessage, size),
+	}
+}
+
+// push never blocks, it returns the number of dropped messages
+// and false when the queue is full and the client must be disconnected
+func (q *sendQueue) push(message outboundMessage) (int, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	select {
+	case q.messages <- message:
+		return 0, true
+	default:
+	}
+
+	dropped := 0
+	switch q.policy {
+	case QueuePolicyDisconnect:
+		return dropped, false
+	case QueuePolicyDropOldest:
+		select {
+		case <-q.messages:
+			dropped++
+		default:
+		}
+	default:
+		kept := make([]outboundMessage, 0, cap(q.messages))
+		for drained := false; !drained; {
+			select {
+			case queued := <-q.messages:
+				if queued.coalesceWith(message) {
+					dropped++
+					continue
+				}
+				kept = append(kept, queued)
+			default:
+				drained = true
+			}
+		}
+
+		// the queue is full of the other subscriptions, drop the oldest
+		for len(kept) >= cap(q.messages) {
+			kept = kept[1:]
+			dropped++
+		}
+		for _, queued := range kept {
+			q.messages <- queued
+		}
+	}
+
+	// only the writer takes from the queue, so there is a room for the message
+	q.messages <- message
+	return dropped, true
+}
+
+func (q *sendQueue) len() int {
+	return len(q.messages)
+}
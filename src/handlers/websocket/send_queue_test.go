@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendQueuePush(t *testing.T) {
+	messages := func(q *sendQueue) []string {
+		close(q.messages)
+		result := []string{}
+		for message := range q.messages {
+			result = append(result, string(message.data))
+		}
+		return result
+	}
+
+	testCases := []struct {
+		name            string
+		policy          QueuePolicy
+		expectedDropped int
+		expectedOk      bool
+		expected        []string
+	}{
+		{
+			name:            "drop oldest",
+			policy:          QueuePolicyDropOldest,
+			expectedDropped: 1,
+			expectedOk:      true,
+			expected:        []string{"2", "3", "4"},
+		},
+		{
+			name:            "coalesce to latest",
+			policy:          QueuePolicyCoalesce,
+			expectedDropped: 3,
+			expectedOk:      true,
+			expected:        []string{"4"},
+		},
+		{
+			name:            "disconnect",
+			policy:          QueuePolicyDisconnect,
+			expectedDropped: 0,
+			expectedOk:      false,
+			expected:        []string{"1", "2", "3"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			q := newSendQueue(3, test.policy)
+			for _, data := range []string{"1", "2", "3"} {
+				_, ok := q.push(outboundMessage{data: []byte(data)})
+				assert.True(t, ok)
+			}
+
+			dropped, ok := q.push(outboundMessage{data: []byte("4")})
+
+			assert.Equal(t, test.expectedDropped, dropped)
+			assert.Equal(t, test.expectedOk, ok)
+			assert.Equal(t, test.expected, messages(q))
+		})
+	}
+
+	t.Run("unknown policy fallback to coalesce", func(t *testing.T) {
+		assert.Equal(t, QueuePolicyCoalesce, NewQueuePolicy("unknown"))
+		assert.Equal(t, QueuePolicyDropOldest, NewQueuePolicy("drop_oldest"))
+	})
+}
+
+func TestSendQueueCoalescePerSubscription(t *testing.T) {
+	q := newSendQueue(3, QueuePolicyCoalesce)
+	for _, message := range []outboundMessage{
+		{data: []byte("a1"), clientKey: "a"},
+		{data: []byte("b1"), clientKey: "b"},
+		{data: []byte("subscribed"), control: true},
+	} {
+		_, ok := q.push(message)
+		assert.True(t, ok)
+	}
+
+	// only the queued message of the same subscription is replaced
+	dropped, ok := q.push(outboundMessage{data: []byte("a2"), clientKey: "a"})
+	assert.Equal(t, 1, dropped)
+	assert.True(t, ok)
+
+	// the queue is full of the other subscriptions, the oldest is dropped
+	dropped, ok = q.push(outboundMessage{data: []byte("c1"), clientKey: "c"})
+	assert.Equal(t, 1, dropped)
+	assert.True(t, ok)
+
+	close(q.messages)
+	result := []string{}
+	for message := range q.messages {
+		result = append(result, string(message.data))
+	}
+	assert.Equal(t, []string{"subscribed", "a2", "c1"}, result)
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/pkg/signature"
+)
+
+var (
+	ErrSubscriptionExists      error = errors.New("err: subscription already exists")
+	ErrSubscriptionNotExists   error = errors.New("err: subscription is not exists")
+	ErrSubscriptionLimit       error = errors.New("err: subscription limit is reached")
+	ErrSubscriptionUnauthorize error = errors.New("err: client key is not authorized")
+	ErrReportEmptyRevision     error = errors.New("err: reported revision cannot be empty")
+)
+
+type MessageType string
+
+const (
+	// sent by the client
+	MessageTypeSubscribe   MessageType = "subscribe"
+	MessageTypeUnsubscribe MessageType = "unsubscribe"
+	// MessageTypeReport reports the revision that is applied by the client
+	MessageTypeReport MessageType = "report"
+
+	// sent by the server
+	MessageTypeSubscribed    MessageType = "subscribed"
+	MessageTypeUnsubscribed  MessageType = "unsubscribed"
+	MessageTypeConfiguration MessageType = "configuration"
+	MessageTypeError         MessageType = "error"
+)
+
+// DefaultSubscription is the subscription of the key that is sent
+// by the authorization query on the handshake
+const DefaultSubscription = "default"
+
+// Subscription is an application key that the client listens to,
+// a client can listen to several keys on the same connection
+type Subscription struct {
+	Id            string
+	ClientKey     string
+	ApplicationId string
+	// Revision is the last revision that is sent to the client
+	Revision       string
+	RevisionSentAt time.Time
+	// Applied is the last revision that is reported by the client
+	Applied      string
+	AppliedAt    time.Time
+	SubscribedAt time.Time
+
+	// sentRevisions are the latest sent revisions, the applied revision
+	// that is never sent to the client is a drift
+	sentRevisions []string
+}
+
+// RequestSubscription is sent by the client to subscribe or unsubscribe a key,
+// the subscription id is chosen by the client and used to tag the messages
+type RequestSubscription struct {
+	Type          MessageType `json:"type"`
+	Subscription  string      `json:"subscription"`
+	Authorization string      `json:"authorization,omitempty"`
+	// Revision is the applied revision of the report
+	Revision string `json:"revision,omitempty"`
+}
+
+// isSubscription tells whether the message is a subscription request,
+// the other messages are handled as the distribute request
+func isSubscription(c codec.Codec, message []byte) (RequestSubscription, bool) {
+	var request RequestSubscription
+	if err := c.Unmarshal(message, &request); err != nil {
+		return request, false
+	}
+
+	switch request.Type {
+	case MessageTypeSubscribe, MessageTypeUnsubscribe, MessageTypeReport:
+		return request, true
+	}
+	return request, false
+}
+
+func (r RequestSubscription) Validate() error {
+	if r.Subscription == "" {
+		return errors.New("err: subscription cannot be empty")
+	}
+	if r.Type == MessageTypeSubscribe && r.Authorization == "" {
+		return errors.New("err: authorization cannot be empty")
+	}
+	if r.Type == MessageTypeReport && r.Revision == "" {
+		return ErrReportEmptyRevision
+	}
+	return nil
+}
+
+// ResponseSubscription answers the subscription request
+type ResponseSubscription struct {
+	Type         MessageType `json:"type"`
+	Subscription string      `json:"subscription"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// ResponseDistribute is the configuration that is sent to the client,
+// it's tagged by the subscription id of the client
+type ResponseDistribute struct {
+	Type         MessageType `json:"type"`
+	Subscription string      `json:"subscription"`
+	ClientKey    string      `json:"clientKey"`
+	Revision     string      `json:"revision,omitempty"`
+	// Data is the JSON document, or the native map of the binary encodings
+	Data any `json:"data"`
+	// Signature is the detached signature of the configuration, it's verified
+	// with the key of the KeyId that is published on /v1/signing-keys
+	Signature string `json:"signature"`
+	KeyId     string `json:"keyId"`
+	IssuedAt  int64  `json:"issuedAt"`
+}
+
+func newResponseDistribute(subscription Subscription, request RequestDistribute, signature signature.Signature) ResponseDistribute {
+	return ResponseDistribute{
+		Type:         MessageTypeConfiguration,
+		Subscription: subscription.Id,
+		ClientKey:    request.ClientKey,
+		Revision:     request.Revision,
+		Data:         request.Data,
+		Signature:    signature.Value,
+		KeyId:        signature.KeyId,
+		IssuedAt:     signature.IssuedAt,
+	}
+}
+
+type ResponseConnectionSubscription struct {
+	Id            string    `json:"id"`
+	ClientKey     string    `json:"clientKey"`
+	ApplicationId string    `json:"applicationId"`
+	Revision      string    `json:"revision"`
+	Applied       string    `json:"applied"`
+	AppliedAt     time.Time `json:"appliedAt"`
+	SubscribedAt  time.Time `json:"subscribedAt"`
+}
+
+func NewResponseConnectionSubscriptions(subscriptions map[string]*Subscription) []ResponseConnectionSubscription {
+	responses := make([]ResponseConnectionSubscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		responses = append(responses, ResponseConnectionSubscription{
+			Id:            subscription.Id,
+			ClientKey:     subscription.ClientKey,
+			ApplicationId: subscription.ApplicationId,
+			Revision:      subscription.Revision,
+			Applied:       subscription.Applied,
+			AppliedAt:     subscription.AppliedAt,
+			SubscribedAt:  subscription.SubscribedAt,
+		})
+	}
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Id < responses[j].Id
+	})
+
+	return responses
+}
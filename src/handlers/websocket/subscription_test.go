@@ -0,0 +1,402 @@
+package websocket_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/container"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/application/application/dto"
+	signingsvc "github.com/nurcahyaari/coma/src/application/signing/service"
+	"github.com/nurcahyaari/coma/src/domain/entity"
+	"github.com/nurcahyaari/coma/src/domain/service"
+	"github.com/nurcahyaari/coma/src/handlers/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xwebsocket "golang.org/x/net/websocket"
+)
+
+var applicationKeys = map[string]string{
+	"service-key":  "service",
+	"platform-key": "platform",
+	"other-key":    "other",
+}
+
+const internalToken = "internal-token"
+
+var signingKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+
+type fakeApplicationKeyService struct {
+	service.ApplicationKeyServicer
+}
+
+func (fakeApplicationKeyService) InternalFindApplicationKey(ctx context.Context, request dto.RequestInternalFindApplicationKey) (entity.ApplicationKey, error) {
+	applicationId, exists := applicationKeys[request.Key]
+	if !exists {
+		return entity.ApplicationKey{}, nil
+	}
+	return entity.ApplicationKey{
+		ApplicationId: applicationId,
+		Key:           request.Key,
+	}, nil
+}
+
+type fakeConfigurationService struct {
+	service.ApplicationConfigurationServicer
+}
+
+func (fakeConfigurationService) GetConfigurationViewTypeJSON(ctx context.Context, req dto.RequestGetConfiguration) (dto.ResponseGetConfigurationViewTypeJSON, error) {
+	return dto.ResponseGetConfigurationViewTypeJSON{
+		ClientKey: req.XClientKey,
+		Data:      json.RawMessage(`{"application":"` + applicationKeys[req.XClientKey] + `"}`),
+	}, nil
+}
+
+// configurationOverrides target the canary and the "pod-1" instance of "service-key"
+var configurationOverrides = entity.ConfigurationOverrides{
+	{
+		Id:        "canary",
+		ClientKey: "service-key",
+		Labels:    map[string]string{"track": "canary"},
+		Values:    map[string]any{"feature": true},
+	},
+	{
+		Id:         "pod-1",
+		ClientKey:  "service-key",
+		InstanceId: "pod-1",
+		Values:     map[string]any{"feature": false, "replicas": 2},
+	},
+}
+
+func (fakeConfigurationService) InternalFindConfigurationOverrides(ctx context.Context, clientKey string) (entity.ConfigurationOverrides, error) {
+	var overrides entity.ConfigurationOverrides
+	for _, override := range configurationOverrides {
+		if override.ClientKey == clientKey {
+			overrides = append(overrides, override)
+		}
+	}
+	return overrides, nil
+}
+
+func newWebsocketServer(t *testing.T, options ...func(cfg *config.Config)) *httptest.Server {
+	cfg := &config.Config{
+		Websocket: config.WebsocketConfig{
+			PingInterval:     time.Minute,
+			PongTimeout:      time.Minute,
+			WriteTimeout:     time.Second,
+			SendQueueSize:    16,
+			SendQueuePolicy:  "coalesce",
+			MaxSubscriptions: 2,
+			InternalToken:    internalToken,
+		},
+		Signing: config.SigningConfig{
+			PrivateKey: signingKey,
+		},
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+	handler := websocket.NewWebsocketHandler(cfg, container.Service{
+		ApplicationKeyServicer:           fakeApplicationKeyService{},
+		ApplicationConfigurationServicer: fakeConfigurationService{},
+		SigningServicer:                  signingsvc.NewSigningService(cfg, container.Container{}),
+	})
+
+	router := chi.NewRouter()
+	handler.Router(router)
+	router.Route("/v1/connections", handler.ConnectionRouter)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(func() {
+		handler.Close()
+		server.Close()
+	})
+	return server
+}
+
+func dial(t *testing.T, server *httptest.Server, query string, header ...string) *xwebsocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/websocket?" + query
+	wsConfig, err := xwebsocket.NewConfig(url, server.URL)
+	require.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		wsConfig.Header.Set(header[i], header[i+1])
+	}
+
+	conn, err := xwebsocket.DialConfig(wsConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+type message struct {
+	Type         websocket.MessageType `json:"type"`
+	Subscription string                `json:"subscription"`
+	ClientKey    string                `json:"clientKey"`
+	Error        string                `json:"error"`
+	Revision     string                `json:"revision"`
+	Data         json.RawMessage       `json:"data"`
+	Signature    string                `json:"signature"`
+	KeyId        string                `json:"keyId"`
+	IssuedAt     int64                 `json:"issuedAt"`
+}
+
+func read(t *testing.T, conn *xwebsocket.Conn) message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg message
+	require.NoError(t, xwebsocket.JSON.Receive(conn, &msg))
+	return msg
+}
+
+func write(t *testing.T, conn *xwebsocket.Conn, value any) {
+	t.Helper()
+	require.NoError(t, xwebsocket.JSON.Send(conn, value))
+}
+
+func TestSubscription(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+
+	// the key of the handshake is the default subscription
+	msg := read(t, conn)
+	assert.Equal(t, websocket.MessageTypeConfiguration, msg.Type)
+	assert.Equal(t, websocket.DefaultSubscription, msg.Subscription)
+	assert.JSONEq(t, `{"application":"service"}`, string(msg.Data))
+
+	testCases := []struct {
+		name     string
+		request  websocket.RequestSubscription
+		expected []message
+	}{
+		{
+			name: "subscribe another key",
+			request: websocket.RequestSubscription{
+				Type:          websocket.MessageTypeSubscribe,
+				Subscription:  "platform",
+				Authorization: "platform-key",
+			},
+			expected: []message{
+				{Type: websocket.MessageTypeSubscribed, Subscription: "platform"},
+				{Type: websocket.MessageTypeConfiguration, Subscription: "platform", ClientKey: "platform-key", Data: json.RawMessage(`{"application":"platform"}`)},
+			},
+		},
+		{
+			name: "subscribe the subscribed key",
+			request: websocket.RequestSubscription{
+				Type:          websocket.MessageTypeSubscribe,
+				Subscription:  "platform-2",
+				Authorization: "platform-key",
+			},
+			expected: []message{
+				{Type: websocket.MessageTypeError, Subscription: "platform-2", Error: websocket.ErrSubscriptionExists.Error()},
+			},
+		},
+		{
+			name: "subscribe unknown key",
+			request: websocket.RequestSubscription{
+				Type:          websocket.MessageTypeSubscribe,
+				Subscription:  "unknown",
+				Authorization: "unknown-key",
+			},
+			expected: []message{
+				{Type: websocket.MessageTypeError, Subscription: "unknown", Error: websocket.ErrSubscriptionUnauthorize.Error()},
+			},
+		},
+		{
+			name: "unsubscribe unknown subscription",
+			request: websocket.RequestSubscription{
+				Type:         websocket.MessageTypeUnsubscribe,
+				Subscription: "unknown",
+			},
+			expected: []message{
+				{Type: websocket.MessageTypeError, Subscription: "unknown", Error: websocket.ErrSubscriptionNotExists.Error()},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			write(t, conn, tc.request)
+
+			for _, expected := range tc.expected {
+				act := read(t, conn)
+				assert.Equal(t, expected.Type, act.Type)
+				assert.Equal(t, expected.Subscription, act.Subscription)
+				assert.Equal(t, expected.Error, act.Error)
+				if expected.Data != nil {
+					assert.JSONEq(t, string(expected.Data), string(act.Data))
+				}
+			}
+		})
+	}
+}
+
+func TestSubscriptionBroadcast(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+	read(t, conn)
+
+	write(t, conn, websocket.RequestSubscription{
+		Type:          websocket.MessageTypeSubscribe,
+		Subscription:  "platform",
+		Authorization: "platform-key",
+	})
+	read(t, conn)
+	read(t, conn)
+
+	// the connection is listed by any of its keys
+	resp, err := http.Get(server.URL + "/v1/connections?clientKey=platform-key")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var connections struct {
+		Data websocket.ResponseConnections `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&connections))
+	require.Len(t, connections.Data, 1)
+	require.Len(t, connections.Data[0].Subscriptions, 2)
+	assert.Equal(t, websocket.DefaultSubscription, connections.Data[0].Subscriptions[0].Id)
+	assert.Equal(t, "platform", connections.Data[0].Subscriptions[1].Id)
+	assert.Equal(t, "platform", connections.Data[0].Subscriptions[1].ApplicationId)
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+
+	// the message is tagged by the subscription of the key
+	write(t, self, websocket.RequestDistribute{ClientKey: "platform-key", Data: json.RawMessage(`{"version":2}`)})
+	msg := read(t, conn)
+	assert.Equal(t, "platform", msg.Subscription)
+	assert.JSONEq(t, `{"version":2}`, string(msg.Data))
+
+	write(t, conn, websocket.RequestSubscription{
+		Type:         websocket.MessageTypeUnsubscribe,
+		Subscription: "platform",
+	})
+	msg = read(t, conn)
+	assert.Equal(t, websocket.MessageTypeUnsubscribed, msg.Type)
+
+	// the unsubscribed key is not sent anymore
+	write(t, self, websocket.RequestDistribute{ClientKey: "platform-key", Data: json.RawMessage(`{"version":3}`)})
+	write(t, self, websocket.RequestDistribute{ClientKey: "service-key", Data: json.RawMessage(`{"version":3}`)})
+	msg = read(t, conn)
+	assert.Equal(t, websocket.DefaultSubscription, msg.Subscription)
+	assert.Equal(t, "service-key", msg.ClientKey)
+}
+
+func TestSubscriptionLimit(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+	read(t, conn)
+
+	write(t, conn, websocket.RequestSubscription{
+		Type:          websocket.MessageTypeSubscribe,
+		Subscription:  "platform",
+		Authorization: "platform-key",
+	})
+	read(t, conn)
+	read(t, conn)
+
+	// the limit is 2 subscriptions
+	write(t, conn, websocket.RequestSubscription{
+		Type:          websocket.MessageTypeSubscribe,
+		Subscription:  "other",
+		Authorization: "other-key",
+	})
+	msg := read(t, conn)
+	assert.Equal(t, websocket.MessageTypeError, msg.Type)
+	assert.Equal(t, websocket.ErrSubscriptionLimit.Error(), msg.Error)
+}
+
+func TestSubscriptionReceiveOnly(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+	read(t, conn)
+
+	other := dial(t, server, "authorization=other-key")
+	read(t, other)
+
+	// the client can't distribute the configuration of another key
+	write(t, other, websocket.RequestDistribute{ClientKey: "service-key", Data: json.RawMessage(`{"forged":true}`)})
+	msg := read(t, other)
+	assert.Equal(t, websocket.MessageTypeError, msg.Type)
+	assert.Equal(t, websocket.ErrReceiveOnly.Error(), msg.Error)
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+	write(t, self, websocket.RequestDistribute{ClientKey: "service-key", Data: json.RawMessage(`{"version":2}`)})
+	msg = read(t, conn)
+	assert.JSONEq(t, `{"version":2}`, string(msg.Data))
+}
+
+func TestSubscriptionSelfUnauthorized(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+	read(t, conn)
+
+	testCases := []struct {
+		name   string
+		header []string
+	}{
+		{
+			name: "without token",
+		},
+		{
+			name:   "wrong token",
+			header: []string{config.InternalTokenHeader, "wrong-token"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			self := dial(t, server, "self=true", tc.header...)
+
+			// the connection is closed by the server
+			self.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var data []byte
+			assert.Error(t, xwebsocket.Message.Receive(self, &data))
+		})
+	}
+
+	// nothing is distributed to the client
+	write(t, conn, websocket.RequestSubscription{
+		Type:         websocket.MessageTypeUnsubscribe,
+		Subscription: "unknown",
+	})
+	msg := read(t, conn)
+	assert.Equal(t, websocket.MessageTypeError, msg.Type)
+}
+
+func TestSubscriptionSignature(t *testing.T) {
+	server := newWebsocketServer(t)
+	conn := dial(t, server, "authorization=service-key")
+	read(t, conn)
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+	write(t, self, websocket.RequestDistribute{
+		ClientKey: "service-key",
+		Revision:  "revision",
+		Data:      json.RawMessage(`{ "url": "http://host?a=1&b=<2>" }`),
+	})
+
+	msg := read(t, conn)
+
+	signer, err := signature.NewSigner(signingKey)
+	require.NoError(t, err)
+	verifier, err := signature.NewVerifier(signer.VerificationKey())
+	require.NoError(t, err)
+
+	sig := signature.Signature{KeyId: msg.KeyId, Value: msg.Signature, IssuedAt: msg.IssuedAt}
+	assert.NoError(t, verifier.Verify("service-key", msg.Revision, msg.Data, sig))
+	assert.ErrorIs(t, verifier.Verify("other-key", msg.Revision, msg.Data, sig), signature.ErrInvalidSignature)
+	assert.ErrorIs(t, verifier.Verify("service-key", msg.Revision, []byte(`{"url":"http://forged"}`), sig), signature.ErrInvalidSignature)
+}
@@ -0,0 +1,149 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nurcahyaari/coma/config"
+	"github.com/nurcahyaari/coma/pkg/signature"
+	"github.com/nurcahyaari/coma/src/handlers/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xwebsocket "golang.org/x/net/websocket"
+)
+
+func TestTargetedDelivery(t *testing.T) {
+	signer, err := signature.NewSigner(signingKey)
+	require.NoError(t, err)
+	verifier, err := signature.NewVerifier(signer.VerificationKey())
+	require.NoError(t, err)
+
+	server := newWebsocketServer(t)
+
+	testCases := []struct {
+		name     string
+		query    string
+		initial  string
+		expected string
+	}{
+		{
+			name:     "not targeted",
+			query:    "&instanceId=pod-0",
+			initial:  `{"application":"service"}`,
+			expected: `{"url":"http://host"}`,
+		},
+		{
+			name:     "label canary",
+			query:    "&instanceId=pod-2&label=track:canary",
+			initial:  `{"application":"service","feature":true}`,
+			expected: `{"url":"http://host","feature":true}`,
+		},
+		{
+			name:     "instance override wins over the label override",
+			query:    "&instanceId=pod-1&label=track:canary&hostname=host-1",
+			initial:  `{"application":"service","feature":false,"replicas":2}`,
+			expected: `{"url":"http://host","feature":false,"replicas":2}`,
+		},
+	}
+
+	conns := make([]*xwebsocket.Conn, len(testCases))
+	for i, tc := range testCases {
+		conns[i] = dial(t, server, "authorization=service-key"+tc.query)
+		msg := read(t, conns[i])
+		assert.JSONEq(t, tc.initial, string(msg.Data), tc.name)
+	}
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+	write(t, self, websocket.RequestDistribute{
+		ClientKey: "service-key",
+		Revision:  "revision",
+		Data:      json.RawMessage(`{"url":"http://host"}`),
+	})
+
+	revisions := make(map[string]string)
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := read(t, conns[i])
+			assert.JSONEq(t, tc.expected, string(msg.Data))
+
+			// the targeted configuration is signed on its own revision
+			sig := signature.Signature{KeyId: msg.KeyId, Value: msg.Signature, IssuedAt: msg.IssuedAt}
+			assert.NoError(t, verifier.Verify("service-key", msg.Revision, msg.Data, sig))
+			revisions[tc.name] = msg.Revision
+		})
+	}
+	assert.Equal(t, "revision", revisions["not targeted"])
+	assert.NotEqual(t, revisions["not targeted"], revisions["label canary"])
+	assert.NotEqual(t, revisions["label canary"], revisions["instance override wins over the label override"])
+
+	// the revision is reported after it's written to the client
+	var report struct {
+		Data websocket.ResponseConnectionRevisions `json:"data"`
+	}
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(server.URL + "/v1/connections/revisions?clientKey=service-key")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+
+		for _, revision := range report.Data {
+			if revision.Revision != revisions["not targeted"] &&
+				revision.Revision != revisions["label canary"] &&
+				revision.Revision != revisions["instance override wins over the label override"] {
+				return false
+			}
+		}
+		return len(report.Data) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	instances := make(map[string][]string)
+	for _, revision := range report.Data {
+		assert.Equal(t, "service-key", revision.ClientKey)
+		for _, instance := range revision.Instances {
+			instances[revision.Revision] = append(instances[revision.Revision], instance.InstanceId)
+		}
+	}
+	assert.Equal(t, []string{"pod-0"}, instances[revisions["not targeted"]])
+	assert.Equal(t, []string{"pod-2"}, instances[revisions["label canary"]])
+	assert.Equal(t, []string{"pod-1"}, instances[revisions["instance override wins over the label override"]])
+
+	resp, err := http.Get(server.URL + "/v1/connections?hostname=host-1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var connections struct {
+		Data websocket.ResponseConnections `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&connections))
+	require.Len(t, connections.Data, 1)
+	assert.Equal(t, "pod-1", connections.Data[0].InstanceId)
+	assert.Equal(t, "host-1", connections.Data[0].Hostname)
+}
+
+func TestTargetedDeliveryInvalid(t *testing.T) {
+	server := newWebsocketServer(t)
+
+	// the override of "pod-1" can't be applied to the configuration that isn't an object,
+	// the other clients still receive it
+	targeted := dial(t, server, "authorization=service-key&instanceId=pod-1")
+	read(t, targeted)
+	conns := make([]*xwebsocket.Conn, 3)
+	for i := range conns {
+		conns[i] = dial(t, server, "authorization=service-key&instanceId=pod-0")
+		read(t, conns[i])
+	}
+
+	self := dial(t, server, "self=true", config.InternalTokenHeader, internalToken)
+	write(t, self, websocket.RequestDistribute{
+		ClientKey: "service-key",
+		Revision:  "revision",
+		Data:      json.RawMessage(`["http://host"]`),
+	})
+
+	for _, conn := range conns {
+		msg := read(t, conn)
+		assert.JSONEq(t, `["http://host"]`, string(msg.Data))
+	}
+}
@@ -2,17 +2,43 @@ package websocket
 
 import (
 	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/nurcahyaari/coma/config"
 	"github.com/nurcahyaari/coma/container"
+	"github.com/nurcahyaari/coma/pkg/codec"
+	"github.com/nurcahyaari/coma/src/application/application/dto"
 	"github.com/nurcahyaari/coma/src/domain/service"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/net/websocket"
 )
 
+var (
+	ErrClientIsNotExists error = errors.New("err: client is not exists")
+)
+
 type Client struct {
+	Id         string
 	Connection *websocket.Conn
-	ClientKey  string
+	Self       bool
+	// Subscriptions is the set of the application keys that the client listens to,
+	// it's keyed by the client key
+	Subscriptions map[string]*Subscription
+	RemoteAddr    string
+	SdkName       string
+	SdkVersion    string
+	InstanceId    string
+	Hostname      string
+	Labels        map[string]string
+	ConnectedAt   time.Time
+	LastSeen      time.Time
+	// Codec is the encoding and compression that is negotiated on the handshake
+	Codec codec.Codec
+
+	queue *sendQueue
 }
 
 type ContentType string
@@ -23,108 +49,473 @@ var (
 )
 
 type WebsocketConnection struct {
-	clients          map[string]Client
-	client           chan Client
-	clientsRemoved   chan []string
-	close            chan bool
+	mtx              sync.RWMutex
+	config           config.WebsocketConfig
+	queuePolicy      QueuePolicy
+	trustedProxies   []netip.Prefix
+	metrics          *connectionMetrics
+	clients          map[string]*Client
 	configurationSvc service.ApplicationConfigurationServicer
+	signingSvc       service.SigningServicer
 }
 
 type WebsocketConnectionOption func(h *WebsocketConnection)
 
-func NewWebsocketConnection(c container.Service) *WebsocketConnection {
+func NewWebsocketConnection(cfg config.WebsocketConfig, c container.Service) *WebsocketConnection {
 	websocketConnection := &WebsocketConnection{
-		clients:          make(map[string]Client),
-		client:           make(chan Client),
-		close:            make(chan bool),
-		clientsRemoved:   make(chan []string),
+		config:           cfg,
+		queuePolicy:      NewQueuePolicy(cfg.SendQueuePolicy),
+		trustedProxies:   parseTrustedProxies(cfg.TrustedProxies),
+		metrics:          newConnectionMetrics(),
+		clients:          make(map[string]*Client),
 		configurationSvc: c.ApplicationConfigurationServicer,
+		signingSvc:       c.SigningServicer,
 	}
 	return websocketConnection
 }
 
-func (w *WebsocketConnection) establishConn() {
+// createClient registers the client with its own send queue,
+// the queue must be drained by the writer of the client
+func (w *WebsocketConnection) createClient(c *Client) {
+	c.queue = newSendQueue(w.config.SendQueueSize, w.queuePolicy)
+
+	w.mtx.Lock()
+	w.clients[c.Id] = c
+	w.mtx.Unlock()
+
+	if !c.Self {
+		w.metrics.connect()
+	}
+
+	log.Info().
+		Str("clientId", c.Id).
+		Msg("add client")
+}
+
+// sendInitialData queues the current configuration of the subscription to the client only
+func (w *WebsocketConnection) sendInitialData(ctx context.Context, client *Client, subscription Subscription) {
+	if client.Self {
+		return
+	}
+
+	message, err := w.snapshot(ctx, client, subscription)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("clientId", client.Id).
+			Str("subscription", subscription.Id).
+			Msg("[sendInitialData] err: get configuration")
+		return
+	}
+
+	if !w.enqueue(client, message) {
+		w.removeClient(client.Id, DisconnectReasonSlowConsumer)
+	}
+}
+
+// snapshot builds the distribute message of the current configuration of the client,
+// the overrides that target the client are applied
+func (w *WebsocketConnection) snapshot(ctx context.Context, client *Client, subscription Subscription) (outboundMessage, error) {
+	configuration, err := w.configurationSvc.GetConfigurationViewTypeJSON(ctx, dto.RequestGetConfiguration{
+		XClientKey: subscription.ClientKey,
+	})
+	if err != nil {
+		return outboundMessage{}, err
+	}
+
+	overrides, err := w.configurationSvc.InternalFindConfigurationOverrides(ctx, subscription.ClientKey)
+	if err != nil {
+		return outboundMessage{}, err
+	}
+
+	distribution, err := newVariants(RequestDistribute{
+		ClientKey: subscription.ClientKey,
+		Revision:  configuration.Revision(),
+		Data:      configuration.Data,
+	}, overrides, w.signingSvc).distribution(client)
+	if err != nil {
+		return outboundMessage{}, err
+	}
+
+	message, err := distribution.message(subscription, client.Codec)
+	if err != nil {
+		return outboundMessage{}, err
+	}
+
+	return outboundMessage{
+		data:      message,
+		clientKey: subscription.ClientKey,
+		revision:  distribution.request.Revision,
+	}, nil
+}
+
+// subscribe adds the authorized key to the client, a key can only be subscribed once
+func (w *WebsocketConnection) subscribe(client *Client, subscription Subscription) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if _, exists := client.Subscriptions[subscription.ClientKey]; exists {
+		return ErrSubscriptionExists
+	}
+	if _, exists := client.subscription(subscription.Id); exists {
+		return ErrSubscriptionExists
+	}
+	if len(client.Subscriptions) >= w.config.MaxSubscriptions {
+		return ErrSubscriptionLimit
+	}
+
+	subscription.SubscribedAt = time.Now()
+	client.Subscriptions[subscription.ClientKey] = &subscription
+
+	log.Info().
+		Str("clientId", client.Id).
+		Str("subscription", subscription.Id).
+		Msg("subscribe")
+
+	return nil
+}
+
+// unsubscribe removes the subscription, the queued messages of the subscription are not sent
+func (w *WebsocketConnection) unsubscribe(client *Client, subscriptionId string) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	subscription, exists := client.subscription(subscriptionId)
+	if !exists {
+		return ErrSubscriptionNotExists
+	}
+	delete(client.Subscriptions, subscription.ClientKey)
+
+	log.Info().
+		Str("clientId", client.Id).
+		Str("subscription", subscriptionId).
+		Msg("unsubscribe")
+
+	return nil
+}
+
+// reply queues the answer of the subscription request
+func (w *WebsocketConnection) reply(client *Client, response ResponseSubscription) {
+	message, err := client.Codec.Marshal(response)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[reply] err: marshaling response")
+		return
+	}
+
+	if !w.enqueue(client, outboundMessage{data: message, control: true}) {
+		w.removeClient(client.Id, DisconnectReasonSlowConsumer)
+	}
+}
+
+func (w *WebsocketConnection) subscribed(client *Client, clientKey string) bool {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	_, exists := client.Subscriptions[clientKey]
+	return exists
+}
+
+// enqueue never blocks the caller, it returns false
+// when the client can't keep up and must be disconnected
+func (w *WebsocketConnection) enqueue(client *Client, message outboundMessage) bool {
+	dropped, ok := client.queue.push(message)
+	if dropped > 0 {
+		w.metrics.drop(dropped)
+		log.Warn().
+			Str("clientId", client.Id).
+			Int("dropped", dropped).
+			Str("policy", string(client.queue.policy)).
+			Msg("[enqueue] send queue is full")
+	}
+	return ok
+}
+
+// writer sends the queued messages to the client until the context is done,
+// so a slow client only blocks its own writer
+func (w *WebsocketConnection) writer(ctx context.Context, client *Client) {
 	for {
 		select {
-		case client := <-w.client:
-			w.createClient(client)
-		case <-w.close:
-			w.removeAllClient()
-		case clientsRemoved := <-w.clientsRemoved:
-			w.removeClients(clientsRemoved)
+		case <-ctx.Done():
+			return
+		case message := <-client.queue.messages:
+			// the subscription is removed while the message is queued
+			if !message.control && !w.subscribed(client, message.clientKey) {
+				continue
+			}
+
+			if err := w.send(client, message.data); err != nil {
+				log.Warn().
+					Err(err).
+					Str("clientId", client.Id).
+					Msg("[writer] err: send message")
+				w.removeClient(client.Id, DisconnectReasonSendFailure)
+				return
+			}
+			w.setRevision(client.Id, message.clientKey, message.revision)
 		}
 	}
 }
 
-func (w *WebsocketConnection) createClient(c Client) {
-	clientId := uuid.New().String()
-	w.clients[clientId] = c
+// removeClient closes the connection and removes the client,
+// it's safe to be called more than once, only the first reason is counted
+func (w *WebsocketConnection) removeClient(clientId string, reason DisconnectReason) {
+	w.mtx.Lock()
+	client, exists := w.clients[clientId]
+	if exists {
+		delete(w.clients, clientId)
+	}
+	w.mtx.Unlock()
+
+	if !exists {
+		return
+	}
 
-	if c.ClientKey != "" {
-		w.sendInitialData(c.ClientKey)
+	client.Connection.Close()
+	if !client.Self {
+		w.metrics.disconnect(reason)
 	}
 
 	log.Info().
 		Str("clientId", clientId).
-		Msg("add client")
+		Str("reason", string(reason)).
+		Msg("remove client")
 }
 
-func (w *WebsocketConnection) sendInitialData(clientKey string) {
-	if err := w.configurationSvc.DistributeConfiguration(context.Background(), clientKey); err != nil {
-		log.Warn().Msg("sendInitialData failed due to its data is empty")
+func (w *WebsocketConnection) removeAllClient() {
+	for _, id := range w.clientIds() {
+		w.removeClient(id, DisconnectReasonShutdown)
 	}
 }
 
-func (w *WebsocketConnection) removeClient(clientId string) {
-	w.clients[clientId].Connection.Close()
-	delete(w.clients, clientId)
+func (w *WebsocketConnection) removeClients(clientIds []string, reason DisconnectReason) {
+	for _, clientId := range clientIds {
+		w.removeClient(clientId, reason)
+	}
 }
 
-func (w *WebsocketConnection) removeAllClient() {
-	for id, _ := range w.clients {
-		w.removeClient(id)
-	}
+// send writes the message to the client within the write timeout
+func (w *WebsocketConnection) send(client *Client, message []byte) error {
+	client.Connection.SetWriteDeadline(time.Now().Add(w.config.WriteTimeout))
+	return websocket.Message.Send(client.Connection, message)
 }
 
-func (w *WebsocketConnection) removeClients(clientIds []string) {
-	for _, clientId := range clientIds {
-		w.removeClient(clientId)
+func (w *WebsocketConnection) clientIds() []string {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	ids := make([]string, 0, len(w.clients))
+	for id := range w.clients {
+		ids = append(ids, id)
 	}
+	return ids
 }
 
-type broadcast struct {
-	clientKey string
+// touch updates the last time the client was seen
+func (w *WebsocketConnection) touch(client *Client) {
+	w.extendReadDeadline(client)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	client.LastSeen = time.Now()
 }
 
-type broadcastOption func(c *broadcast)
+// findClients returns a copy of the connected clients that match the filter
+func (w *WebsocketConnection) findClients(filter FilterClient) []Client {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
 
-func SetClientKey(clientKey string) broadcastOption {
-	return func(c *broadcast) {
-		c.clientKey = clientKey
+	clients := make([]Client, 0)
+	for _, client := range w.clients {
+		if !filter.Match(*client) {
+			continue
+		}
+		clients = append(clients, client.copy())
 	}
+
+	return clients
 }
 
-func (w *WebsocketConnection) broadcast(message []byte, opts ...broadcastOption) ([]string, error) {
+func (w *WebsocketConnection) connectionMetrics() ResponseConnectionMetrics {
 	var (
-		clientIdsErr   []string
-		err            error
-		specificClient broadcast
+		now                   = time.Now()
+		active, subscriptions = 0, 0
+		drift                 = make(map[DriftStatus]int64, len(driftStatuses))
 	)
+	for _, status := range driftStatuses {
+		drift[status] = 0
+	}
+
+	w.mtx.RLock()
+	for _, client := range w.clients {
+		if client.Self {
+			continue
+		}
+		active++
+		subscriptions += len(client.Subscriptions)
+		for _, subscription := range client.Subscriptions {
+			drift[subscription.driftStatus(now, w.config.DriftGracePeriod)]++
+		}
+	}
+	w.mtx.RUnlock()
+
+	response := w.metrics.response(active, subscriptions)
+	response.Drift = drift
+	return response
+}
+
+// drift reports the applied revision of the clients
+func (w *WebsocketConnection) drift(filter FilterClient, status DriftStatus) ResponseConnectionDrifts {
+	return NewResponseConnectionDrifts(w.findClients(filter), filter, status, time.Now(), w.config.DriftGracePeriod)
+}
+
+func (w *WebsocketConnection) findClient(clientId string) (Client, error) {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	client, exists := w.clients[clientId]
+	if !exists || client.Self {
+		return Client{}, ErrClientIsNotExists
+	}
+
+	return client.copy(), nil
+}
+
+// disconnect forcibly closes the connection of the client
+func (w *WebsocketConnection) disconnect(clientId string) error {
+	if _, err := w.findClient(clientId); err != nil {
+		return err
+	}
+
+	w.removeClient(clientId, DisconnectReasonForced)
+	return nil
+}
+
+// resync queues the current configuration of every subscription to the client
+func (w *WebsocketConnection) resync(ctx context.Context, clientId string) error {
+	w.mtx.RLock()
+	client, exists := w.clients[clientId]
+	if !exists || client.Self {
+		w.mtx.RUnlock()
+		return ErrClientIsNotExists
+	}
+	subscriptions := client.copy().Subscriptions
+	w.mtx.RUnlock()
+
+	for _, subscription := range subscriptions {
+		message, err := w.snapshot(ctx, client, *subscription)
+		if err != nil {
+			return err
+		}
+
+		if !w.enqueue(client, message) {
+			w.removeClient(clientId, DisconnectReasonSlowConsumer)
+			return ErrClientIsNotExists
+		}
+	}
+
+	return nil
+}
+
+func (w *WebsocketConnection) setRevision(clientId, clientKey, revision string) {
+	if revision == "" {
+		return
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	client, exists := w.clients[clientId]
+	if !exists {
+		return
+	}
+	subscription, exists := client.Subscriptions[clientKey]
+	if !exists || subscription.Revision == revision {
+		return
+	}
+
+	subscription.Revision = revision
+	subscription.RevisionSentAt = time.Now()
+	// the slice is shared by the copies of the client, it's never modified in place
+	sentRevisions := append([]string{revision}, subscription.sentRevisions...)
+	if len(sentRevisions) > maxSentRevisions {
+		sentRevisions = sentRevisions[:maxSentRevisions]
+	}
+	subscription.sentRevisions = sentRevisions
+}
 
-	for _, opt := range opts {
-		opt(&specificClient)
+// report stores the revision that is applied by the client
+func (w *WebsocketConnection) report(client *Client, subscriptionId, revision string) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	subscription, exists := client.subscription(subscriptionId)
+	if !exists {
+		return ErrSubscriptionNotExists
+	}
+
+	subscription.Applied = revision
+	subscription.AppliedAt = time.Now()
+	return nil
+}
+
+// broadcast queues the configuration to the subscribed clients without waiting for the write,
+// it returns the clients that can't keep up and must be disconnected
+func (w *WebsocketConnection) broadcast(ctx context.Context, request RequestDistribute) []string {
+	var clientIdsSlow []string
+
+	// the clients without override still receive the configuration when the overrides can't be read
+	overrides, err := w.configurationSvc.InternalFindConfigurationOverrides(ctx, request.ClientKey)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("[broadcast] err: find configuration overrides")
 	}
+	// the configuration is signed once per set of the matching overrides,
+	// then the message is encoded once per subscription id and codec
+	variants := newVariants(request, overrides, w.signingSvc)
+
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
 
 	for id, client := range w.clients {
-		if specificClient.clientKey != "" && client.ClientKey != specificClient.clientKey {
+		if client.Self {
+			continue
+		}
+		subscription, subscribed := client.Subscriptions[request.ClientKey]
+		if !subscribed {
+			continue
+		}
+
+		distribution, err := variants.distribution(client)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("clientId", id).
+				Msg("[broadcast] err: signing message")
 			continue
 		}
 
-		err = websocket.Message.Send(client.Connection, message)
+		message, err := distribution.message(*subscription, client.Codec)
 		if err != nil {
-			clientIdsErr = append(clientIdsErr, id)
+			log.Error().
+				Err(err).
+				Str("clientId", id).
+				Str("codec", client.Codec.String()).
+				Msg("[broadcast] err: marshaling message")
+			continue
+		}
+
+		if !w.enqueue(client, outboundMessage{
+			data:      message,
+			clientKey: request.ClientKey,
+			revision:  distribution.request.Revision,
+		}) {
+			clientIdsSlow = append(clientIdsSlow, id)
 		}
 	}
 
-	return clientIdsErr, err
+	return clientIdsSlow
 }
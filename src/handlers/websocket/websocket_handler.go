@@ -2,21 +2,35 @@ package websocket
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nurcahyaari/coma/config"
 	"github.com/nurcahyaari/coma/container"
 	internalerrors "github.com/nurcahyaari/coma/internal/x/errors"
+	"github.com/nurcahyaari/coma/pkg/codec"
 	"github.com/nurcahyaari/coma/src/application/application/dto"
+	"github.com/nurcahyaari/coma/src/domain/entity"
 	"github.com/nurcahyaari/coma/src/domain/service"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/net/websocket"
 )
 
+var (
+	ErrReceiveOnly error = errors.New("err: client connection is receive only")
+)
+
 type WebsocketHandler struct {
+	// internalToken authorizes the self connection, only the self connection
+	// can distribute the configuration
+	internalToken     string
 	connection        *WebsocketConnection
 	configurationSvc  service.ApplicationConfigurationServicer
 	applicationKeySvc service.ApplicationKeyServicer
@@ -32,66 +46,132 @@ func (h WebsocketHandler) Router(r *chi.Mux) {
 	})
 }
 
-func NewWebsocketHandler(c container.Service) *WebsocketHandler {
+func NewWebsocketHandler(cfg *config.Config, c container.Service) *WebsocketHandler {
 	websocketHandler := &WebsocketHandler{
-		connection:        NewWebsocketConnection(c),
+		internalToken:     cfg.Websocket.InternalToken,
+		connection:        NewWebsocketConnection(cfg.Websocket, c),
 		configurationSvc:  c.ApplicationConfigurationServicer,
 		applicationKeySvc: c.ApplicationKeyServicer,
 	}
 
-	go websocketHandler.connection.establishConn()
-
 	return websocketHandler
 }
 
 func (w *WebsocketHandler) Close() {
 	log.Warn().Msg("Clossing websocket connection")
-	w.connection.close <- true
+	w.connection.removeAllClient()
+}
+
+// authorize finds the application of the client key
+func (w *WebsocketHandler) authorize(ctx context.Context, clientKey string) (entity.ApplicationKey, error) {
+	applicationKey, err := w.applicationKeySvc.InternalFindApplicationKey(ctx, dto.RequestInternalFindApplicationKey{
+		Key:            clientKey,
+		SkipValidation: true,
+	})
+	if err != nil {
+		errCustom := err.(*internalerrors.Error)
+		log.Error().
+			Err(errCustom.Err).
+			Msg("[Websocket.FindApplicationKey] err: search applicationKey")
+		return applicationKey, err
+	}
+	if clientKey == "" || applicationKey.Key != clientKey {
+		log.Error().
+			Msg("[Websocket.FindApplicationKey] client key doesn't exists")
+		return applicationKey, ErrSubscriptionUnauthorize
+	}
+
+	return applicationKey, nil
+}
+
+// authorizeInternal checks the credential of the internal publisher
+func (w *WebsocketHandler) authorizeInternal(r *http.Request) bool {
+	token := r.Header.Get(config.InternalTokenHeader)
+	if w.internalToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(w.internalToken)) == 1
 }
 
 func (w *WebsocketHandler) Websocket(c *websocket.Conn) {
 	// add client
 	selfConnection := c.Request().URL.Query().Get("self")
 	isSelfConnection, _ := strconv.ParseBool(selfConnection)
-	clientKey := c.Request().URL.Query().Get("authorization")
+	if isSelfConnection && !w.authorizeInternal(c.Request()) {
+		log.Error().
+			Str("remoteAddr", c.Request().RemoteAddr).
+			Msg("[Websocket] self connection is not authorized")
+		return
+	}
+	client := newClient(c, isSelfConnection, w.connection.trustedProxies)
+
+	// the key of the handshake is the default subscription,
+	// the other keys are subscribed by the subscription request
+	var defaultSubscription Subscription
 	if !isSelfConnection {
-		exists, err := w.applicationKeySvc.IsExistsApplicationKey(context.Background(), dto.RequestFindApplicationKey{
-			Key: clientKey,
-		})
+		clientKey := c.Request().URL.Query().Get("authorization")
+		applicationKey, err := w.authorize(context.Background(), clientKey)
 		if err != nil {
-			errCustom := err.(*internalerrors.Error)
-			log.Error().
-				Err(errCustom.Err).
-				Msg("[Websocket.FindApplicationKey] err: search applicationKey")
 			return
 		}
-		if !exists {
-			errCustom := err.(*internalerrors.Error)
-			log.Error().
-				Err(errCustom.Err).
-				Msg("[Websocket.FindApplicationKey] client key doesn't exists")
+
+		// the encoding is negotiated once, the error is answered in JSON
+		// because the client can't decode the other encodings
+		client.Codec, err = codec.Parse(c.Request().URL.Query().Get("encoding"), c.Request().URL.Query().Get("compression"))
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("clientId", client.Id).
+				Msg("[Websocket] err: negotiating encoding")
+			message, _ := codec.Default.Marshal(ResponseSubscription{
+				Type:  MessageTypeError,
+				Error: err.Error(),
+			})
+			websocket.Message.Send(c, message)
 			return
 		}
-	}
 
-	w.connection.client <- Client{
-		Connection: c,
-		ClientKey:  clientKey,
+		defaultSubscription = Subscription{
+			Id:            DefaultSubscription,
+			ClientKey:     clientKey,
+			ApplicationId: applicationKey.ApplicationId,
+			SubscribedAt:  time.Now(),
+		}
+		client.Subscriptions[clientKey] = &defaultSubscription
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	disconnectReason := DisconnectReasonClosed
+	defer func() {
+		cancel()
+		w.connection.removeClient(client.Id, disconnectReason)
+	}()
+
+	w.connection.createClient(client)
+	go w.connection.writer(ctx, client)
+	go w.connection.heartbeat(ctx, client)
+	go w.connection.sendInitialData(ctx, client, defaultSubscription)
+
 	for {
-		var (
-			msg  string
-			data RequestDistribute
-		)
+		var data RequestDistribute
 
-		err := websocket.Message.Receive(c, &msg)
+		byt, err := receive(c, func(payloadType byte) {
+			w.connection.touch(client)
+		})
 		if err == io.EOF {
 			log.Warn().
 				Err(err).
 				Msg("[Websocket] connection is closed")
 			break
 		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			disconnectReason = DisconnectReasonTimeout
+			log.Warn().
+				Err(err).
+				Str("clientId", client.Id).
+				Msg("[Websocket] client is unresponsive")
+			break
+		}
 		if err != nil {
 			log.Error().
 				Err(err).
@@ -99,6 +179,26 @@ func (w *WebsocketHandler) Websocket(c *websocket.Conn) {
 			break
 		}
 
+		// the client connection is receive only, the configuration
+		// is only distributed by the self connection
+		if !client.Self {
+			if request, ok := isSubscription(client.Codec, byt); ok {
+				w.subscription(ctx, client, request)
+				continue
+			}
+
+			log.Warn().
+				Str("clientId", client.Id).
+				Msg("[Websocket] client message is rejected, the connection is receive only")
+			w.connection.reply(client, ResponseSubscription{
+				Type:  MessageTypeError,
+				Error: ErrReceiveOnly.Error(),
+			})
+			continue
+		}
+
+		msg := string(byt)
+
 		log.Info().
 			Str("message", msg).
 			Msg("[Websocket] received message")
@@ -118,26 +218,83 @@ func (w *WebsocketHandler) Websocket(c *websocket.Conn) {
 			continue
 		}
 
-		byt, err := json.Marshal(data)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Msg("[Websocket] err: marshaling to byte")
-			continue
+		clients := w.connection.broadcast(ctx, data)
+		if len(clients) > 0 {
+			w.connection.removeClients(clients, DisconnectReasonSlowConsumer)
 		}
 
-		clients, err := w.connection.broadcast(byt,
-			SetClientKey(data.ClientKey))
+		log.Info().
+			Str("message", string(msg)).
+			Msg("[Websocket] message is queued")
+	}
+}
+
+// subscription handles the subscription request of the client, every key is authorized
+// on its own and the current configuration is sent after the answer
+func (w *WebsocketHandler) subscription(ctx context.Context, client *Client, request RequestSubscription) {
+	response := ResponseSubscription{
+		Subscription: request.Subscription,
+	}
+
+	// the report is periodic, it's only answered when it's rejected
+	if request.Type == MessageTypeReport {
+		err := request.Validate()
+		if err == nil {
+			err = w.connection.report(client, request.Subscription, request.Revision)
+		}
 		if err != nil {
-			w.connection.clientsRemoved <- clients
-			log.Error().
+			log.Warn().
 				Err(err).
-				Msg("[Websocket] err: send message")
-			continue
+				Str("clientId", client.Id).
+				Str("subscription", request.Subscription).
+				Msg("[Websocket.subscription] err: report request")
+			response.Type = MessageTypeError
+			response.Error = err.Error()
+			w.connection.reply(client, response)
 		}
+		return
+	}
 
-		log.Info().
-			Str("message", string(msg)).
-			Msg("[Websocket] success send message")
+	var (
+		subscription Subscription
+		err          = request.Validate()
+	)
+	if err == nil {
+		switch request.Type {
+		case MessageTypeSubscribe:
+			var applicationKey entity.ApplicationKey
+			applicationKey, err = w.authorize(ctx, request.Authorization)
+			if err != nil {
+				err = ErrSubscriptionUnauthorize
+				break
+			}
+
+			subscription = Subscription{
+				Id:            request.Subscription,
+				ClientKey:     request.Authorization,
+				ApplicationId: applicationKey.ApplicationId,
+			}
+			err = w.connection.subscribe(client, subscription)
+			response.Type = MessageTypeSubscribed
+		case MessageTypeUnsubscribe:
+			err = w.connection.unsubscribe(client, request.Subscription)
+			response.Type = MessageTypeUnsubscribed
+		}
+	}
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("clientId", client.Id).
+			Str("subscription", request.Subscription).
+			Msg("[Websocket.subscription] err: subscription request")
+		response.Type = MessageTypeError
+		response.Error = err.Error()
+	}
+
+	w.connection.reply(client, response)
+
+	if err == nil && request.Type == MessageTypeSubscribe {
+		w.connection.sendInitialData(ctx, client, subscription)
 	}
 }